@@ -0,0 +1,180 @@
+package godb
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// insertBatch inserts the elements of list, a slice of struct pointers or
+// structs, with multi-row insert statements of at most batchSize rows.
+// Consecutive elements mapped to the same table share a statement, and the
+// number of rows per statement is lowered to fit the bind variable limit of
+// the dialect.
+//
+// Dialects that do not implement BatchInserter, or that cannot return the
+// generated keys of a multi-row insert for tables with an autoincrement
+// key, fall back to one insert per element.
+func insertBatch(dbUtils *DbUtils, queryRunner SqlQueryRunner, list interface{}, batchSize int) error {
+	listv := reflect.ValueOf(list)
+	if listv.Kind() != reflect.Slice {
+		return fmt.Errorf("godb: InsertBatch list must be a slice, but got: %T", list)
+	}
+
+	ptrs := make([]interface{}, listv.Len())
+	for i := range ptrs {
+		v := listv.Index(i)
+		if v.Kind() == reflect.Struct {
+			v = v.Addr()
+		}
+		ptrs[i] = v.Interface()
+	}
+
+	batcher, ok := dbUtils.Dialect.(BatchInserter)
+	if !ok || batchSize < 2 {
+		return insert(dbUtils, queryRunner, ptrs...)
+	}
+
+	var (
+		table   *TableMap
+		pending []interface{}
+		maxRows int
+	)
+	for _, ptr := range ptrs {
+		t, _, err := dbUtils.tableForPointer(ptr, false)
+		if err != nil {
+			return err
+		}
+		if t != table && len(pending) > 0 {
+			err = insertRows(dbUtils, queryRunner, table, pending)
+			if err != nil {
+				return err
+			}
+			pending = pending[:0]
+		}
+		if t != table {
+			table = t
+			maxRows = batchRows(batcher, t.bindInsert(), batchSize)
+		}
+
+		pending = append(pending, ptr)
+		if len(pending) == maxRows {
+			err = insertRows(dbUtils, queryRunner, table, pending)
+			if err != nil {
+				return err
+			}
+			pending = pending[:0]
+		}
+	}
+	if len(pending) > 0 {
+		return insertRows(dbUtils, queryRunner, table, pending)
+	}
+
+	return nil
+}
+
+// batchRows returns the number of rows of the plan that fit in a single
+// statement of the dialect.
+func batchRows(batcher BatchInserter, plan *bindPlan, batchSize int) int {
+	rows := batchSize
+	if n := len(plan.argFields); n > 0 && batcher.MaxBindVars()/n < rows {
+		rows = batcher.MaxBindVars() / n
+	}
+	if max := batcher.MaxBatchRows(); max > 0 && max < rows {
+		rows = max
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return rows
+}
+
+// insertRows inserts elements of the same table with one statement.
+func insertRows(dbUtils *DbUtils, queryRunner SqlQueryRunner, table *TableMap, ptrs []interface{}) error {
+	plan := table.bindInsert()
+
+	intInserter, intOk := dbUtils.Dialect.(IntegerBatchAutoIncrInserter)
+	targetInserter, targetOk := dbUtils.Dialect.(TargetedBatchAutoIncrInserter)
+	if len(ptrs) == 1 || (plan.autoIncrIdx > -1 && !intOk && !targetOk) {
+		return insert(dbUtils, queryRunner, ptrs...)
+	}
+
+	elems := make([]reflect.Value, len(ptrs))
+	bis := make([]bindInstance, len(ptrs))
+	var (
+		args     []interface{}
+		restores []func()
+	)
+	restore := func() {
+		for _, r := range restores {
+			r()
+		}
+	}
+	now := dbUtils.now()
+	for i, ptr := range ptrs {
+		elems[i] = reflect.ValueOf(ptr).Elem()
+		restores = append(restores, table.setTimestamps(elems[i], now, true))
+		if v, ok := ptr.(HasPreInsert); ok {
+			err := v.PreInsert(queryRunner)
+			if err != nil {
+				restore()
+				return err
+			}
+		}
+
+		bi, err := plan.createBindInstance(elems[i], dbUtils.TypeConverter)
+		if err != nil {
+			restore()
+			return err
+		}
+		bis[i] = bi
+		args = append(args, bi.args...)
+	}
+
+	query := plan.batchQuery(dbUtils.Dialect, len(ptrs))
+
+	if plan.autoIncrIdx > -1 {
+		if targetOk {
+			targets := make([]interface{}, len(elems))
+			for i, elem := range elems {
+				targets[i] = fieldByName(elem, plan.autoIncrFieldName).Addr().Interface()
+			}
+			err := targetInserter.InsertAutoIncrBatchToTargets(queryRunner, query, targets, args...)
+			if err != nil {
+				restore()
+				return err
+			}
+		} else {
+			ids, err := intInserter.InsertAutoIncrBatch(queryRunner, query, len(elems), args...)
+			if err != nil {
+				restore()
+				return err
+			}
+			for i, elem := range elems {
+				if !setAutoIncrValue(fieldByName(elem, plan.autoIncrFieldName), ids[i]) {
+					return fmt.Errorf("godb: cannot set autoincrement value on non-Int field. SQL=%s  autoIncrIdx=%d autoIncrFieldName=%s", query, plan.autoIncrIdx, plan.autoIncrFieldName)
+				}
+			}
+		}
+	} else {
+		_, err := queryRunner.Exec(query, args...)
+		if err != nil {
+			restore()
+			return err
+		}
+	}
+
+	for i, ptr := range ptrs {
+		if plan.versField != "" {
+			fieldByName(elems[i], plan.versField).SetInt(bis[i].existingVersion + 1)
+		}
+
+		if v, ok := ptr.(HasPostInsert); ok {
+			err := v.PostInsert(queryRunner)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package godb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBindPlan_batchQuery(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		query   string
+	}{
+		{MySQLDialect{}, "insert into `invoice_test` (`Id`,`Created`,`Updated`,`Memo`,`PersonId`,`IsPaid`) values (null,?,?,?,?,?),(null,?,?,?,?,?);"},
+		{PostgresDialect{}, `insert into "invoice_test" ("Id","Created","Updated","Memo","PersonId","IsPaid") values (default,$1,$2,$3,$4,$5),(default,$6,$7,$8,$9,$10) returning "Id";`},
+		{SqliteDialect{}, `insert into "invoice_test" ("Id","Created","Updated","Memo","PersonId","IsPaid") values (null,?,?,?,?,?),(null,?,?,?,?,?);`},
+		{Db2Dialect{}, `select "ID" from final table (insert into "INVOICE_TEST" ("ID","CREATED","UPDATED","MEMO","PERSONID","ISPAID") values (default,?,?,?,?,?),(default,?,?,?,?,?)) order by input sequence`},
+		{SqlServerDialect{}, `insert into [invoice_test] ([Created],[Updated],[Memo],[PersonId],[IsPaid]) values (?,?,?,?,?),(?,?,?,?,?);`},
+	}
+
+	for _, test := range tests {
+		dbUtils := &DbUtils{Dialect: test.dialect}
+		table := dbUtils.AddTableWithName(Invoice{}, "invoice_test").SetKeys(true, "Id")
+		query := table.bindInsert().batchQuery(test.dialect, 2)
+		if query != test.query {
+			t.Errorf("%T: got %s, want %s", test.dialect, query, test.query)
+		}
+	}
+}
+
+func TestBatchRows(t *testing.T) {
+	dbUtils := &DbUtils{Dialect: SqliteDialect{}}
+	plan := dbUtils.AddTableWithName(Invoice{}, "invoice_test").SetKeys(true, "Id").bindInsert()
+
+	if n := batchRows(SqliteDialect{}, plan, 1000); n != 199 {
+		t.Errorf("%d != 199", n)
+	}
+	if n := batchRows(SqliteDialect{}, plan, 50); n != 50 {
+		t.Errorf("%d != 50", n)
+	}
+	if n := batchRows(SqlServerDialect{}, plan, 5000); n != 420 {
+		t.Errorf("%d != 420", n)
+	}
+}
+
+func Test_InsertBatch(t *testing.T) {
+	dbmap := initDB()
+	dbmap.AddTableWithName(Invoice{}, "invoice_test").SetKeys(true, "Id")
+	dbmap.CreateTablesIfNotExists()
+	defer close(dbmap)
+
+	invoices := make([]*Invoice, 25)
+	for i := range invoices {
+		invoices[i] = &Invoice{Memo: "batch", PersonId: int64(i)}
+	}
+	err := dbmap.InsertBatch(invoices, 10)
+	if err != nil {
+		panic(err)
+	}
+
+	for i, inv := range invoices {
+		if i > 0 && inv.Id != invoices[i-1].Id+1 {
+			t.Errorf("unexpected id %d after %d", inv.Id, invoices[i-1].Id)
+		}
+		obj := _get(dbmap, Invoice{}, inv.Id)
+		if !reflect.DeepEqual(inv, obj) {
+			t.Errorf("%v != %v", inv, obj)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package godb
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// LimitClauser is implemented by dialects that need their own syntax to
+// restrict the number of rows returned by a select statement. Dialects that
+// do not implement it get the "limit n offset m" form.
+type LimitClauser interface {
+	// LimitClause returns the clause appended after the "order by" part of
+	// the statement. limit is negative when no limit was requested, and
+	// ordered reports whether the statement already has an "order by".
+	LimitClause(limit, offset int, ordered bool) string
+}
+
+type whereClause struct {
+	cond string
+	args []interface{}
+}
+
+// SelectBuilder builds a select statement for a mapped table. Conditions use
+// "?" as placeholder, which is rewritten to the bind variable of the dialect,
+// and field names given to WhereEq, WhereIn, OrderBy and Columns are mapped
+// to their quoted column names.
+//
+// A SelectBuilder is created with DbUtils.From, Transaction.From or
+// TableMap.Query, and is not safe for concurrent use.
+type SelectBuilder struct {
+	queryRunner SqlQueryRunner
+	table       *TableMap
+	columns     []string
+	wheres      []whereClause
+	orderBys    []string
+	limit       int
+	offset      int
+	unscoped    bool
+	err         error
+}
+
+// From starts a select statement on the table mapped to i.
+func (dbUtils *DbUtils) From(i interface{}) *SelectBuilder {
+	return newSelectBuilder(dbUtils, dbUtils, i)
+}
+
+// From has the same behavior as DbUtils.From(), but runs in a transaction.
+func (t *Transaction) From(i interface{}) *SelectBuilder {
+	return newSelectBuilder(t.dbUtils, t, i)
+}
+
+// Query starts a select statement on this table.
+func (t *TableMap) Query() *SelectBuilder {
+	return &SelectBuilder{queryRunner: t.dbUtils, table: t, limit: -1}
+}
+
+func newSelectBuilder(dbUtils *DbUtils, queryRunner SqlQueryRunner, i interface{}) *SelectBuilder {
+	b := &SelectBuilder{queryRunner: queryRunner, limit: -1, unscoped: dbUtils.unscoped}
+
+	t, err := toType(i)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.table, b.err = dbUtils.TableFor(t, false)
+	return b
+}
+
+func (b *SelectBuilder) column(field string) string {
+	if b.table == nil {
+		return field
+	}
+	col := colMapOrNil(b.table, field)
+	if col == nil {
+		if b.err == nil {
+			b.err = fmt.Errorf("godb: no field %s in table %s", field, b.table.TableName)
+		}
+		return field
+	}
+	return b.table.dbUtils.Dialect.QuoteField(col.ColumnName)
+}
+
+// Columns restricts the selected columns to the given fields. By default
+// every non transient column of the table is selected.
+func (b *SelectBuilder) Columns(fields ...string) *SelectBuilder {
+	for _, field := range fields {
+		b.columns = append(b.columns, b.column(field))
+	}
+	return b
+}
+
+// Where adds a condition to the statement. Conditions are joined with "and".
+func (b *SelectBuilder) Where(cond string, args ...interface{}) *SelectBuilder {
+	b.wheres = append(b.wheres, whereClause{cond: cond, args: args})
+	return b
+}
+
+// WhereEq adds a "field = value" condition to the statement.
+func (b *SelectBuilder) WhereEq(field string, value interface{}) *SelectBuilder {
+	return b.Where(b.column(field)+"=?", value)
+}
+
+// WhereIn adds a "field in (values...)" condition to the statement. An empty
+// list of values matches no rows.
+func (b *SelectBuilder) WhereIn(field string, values ...interface{}) *SelectBuilder {
+	if len(values) == 0 {
+		return b.Where("1=0")
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?,", len(values)), ",")
+	return b.Where(b.column(field)+" in ("+marks+")", values...)
+}
+
+// OrderBy sorts the result by field in ascending order.
+func (b *SelectBuilder) OrderBy(field string) *SelectBuilder {
+	b.orderBys = append(b.orderBys, b.column(field)+" asc")
+	return b
+}
+
+// OrderByDesc sorts the result by field in descending order.
+func (b *SelectBuilder) OrderByDesc(field string) *SelectBuilder {
+	b.orderBys = append(b.orderBys, b.column(field)+" desc")
+	return b
+}
+
+// Unscoped includes the soft deleted rows, which are skipped by default.
+func (b *SelectBuilder) Unscoped() *SelectBuilder {
+	b.unscoped = true
+	return b
+}
+
+// Limit restricts the result to at most n rows.
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset skips the first n rows of the result.
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	b.offset = n
+	return b
+}
+
+// ToSql returns the statement and its arguments.
+func (b *SelectBuilder) ToSql() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+
+	dialect := b.table.dbUtils.Dialect
+
+	s := bytes.Buffer{}
+	s.WriteString("select ")
+	if len(b.columns) > 0 {
+		s.WriteString(strings.Join(b.columns, ","))
+	} else {
+		x := 0
+		for _, col := range b.table.Columns {
+			if !col.Transient {
+				if x > 0 {
+					s.WriteString(",")
+				}
+				s.WriteString(dialect.QuoteField(col.ColumnName))
+				x++
+			}
+		}
+	}
+	s.WriteString(" from ")
+	s.WriteString(dialect.QuotedTableForQuery(b.table.SchemaName, b.table.TableName))
+
+	args := b.writeWhere(&s)
+	if b.err != nil {
+		return "", nil, b.err
+	}
+
+	if len(b.orderBys) > 0 {
+		s.WriteString(" order by ")
+		s.WriteString(strings.Join(b.orderBys, ","))
+	}
+	if b.limit >= 0 || b.offset > 0 {
+		if limiter, ok := dialect.(LimitClauser); ok {
+			s.WriteString(limiter.LimitClause(b.limit, b.offset, len(b.orderBys) > 0))
+		} else {
+			s.WriteString(standardLimitClause(b.limit, b.offset))
+		}
+	}
+	s.WriteString(dialect.QuerySuffix())
+
+	return s.String(), args, nil
+}
+
+// writeWhere writes the where part of the statement to s, rewriting the "?"
+// placeholders to the bind variables of the dialect, and returns the
+// arguments in order.
+func (b *SelectBuilder) writeWhere(s *bytes.Buffer) []interface{} {
+	var args []interface{}
+	wheres := b.wheres
+	if cond := b.table.notDeletedCond(""); cond != "" && !b.unscoped {
+		wheres = append(wheres[:len(wheres):len(wheres)], whereClause{cond: cond})
+	}
+	if len(wheres) == 0 {
+		return args
+	}
+
+	dialect := b.table.dbUtils.Dialect
+
+	s.WriteString(" where ")
+	for x, w := range wheres {
+		if x > 0 {
+			s.WriteString(" and ")
+		}
+		if len(wheres) > 1 {
+			s.WriteString("(")
+		}
+		n := 0
+		quoted := false
+		for _, r := range w.cond {
+			if r == '\'' {
+				quoted = !quoted
+			}
+			if r == '?' && !quoted {
+				s.WriteString(dialect.BindVar(len(args) + n))
+				n++
+			} else {
+				s.WriteRune(r)
+			}
+		}
+		if len(wheres) > 1 {
+			s.WriteString(")")
+		}
+		if n != len(w.args) && b.err == nil {
+			b.err = fmt.Errorf("godb: condition %q has %d placeholders but %d arguments", w.cond, n, len(w.args))
+		}
+		args = append(args, w.args...)
+	}
+	return args
+}
+
+func standardLimitClause(limit, offset int) string {
+	s := ""
+	if limit >= 0 {
+		s += " limit " + strconv.Itoa(limit)
+	}
+	if offset > 0 {
+		s += " offset " + strconv.Itoa(offset)
+	}
+	return s
+}
+
+// Select runs the statement and returns one pointer to a new struct of the
+// table type per row.
+func (b *SelectBuilder) Select() ([]interface{}, error) {
+	query, args, err := b.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return b.queryRunner.Select(reflectNewTable(b.table), query, args...)
+}
+
+// SelectInto runs the statement and appends the rows to holder, which must
+// be a pointer to a slice of structs or struct pointers.
+func (b *SelectBuilder) SelectInto(holder interface{}) error {
+	query, args, err := b.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = b.queryRunner.Select(holder, query, args...)
+	return err
+}
+
+// SelectOne runs the statement and scans the single resulting row into
+// holder. sql.ErrNoRows is returned if there is no row.
+func (b *SelectBuilder) SelectOne(holder interface{}) error {
+	query, args, err := b.ToSql()
+	if err != nil {
+		return err
+	}
+	return b.queryRunner.SelectOne(holder, query, args...)
+}
+
+// Count returns the number of rows matched by the conditions of the
+// statement. Columns, order, limit and offset are ignored.
+func (b *SelectBuilder) Count() (int64, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	dialect := b.table.dbUtils.Dialect
+
+	s := bytes.Buffer{}
+	s.WriteString("select count(*) from ")
+	s.WriteString(dialect.QuotedTableForQuery(b.table.SchemaName, b.table.TableName))
+	args := b.writeWhere(&s)
+	if b.err != nil {
+		return 0, b.err
+	}
+	s.WriteString(dialect.QuerySuffix())
+
+	return b.queryRunner.SelectInt(s.String(), args...)
+}
+
+func reflectNewTable(t *TableMap) interface{} {
+	return reflect.New(t.gotype).Interface()
+}
@@ -0,0 +1,89 @@
+package godb
+
+import (
+	"reflect"
+	"testing"
+)
+
+type BuilderUser struct {
+	Id       int64  `db:"id, primarykey, autoincrement"`
+	Username string `db:"user_name"`
+	Price    float64
+	Ignored  string `db:"-"`
+}
+
+func builderDbUtils(dialect Dialect) *DbUtils {
+	dbUtils := &DbUtils{Dialect: dialect}
+	dbUtils.AddTableWithName(BuilderUser{}, "users")
+	return dbUtils
+}
+
+func TestSelectBuilder_ToSql(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		query   string
+	}{
+		{MySQLDialect{}, "select `id`,`user_name`,`Price` from `users` where (`user_name`=?) and (Price > ? or Price < ?) order by `Price` desc,`id` asc limit 10 offset 20;"},
+		{PostgresDialect{}, `select "id","user_name","Price" from "users" where ("user_name"=$1) and (Price > $2 or Price < $3) order by "Price" desc,"id" asc limit 10 offset 20;`},
+		{SqliteDialect{}, `select "id","user_name","Price" from "users" where ("user_name"=?) and (Price > ? or Price < ?) order by "Price" desc,"id" asc limit 10 offset 20;`},
+		{OracleDialect{}, `select "ID","USER_NAME","PRICE" from "USERS" where ("USER_NAME"=:1) and (Price > :2 or Price < :3) order by "PRICE" desc,"ID" asc offset 20 rows fetch next 10 rows only`},
+		{Db2Dialect{}, `select "ID","USER_NAME","PRICE" from "USERS" where ("USER_NAME"=?) and (Price > ? or Price < ?) order by "PRICE" desc,"ID" asc offset 20 rows fetch first 10 rows only`},
+		{SqlServerDialect{}, "select [id],[user_name],[Price] from [users] where ([user_name]=?) and (Price > ? or Price < ?) order by [Price] desc,[id] asc offset 20 rows fetch next 10 rows only;"},
+	}
+
+	for _, test := range tests {
+		query, args, err := builderDbUtils(test.dialect).From(&BuilderUser{}).
+			WhereEq("Username", "cly").
+			Where("Price > ? or Price < ?", 10, 1).
+			OrderByDesc("Price").
+			OrderBy("Id").
+			Limit(10).
+			Offset(20).
+			ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != test.query {
+			t.Errorf("%T: got %s, want %s", test.dialect, query, test.query)
+		}
+		if !reflect.DeepEqual(args, []interface{}{"cly", 10, 1}) {
+			t.Errorf("%T: unexpected args %v", test.dialect, args)
+		}
+	}
+}
+
+func TestSelectBuilder_WhereIn(t *testing.T) {
+	table, err := builderDbUtils(PostgresDialect{}).TableFor(reflect.TypeOf(BuilderUser{}), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, args, err := table.Query().
+		Columns("Id").
+		WhereIn("Id", 1, 2, 3).
+		Where("user_name <> '?'").
+		ToSql()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `select "id" from "users" where ("id" in ($1,$2,$3)) and (user_name <> '?');`
+	if query != want {
+		t.Errorf("got %s, want %s", query, want)
+	}
+	if len(args) != 3 {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestSelectBuilder_Errors(t *testing.T) {
+	dbUtils := builderDbUtils(MySQLDialect{})
+
+	if _, _, err := dbUtils.From(&BuilderUser{}).WhereEq("Missing", 1).ToSql(); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+	if _, _, err := dbUtils.From(&BuilderUser{}).Where("id = ? and price = ?", 1).ToSql(); err == nil {
+		t.Error("expected an error for a placeholder without argument")
+	}
+	if _, _, err := dbUtils.From(&User{}).ToSql(); err == nil {
+		t.Error("expected an error for an unmapped type")
+	}
+}
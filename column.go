@@ -23,15 +23,23 @@ type ColumnMap struct {
 	DefaultValue string
 
 	fieldName  string
+	fieldIndex []int
 	gotype     reflect.Type
 	isPK       bool
 	isAutoIncr bool
 	isNotNull  bool
-
+	isVersion  bool
+	isSoftDelete bool
+	isCreated    bool
+	isUpdated    bool
+	table      *TableMap
 }
 
+// Rename changes the column name in db table, and resets the cached SQL
+// statements of its table.
 func (c *ColumnMap) Rename(colname string) *ColumnMap {
 	c.ColumnName = colname
+	c.resetSql()
 	return c
 }
 
@@ -39,9 +47,16 @@ func (c *ColumnMap) Rename(colname string) *ColumnMap {
 // this column will be skipped when SQL statements are generated
 func (c *ColumnMap) SetTransient(b bool) *ColumnMap {
 	c.Transient = b
+	c.resetSql()
 	return c
 }
 
+func (c *ColumnMap) resetSql() {
+	if c.table != nil {
+		c.table.ResetSql()
+	}
+}
+
 // SetUnique adds "unique" to the create table statements for this
 // column, if b is true.
 func (c *ColumnMap) SetUnique(b bool) *ColumnMap {
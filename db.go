@@ -9,21 +9,11 @@ import (
 	"strconv"
 	"database/sql/driver"
 	"errors"
+	"time"
 )
 var (
 	DefaultCacheSize = 200
 )
-/*
-type cacheStruct struct {
-	value reflect.Value
-	idx   int
-}
-
-type DbUtils struct {
-	Db *sql.DB
-	reflectCache      map[reflect.Type]*cacheStruct
-	reflectCacheMutex sync.RWMutex
-}*/
 
 type DbUtils struct {
 	ctx           context.Context
@@ -31,6 +21,24 @@ type DbUtils struct {
 	tables        []*TableMap
 	Dialect       Dialect
 	TypeConverter TypeConverter
+
+	// Logger receives every statement run by the DbUtils and its
+	// transactions. Nothing is logged when nil.
+	Logger QueryLogger
+
+	// ArgRedactor, if set, filters the arguments passed to Logger.
+	ArgRedactor ArgRedactor
+
+	// Clock returns the time stored in the created, updated and soft
+	// delete columns. time.Now is used when nil.
+	Clock func() time.Time
+
+	// unscoped is set on the copies returned by Unscoped, which see the
+	// soft deleted rows.
+	unscoped bool
+
+	// replicas, set with SetReplicas, run the reads.
+	replicas *replicaSet
 }
 
 
@@ -39,7 +47,6 @@ func Open(driverName string,dataSourceName string)(*DbUtils,error)  {
 	if err!=nil {
 		return nil,err
 	}
-	//return &DbUtils{Db:db,reflectCache:make(map[reflect.Type]*cacheStruct)},nil
 	return &DbUtils{Db:db},nil
 
 }
@@ -51,6 +58,25 @@ func (dbUtils *DbUtils) WithContext(ctx context.Context) SqlQueryRunner {
 	return copy
 }
 
+// Unscoped returns a copy of the DbUtils whose Get, From and Preload
+// include the rows of tables with a soft delete column that are marked
+// deleted.
+func (dbUtils *DbUtils) Unscoped() *DbUtils {
+	copy := &DbUtils{}
+	*copy = *dbUtils
+	copy.unscoped = true
+	return copy
+}
+
+// now returns the time stored in the created, updated and soft delete
+// columns.
+func (dbUtils *DbUtils) now() time.Time {
+	if dbUtils.Clock != nil {
+		return dbUtils.Clock()
+	}
+	return time.Now()
+}
+
 func (dbUtils *DbUtils) Get(i interface{}, keys ...interface{}) (interface{}, error) {
 	return get(dbUtils, dbUtils, i, keys...)
 }
@@ -60,12 +86,46 @@ func (dbUtils *DbUtils) Insert(list ...interface{}) error {
 	return insert(dbUtils, dbUtils, list...)
 }
 
+// InsertBatch inserts the elements of list, a slice of struct pointers or
+// of structs, with multi-row insert statements of at most batchSize rows.
+// Generated keys are stored back into the structs when the dialect can
+// report them for a multi-row insert; otherwise, and for dialects that do
+// not implement BatchInserter, the rows are inserted one by one.
+func (dbUtils *DbUtils) InsertBatch(list interface{}, batchSize int) error {
+	return insertBatch(dbUtils, dbUtils, list, batchSize)
+}
+
+// Upsert inserts each element of list, or updates the existing row with
+// the same primary key. An autoincrement primary key is inserted with the
+// value of the struct, which cannot be zero: use UpsertWithOptions with a
+// Constraint for new rows, and with dialects that are GeneratedKeyUpserters.
+// The insert and update hooks are not run. Tables with a version column are
+// rejected, as the version of the updated row cannot be checked: use
+// UpsertOptions.DoNothing, or Insert and Update.
+func (dbUtils *DbUtils) Upsert(list ...interface{}) error {
+	return upsert(dbUtils, dbUtils, UpsertOptions{}, list...)
+}
+
+// UpsertWithOptions is the same as Upsert, with opts choosing the unique
+// constraint detecting existing rows and the fields updated in them. An
+// autoincrement key outside the constraint is left to the database, and set
+// in the struct for dialects that are UpsertKeyReturners.
+func (dbUtils *DbUtils) UpsertWithOptions(opts UpsertOptions, list ...interface{}) error {
+	return upsert(dbUtils, dbUtils, opts, list...)
+}
+
 func (dbUtils *DbUtils) Update(list ...interface{}) (int64, error) {
 	return update(dbUtils, dbUtils, list...)
 }
 
 func (dbUtils *DbUtils) Delete(list ...interface{}) (int64, error) {
-	return delete(dbUtils, dbUtils, list...)
+	return delete(dbUtils, dbUtils, false, list...)
+}
+
+// HardDelete deletes the rows of list like Delete, even from the tables
+// with a soft delete column.
+func (dbUtils *DbUtils) HardDelete(list ...interface{}) (int64, error) {
+	return delete(dbUtils, dbUtils, true, list...)
 }
 
 
@@ -74,6 +134,25 @@ func (dbUtils *DbUtils) Select(i interface{}, query string, args ...interface{})
 }
 
 
+// SelectIter runs query and returns a cursor over its rows, scanned into
+// values of the type of holderType, a struct or the type of a single
+// column.
+func (dbUtils *DbUtils) SelectIter(holderType interface{}, query string, args ...interface{}) (*RowIter, error) {
+	return selectIter(dbUtils, dbUtils, holderType, query, args...)
+}
+
+// QueryMap runs query and returns its rows, to be scanned into maps,
+// slices or several structs.
+func (dbUtils *DbUtils) QueryMap(query string, args ...interface{}) (*RowsMap, error) {
+	return queryMap(dbUtils, dbUtils, query, args...)
+}
+
+// SelectMaps runs query and returns each row as a map from column names
+// to values, normalized as by RowsMap.ScanMap.
+func (dbUtils *DbUtils) SelectMaps(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return selectMaps(dbUtils, dbUtils, query, args...)
+}
+
 func (dbUtils *DbUtils) Exec(query string, args ...interface{}) (sql.Result, error) {
 	return maybeExpandNamedQueryAndExec(dbUtils,query,args...)
 }
@@ -114,8 +193,6 @@ func (dbUtils *DbUtils) SelectOne(holder interface{}, query string, args ...inte
 }
 
 func (dbUtils *DbUtils) QueryRow(query string, args ...interface{}) *sql.Row {
-
-	fmt.Println("queryRow")
 	return queryRow(dbUtils,query,args...)
 }
 
@@ -143,6 +220,7 @@ func (dbUtils *DbUtils) AddTableWithNameAndSchema(i interface{}, schema string,
 		table := dbUtils.tables[i]
 		if table.gotype == t {
 			table.TableName = name
+			table.ResetSql()
 			return table
 		}
 	}
@@ -155,6 +233,21 @@ func (dbUtils *DbUtils) AddTableWithNameAndSchema(i interface{}, schema string,
 	if len(primaryKey) > 0 {
 		tmap.keys = append(tmap.keys, primaryKey...)
 	}
+	for _, col := range tmap.Columns {
+		col.table = tmap
+		if col.isVersion {
+			tmap.SetVersionCol(col.fieldName)
+		}
+		if col.isSoftDelete {
+			tmap.SetSoftDelete(col.fieldName)
+		}
+		if col.isCreated || col.isUpdated {
+			f := t.FieldByIndex(col.fieldIndex)
+			if _, ok := timeValue(f.Type, time.Time{}); !ok {
+				panic(fmt.Sprintf("godb: created or updated field %s must be a time, not %v", col.fieldName, f.Type))
+			}
+		}
+	}
 
 	return tmap
 }
@@ -169,12 +262,27 @@ func (dbUtils *DbUtils) readStructColumns(t reflect.Type) (cols []*ColumnMap, pr
 		if f.Anonymous && f.Type.Kind() == reflect.Struct {
 
 			subcols, subpk := dbUtils.readStructColumns(f.Type)
+			prefix := embeddedPrefix(f)
+			for _, subcol := range subcols {
+				subcol.fieldIndex = append([]int{i}, subcol.fieldIndex...)
+				// Fields of a prefixed embedded struct are not promoted
+				// over the fields of another prefixed one, so they are
+				// named by their path.
+				if prefix != "" {
+					subcol.fieldName = f.Name + "." + subcol.fieldName
+					if !subcol.Transient {
+						subcol.ColumnName = prefix + subcol.ColumnName
+					}
+				}
+			}
 			// Don't append nested fields that have the same field
-			// name as an already-mapped field.
+			// name, or once prefixed the same column name, as an
+			// already-mapped field.
 			for _, subcol := range subcols {
 				shouldAppend := true
 				for _, col := range cols {
-					if !subcol.Transient && subcol.fieldName == col.fieldName {
+					if !subcol.Transient && (subcol.fieldName == col.fieldName ||
+						prefix != "" && !col.Transient && subcol.ColumnName == col.ColumnName) {
 						shouldAppend = false
 						break
 					}
@@ -194,6 +302,10 @@ func (dbUtils *DbUtils) readStructColumns(t reflect.Type) (cols []*ColumnMap, pr
 			var isAuto bool
 			var isPK bool
 			var isNotNull bool
+			var isVersion bool
+			var isSoftDelete bool
+			var isCreated bool
+			var isUpdated bool
 			for _, argString := range cArguments[1:] {
 				argString = strings.TrimSpace(argString)
 				arg := strings.SplitN(argString, ":", 2)
@@ -223,6 +335,14 @@ func (dbUtils *DbUtils) readStructColumns(t reflect.Type) (cols []*ColumnMap, pr
 					isAuto = true
 				case "notnull":
 					isNotNull = true
+				case "version":
+					isVersion = true
+				case "softdelete":
+					isSoftDelete = true
+				case "created":
+					isCreated = true
+				case "updated":
+					isUpdated = true
 				default:
 					panic(fmt.Sprintf("Unrecognized tag option for field %v: %v", f.Name, arg))
 				}
@@ -263,10 +383,15 @@ func (dbUtils *DbUtils) readStructColumns(t reflect.Type) (cols []*ColumnMap, pr
 				DefaultValue: defaultValue,
 				Transient:    columnName == "-",
 				fieldName:    f.Name,
+				fieldIndex:   []int{i},
 				gotype:       gotype,
 				isPK:         isPK,
 				isAutoIncr:   isAuto,
 				isNotNull:    isNotNull,
+				isVersion:    isVersion,
+				isSoftDelete: isSoftDelete,
+				isCreated:    isCreated,
+				isUpdated:    isUpdated,
 				MaxSize:      maxSize,
 			}
 			if isPK {
@@ -305,6 +430,9 @@ func (dbUtils *DbUtils) createTables(ifNotExists bool) error {
 		table := dbUtils.tables[i]
 		sql := table.CreateTableSql(ifNotExists)
 		_, err = dbUtils.Exec(sql)
+		if classifier, ok := dbUtils.Dialect.(ExistsErrorClassifier); ok && ifNotExists && err != nil && classifier.IsAlreadyExists(err) {
+			err = nil
+		}
 		if err != nil {
 			return err
 		}
@@ -352,8 +480,111 @@ func (dbUtils *DbUtils) dropTableImpl(table *TableMap, ifExists bool) (err error
 		tableDrop = dbUtils.Dialect.IfTableExists(tableDrop, table.SchemaName, table.TableName)
 	}
 	_, err = dbUtils.Exec(fmt.Sprintf("%s %s;", tableDrop, dbUtils.Dialect.QuotedTableForQuery(table.SchemaName, table.TableName)))
+	if classifier, ok := dbUtils.Dialect.(ExistsErrorClassifier); ok && ifExists && err != nil && classifier.IsUndefined(err) {
+		return nil
+	}
 	return err
 }
+
+// CreateIndex creates the indexes added with AddIndex to the registered
+// tables.
+func (dbUtils *DbUtils) CreateIndex() error {
+	for _, table := range dbUtils.tables {
+		if err := table.CreateIndex(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateIndexesIfNotExists is the same as CreateIndex, but skips the
+// indexes found in the database by the SchemaInspector of the dialect.
+func (dbUtils *DbUtils) CreateIndexesIfNotExists() error {
+	inspector, ok := dbUtils.Dialect.(SchemaInspector)
+	if !ok {
+		return fmt.Errorf("godb: dialect %T does not support schema inspection", dbUtils.Dialect)
+	}
+
+	schemas := make(map[string]*Schema)
+	for _, table := range dbUtils.tables {
+		if len(table.indexes) == 0 {
+			continue
+		}
+		schema, ok := schemas[table.SchemaName]
+		if !ok {
+			var err error
+			schema, err = inspector.InspectSchema(dbUtils, table.SchemaName)
+			if err != nil {
+				return err
+			}
+			schemas[table.SchemaName] = schema
+		}
+
+		actual := schema.Table(table.TableName)
+		for _, idx := range table.indexes {
+			if actual != nil && actual.Index(idx.IndexName) != nil {
+				continue
+			}
+			if _, err := dbUtils.Exec(table.createIndexSql(idx)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DropIndex drops the index with the given name from the registered table
+// it was added to. TableMap.DropIndex must be used when several tables
+// have an index with that name.
+func (dbUtils *DbUtils) DropIndex(name string) error {
+	var found *TableMap
+	for _, table := range dbUtils.tables {
+		if table.IdxMap(name) == nil {
+			continue
+		}
+		if found != nil {
+			return fmt.Errorf("godb: tables %s and %s both have an index %s", found.TableName, table.TableName, name)
+		}
+		found = table
+	}
+	if found == nil {
+		return fmt.Errorf("godb: no index %s in the registered tables", name)
+	}
+	return found.DropIndex(name)
+}
+
+// TruncateTables empties all the registered tables, keeping their
+// autoincrement counters where the dialect allows it.
+func (dbUtils *DbUtils) TruncateTables() error {
+	return dbUtils.TruncateTablesWithOptions(TruncateOptions{})
+}
+
+// TruncateTablesWithOptions empties all the registered tables in a single
+// transaction. When the dialect is a SchemaInspector, tables are emptied
+// before the tables they reference.
+func (dbUtils *DbUtils) TruncateTablesWithOptions(opts TruncateOptions) error {
+	return truncate(dbUtils, dbUtils.tables, opts)
+}
+
+// TruncateTable empties the table registered for the type of i, which may
+// be a struct or a pointer to one.
+func (dbUtils *DbUtils) TruncateTable(i interface{}) error {
+	return dbUtils.TruncateTableWithOptions(i, TruncateOptions{})
+}
+
+// TruncateTableWithOptions is the same as TruncateTable, with the options
+// of TruncateTablesWithOptions.
+func (dbUtils *DbUtils) TruncateTableWithOptions(i interface{}, opts TruncateOptions) error {
+	t, err := toType(i)
+	if err != nil {
+		return err
+	}
+	table, err := dbUtils.TableFor(t, false)
+	if err != nil {
+		return err
+	}
+	return truncate(dbUtils, []*TableMap{table}, opts)
+}
 func tableOrNil(dbUtils *DbUtils, t reflect.Type, name string) *TableMap {
 
 	if name!=""{
@@ -392,9 +623,6 @@ func (dbUtils *DbUtils) TableFor(t reflect.Type, checkPK bool) (*TableMap, error
 func (dbUtils *DbUtils) tableForPointer(ptr interface{}, checkPK bool) (*TableMap, reflect.Value, error) {
 
 	ptrv := reflect.ValueOf(ptr)
-
-	fmt.Println(ptrv.Kind())
-
 	if ptrv.Kind() != reflect.Ptr {
 		e := fmt.Sprintf("godb: passed non-pointer: %v (kind=%v)", ptr,
 			ptrv.Kind())
@@ -415,46 +643,55 @@ func (dbUtils *DbUtils) tableForPointer(ptr interface{}, checkPK bool) (*TableMa
 
 func (dbUtils *DbUtils) Begin() (*Transaction, error) {
 
-	tx, err := begin(dbUtils)
+	return dbUtils.BeginWithOptions(nil)
+}
+
+// BeginWithOptions starts a transaction with the isolation level and read
+// only mode of opts, which may be nil for the defaults of the database.
+// Dialects that are IsolationLevelers set them with a statement run first in
+// the transaction instead of through the driver.
+func (dbUtils *DbUtils) BeginWithOptions(opts *sql.TxOptions) (*Transaction, error) {
+	var (
+		setup string
+		level = sql.LevelDefault
+	)
+	if opts != nil {
+		level = opts.Isolation
+		if leveler, ok := dbUtils.Dialect.(IsolationLeveler); ok {
+			var err error
+			setup, level, err = leveler.IsolationSql(*opts)
+			if err != nil {
+				return nil, err
+			}
+			if setup != "" {
+				opts = nil
+			}
+		}
+	}
+
+	tx, err := begin(dbUtils, opts)
 	if err != nil {
 		return nil, err
 	}
-	return &Transaction{
+	trans := &Transaction{
+		ctx:      dbUtils.ctx,
+		id:       nextTxID(),
 		dbUtils:  dbUtils,
 		tx:       tx,
 		closed:   false,
-	}, nil
+		savepoints: &savepointStack{},
+		isolation: level,
+	}
+	if setup != "" {
+		if _, err := trans.Exec(setup); err != nil {
+			trans.Rollback()
+			return nil, err
+		}
+	}
+	return trans, nil
 }
 
 func (dbUtils *DbUtils) Prepare(query string) (*sql.Stmt, error) {
 
 	return prepare(dbUtils, query)
 }
-
-
-/*
-func (dbUtils *DbUtils) reflectNew(typ reflect.Type) reflect.Value {
-	dbUtils.reflectCacheMutex.Lock()
-	defer dbUtils.reflectCacheMutex.Unlock()
-	cs, ok := dbUtils.reflectCache[typ]
-	if !ok || cs.idx+1 > DefaultCacheSize-1 {
-		cs = &cacheStruct{reflect.MakeSlice(reflect.SliceOf(typ), DefaultCacheSize, DefaultCacheSize), 0}
-		dbUtils.reflectCache[typ] = cs
-	} else {
-		cs.idx = cs.idx + 1
-	}
-	return cs.value.Index(cs.idx).Addr()
-}*/
-/*
-func (dbUtils *DbUtils) Query(query string, args ...interface{}) (*RowsMap, error) {
-	//rows, err := db.DB.Query(query, args...)
-	rows,err:=dbUtils.Db.Query(query)
-	if err != nil {
-		if rows != nil {
-			rows.Close()
-		}
-		return nil, err
-	}
-	return &RowsMap{rows, dbUtils}, nil
-}
-*/
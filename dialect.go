@@ -52,7 +52,8 @@ type Dialect interface {
 	// table - The table name
 	QuotedTableForQuery(schema string, table string) string
 
-	// Existence clause for table creation / deletion
+	// Existence clause for table creation / deletion. IfSchemaNotExists
+	// returns "" for dialects creating the schema of a table implicitly.
 	IfSchemaNotExists(command, schema string) string
 	IfTableExists(command, schema, table string) string
 	IfTableNotExists(command, schema, table string) string
@@ -80,13 +81,64 @@ type TargetQueryInserter interface {
 	// TargetQueryInserter runs an insert operation and assigns the
 	// automatically generated primary key retrived by the query
 	// extracted from the GeneratedIdQuery field of the id column.
+	// exec runs its reads on the primary, not on a replica.
 	InsertQueryToTarget(exec SqlQueryRunner, insertSql, idSql string, target interface{}, params ...interface{}) error
 }
 
+// AutoIncrInsertPrefixer is implemented by dialects that wrap the insert
+// statements of tables with an autoincrement column, like DB2 selecting the
+// generated key "from final table (insert ...)". AutoIncrInsertSuffix then
+// closes the wrapping.
+type AutoIncrInsertPrefixer interface {
+	AutoIncrInsertPrefix(col *ColumnMap) string
+}
+
+// ExistsErrorClassifier is implemented by dialects without "if exists"
+// clauses, whose IfTableExists and IfTableNotExists return the command
+// unchanged. CreateTablesIfNotExists and DropTablesIfExists ignore the
+// errors of existing and missing tables instead.
+type ExistsErrorClassifier interface {
+	// IsAlreadyExists reports whether err was caused by creating an
+	// object that already exists.
+	IsAlreadyExists(err error) bool
+
+	// IsUndefined reports whether err was caused by an object that does
+	// not exist.
+	IsUndefined(err error) bool
+}
+
 func standardInsertAutoIncr(exec SqlQueryRunner, insertSql string, params ...interface{}) (int64, error) {
 	res, err := exec.Exec(insertSql, params...)
 	if err != nil {
 		return 0, err
 	}
 	return res.LastInsertId()
-}
\ No newline at end of file
+}
+// BatchInserter is implemented by dialects that can insert several rows
+// with a single multi-row "insert ... values (...),(...)" statement.
+type BatchInserter interface {
+	// MaxBindVars returns the maximum number of bind variables allowed
+	// in a single statement.
+	MaxBindVars() int
+
+	// MaxBatchRows returns the maximum number of rows allowed in a single
+	// values clause, or 0 if there is no limit.
+	MaxBatchRows() int
+}
+
+// IntegerBatchAutoIncrInserter is implemented by dialects that can derive
+// the integer keys generated by a multi-row insert from the statement result.
+type IntegerBatchAutoIncrInserter interface {
+	// InsertAutoIncrBatch runs a multi-row insert of the given number of
+	// rows and returns the generated keys in the order of the rows.
+	InsertAutoIncrBatch(exec SqlQueryRunner, insertSql string, rows int, params ...interface{}) ([]int64, error)
+}
+
+// TargetedBatchAutoIncrInserter is implemented by dialects that return the
+// keys generated by a multi-row insert as a result set, like
+// TargetedAutoIncrInserter does for single rows.
+type TargetedBatchAutoIncrInserter interface {
+	// InsertAutoIncrBatchToTargets runs a multi-row insert and scans the
+	// generated key of each row into the matching target.
+	InsertAutoIncrBatchToTargets(exec SqlQueryRunner, insertSql string, targets []interface{}, params ...interface{}) error
+}
@@ -0,0 +1,361 @@
+package godb
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Db2Dialect targets IBM Db2 11.5 for Linux, Unix and Windows, and the
+// mainframe databases reached through Db2 Connect. DB2 folds unquoted
+// identifiers to upper case, so quoted ones are folded too.
+type Db2Dialect struct{}
+
+func (d Db2Dialect) QuerySuffix() string { return "" }
+
+// Booleans are smallints, as Db2 for z/OS has no boolean type.
+func (d Db2Dialect) ToSqlType(val reflect.Type, maxsize int, isAutoIncr bool) string {
+	switch val.Kind() {
+	case reflect.Ptr:
+		return d.ToSqlType(val.Elem(), maxsize, isAutoIncr)
+	case reflect.Bool:
+		return "smallint"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "smallint"
+	case reflect.Int, reflect.Int32, reflect.Uint16:
+		return "integer"
+	case reflect.Int64, reflect.Uint32:
+		return "bigint"
+	case reflect.Uint, reflect.Uint64:
+		return "decimal(20,0)"
+	case reflect.Float32:
+		return "real"
+	case reflect.Float64:
+		return "double"
+	case reflect.Slice:
+		if val.Elem().Kind() == reflect.Uint8 {
+			return "blob"
+		}
+	}
+
+	switch val.Name() {
+	case "NullInt64":
+		return "bigint"
+	case "NullFloat64":
+		return "double"
+	case "NullBool":
+		return "smallint"
+	case "NullTime", "Time":
+		return "timestamp"
+	}
+
+	if maxsize < 1 {
+		maxsize = 255
+	}
+	return fmt.Sprintf("varchar(%d)", maxsize)
+}
+
+func (d Db2Dialect) AutoIncrStr() string {
+	return "generated always as identity"
+}
+
+func (d Db2Dialect) AutoIncrBindValue() string {
+	return "default"
+}
+
+// Returns "select <col> from final table (", the insert being the data
+// change table reference.
+func (d Db2Dialect) AutoIncrInsertPrefix(col *ColumnMap) string {
+	return "select " + d.QuoteField(col.ColumnName) + " from final table ("
+}
+
+// The generated keys are returned in the order of the inserted rows.
+func (d Db2Dialect) AutoIncrInsertSuffix(col *ColumnMap) string {
+	return ") order by input sequence"
+}
+
+func (d Db2Dialect) CreateTableSuffix() string { return "" }
+
+func (d Db2Dialect) CreateIndexSuffix() string { return "" }
+
+func (d Db2Dialect) DropIndexSuffix() string { return "" }
+
+// Indexes are created in the schema of their table. DB2 chooses the index
+// type itself.
+func (d Db2Dialect) CreateIndexSql(schema, table string, index *IndexMap, columns []string) string {
+	s := "create"
+	if index.Unique {
+		s += " unique"
+	}
+	return fmt.Sprintf("%s index %s on %s (%s)", s, d.QuotedTableForQuery(schema, index.IndexName),
+		d.QuotedTableForQuery(schema, table), quoteFields(d, columns, ""))
+}
+
+func (d Db2Dialect) DropIndexSql(schema, table, index string) string {
+	return "drop index " + d.QuotedTableForQuery(schema, index)
+}
+
+// DB2 requires "immediate" after the table name of a "truncate table", and
+// only runs it as the first statement of a transaction: rows are deleted
+// instead, as by Truncate.
+func (d Db2Dialect) TruncateClause() string {
+	return "delete from"
+}
+
+// Rows are deleted, as DB2 cannot truncate a table referenced by a foreign
+// key, and identities are restarted with "alter column ... restart".
+func (d Db2Dialect) Truncate(exec SqlQueryRunner, tables []QualifiedTable, opts TruncateOptions) error {
+	for _, t := range tables {
+		table := d.QuotedTableForQuery(t.Schema, t.Name)
+		if _, err := exec.Exec("delete from " + table); err != nil {
+			return err
+		}
+		if !opts.RestartIdentity {
+			continue
+		}
+
+		column, err := SelectNullStr(exec, "select colname from syscat.columns where tabschema = "+db2CurrentSchema+" and tabname = ? and identity = 'Y'",
+			strings.ToUpper(t.Schema), strings.ToUpper(t.Name))
+		if err != nil {
+			return err
+		}
+		if !column.Valid {
+			continue
+		}
+		_, err = exec.Exec(fmt.Sprintf("alter table %s alter column %s restart", table, d.QuoteField(column.String)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns "offset m rows fetch first n rows only"
+func (d Db2Dialect) LimitClause(limit, offset int, ordered bool) string {
+	s := ""
+	if offset > 0 {
+		s += fmt.Sprintf(" offset %d rows", offset)
+	}
+	if limit >= 0 {
+		s += fmt.Sprintf(" fetch first %d rows only", limit)
+	}
+	return s
+}
+
+func (d Db2Dialect) BindVar(i int) string {
+	return "?"
+}
+
+// Scans the key selected from the final table of the insert.
+func (d Db2Dialect) InsertAutoIncrToTarget(exec SqlQueryRunner, insertSql string, target interface{}, params ...interface{}) error {
+	return d.InsertAutoIncrBatchToTargets(exec, insertSql, []interface{}{target}, params...)
+}
+
+// Returns 32767, the maximum number of parameter markers in a statement
+func (d Db2Dialect) MaxBindVars() int {
+	return 32767
+}
+
+func (d Db2Dialect) MaxBatchRows() int {
+	return 0
+}
+
+func (d Db2Dialect) InsertAutoIncrBatchToTargets(exec SqlQueryRunner, insertSql string, targets []interface{}, params ...interface{}) error {
+	rows, err := exec.Query(insertSql, params...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for _, target := range targets {
+		if !rows.Next() {
+			return fmt.Errorf("Not enough identity values returned for insert: %s Encountered error: %s", insertSql, rows.Err())
+		}
+		if err := rows.Scan(target); err != nil {
+			return err
+		}
+	}
+	if rows.Next() {
+		return fmt.Errorf("more identity values than rows returned for insert: %s", insertSql)
+	}
+	return rows.Err()
+}
+
+// TransactionalDDL is true: schema changes can be rolled back.
+func (d Db2Dialect) TransactionalDDL() bool {
+	return true
+}
+
+// GeneratedKeys is true: columns generated always reject explicit values.
+func (d Db2Dialect) GeneratedKeys() bool {
+	return true
+}
+
+// Returns a "merge" statement using a values row as source
+func (d Db2Dialect) UpsertSql(schema, table string, columns, values, conflict, update []string) string {
+	target := d.QuotedTableForQuery(schema, table) + " t"
+	source := fmt.Sprintf("using (values (%s)) as s (%s)", strings.Join(values, ","), quoteFields(d, columns, ""))
+	return mergeUpsertSql(d, target, source, columns, conflict, update) + d.QuerySuffix()
+}
+
+const db2CurrentSchema = "coalesce(nullif(cast(? as varchar(128)), ''), current schema)"
+
+var db2Catalog = catalogQueries{
+	columns: "select c.tabschema, c.tabname, c.colname, " +
+		"case when c.typename in ('VARCHAR', 'CHARACTER', 'VARGRAPHIC', 'GRAPHIC', 'VARBINARY', 'BINARY') " +
+		"then c.typename || '(' || cast(c.length as varchar(10)) || ')' " +
+		"when c.typename = 'DECIMAL' then c.typename || '(' || cast(c.length as varchar(10)) || ',' || cast(c.scale as varchar(10)) || ')' " +
+		"else c.typename end, " +
+		"case when c.nulls = 'Y' then 1 else 0 end " +
+		"from syscat.columns c join syscat.tables t on t.tabschema = c.tabschema and t.tabname = c.tabname " +
+		"where t.type = 'T' and c.tabschema = " + db2CurrentSchema + " " +
+		"order by c.tabname, c.colno",
+	primaryKeys: "select k.tabschema, k.tabname, k.colname " +
+		"from syscat.tabconst tc join syscat.keycoluse k on k.tabschema = tc.tabschema and k.tabname = tc.tabname and k.constname = tc.constname " +
+		"where tc.type = 'P' and tc.tabschema = " + db2CurrentSchema + " " +
+		"order by k.tabname, k.colseq",
+	indexes: "select i.tabschema, i.tabname, i.indname, case when i.uniquerule = 'U' then 1 else 0 end, lower(i.indextype), ic.colname " +
+		"from syscat.indexes i join syscat.indexcoluse ic on ic.indschema = i.indschema and ic.indname = i.indname " +
+		"where i.uniquerule <> 'P' and i.tabschema = " + db2CurrentSchema + " " +
+		"order by i.tabname, i.indname, ic.colseq",
+	foreignKeys: "select r.tabschema, r.tabname, r.constname, k.colname, r.reftabschema, r.reftabname, rk.colname " +
+		"from syscat.references r " +
+		"join syscat.keycoluse k on k.tabschema = r.tabschema and k.tabname = r.tabname and k.constname = r.constname " +
+		"join syscat.keycoluse rk on rk.tabschema = r.reftabschema and rk.tabname = r.reftabname and rk.constname = r.refkeyname and rk.colseq = k.colseq " +
+		"where r.tabschema = " + db2CurrentSchema + " " +
+		"order by r.tabname, r.constname, k.colseq",
+}
+
+// InspectSchema reads the schema from the syscat views.
+func (d Db2Dialect) InspectSchema(queryRunner SqlQueryRunner, schema string) (*Schema, error) {
+	return inspectCatalog(queryRunner, db2Catalog, strings.ToUpper(schema))
+}
+
+// Maps the alternative type names to the ones stored in syscat.columns.
+func (d Db2Dialect) NormalizeSqlType(sqlType string) string {
+	t := normalizeSqlType(sqlType, map[string]string{
+		"int":              "integer",
+		"double precision": "double",
+		"float":            "double",
+		"dec(20,0)":        "decimal(20,0)",
+	})
+	if strings.HasPrefix(t, "char(") {
+		return "character(" + strings.TrimPrefix(t, "char(")
+	}
+	return t
+}
+
+func (d Db2Dialect) AddColumnSql(schema, table string, col *ColumnMap) string {
+	return standardAddColumnSql(d, schema, table, col)
+}
+
+// Dropping a column leaves the table in reorg pending state until
+// "reorg table" is run.
+func (d Db2Dialect) DropColumnSql(schema, table, column string) string {
+	return standardDropColumnSql(d, schema, table, column)
+}
+
+func (d Db2Dialect) AlterColumnSql(schema, table string, diff *ColumnDiff) []string {
+	col := diff.Column
+	prefix := fmt.Sprintf("alter table %s alter column %s ", d.QuotedTableForQuery(schema, table), d.QuoteField(col.ColumnName))
+	var stmts []string
+	if diff.TypeChanged {
+		stmts = append(stmts, prefix+"set data type "+d.ToSqlType(col.gotype, col.MaxSize, false))
+	}
+	if diff.NullableChanged && (col.isPK || col.isNotNull) {
+		stmts = append(stmts, prefix+"set not null")
+	} else if diff.NullableChanged {
+		stmts = append(stmts, prefix+"drop not null")
+	}
+	return stmts
+}
+
+func (d Db2Dialect) QuoteField(f string) string {
+	return `"` + strings.ToUpper(f) + `"`
+}
+
+func (d Db2Dialect) QuotedTableForQuery(schema string, table string) string {
+	if strings.TrimSpace(schema) == "" {
+		return d.QuoteField(table)
+	}
+	return d.QuoteField(schema) + "." + d.QuoteField(table)
+}
+
+// DB2 creates the schema of a table implicitly, and Db2 for z/OS has no
+// "create schema" statement.
+func (d Db2Dialect) IfSchemaNotExists(command, schema string) string {
+	return ""
+}
+
+// Db2 for z/OS and Db2 for LUW before 11.5 have no "if exists" clause, the
+// errors of missing tables are ignored instead.
+func (d Db2Dialect) IfTableExists(command, schema, table string) string {
+	return command
+}
+
+func (d Db2Dialect) IfTableNotExists(command, schema, table string) string {
+	return command
+}
+
+// IsAlreadyExists reports the errors with SQLSTATE 42710, given by the
+// driver error or in its message.
+func (d Db2Dialect) IsAlreadyExists(err error) bool {
+	return db2HasSQLState(err, "42710")
+}
+
+// IsUndefined reports the errors with SQLSTATE 42704.
+func (d Db2Dialect) IsUndefined(err error) bool {
+	return db2HasSQLState(err, "42704")
+}
+
+// IsRetryable reports the transactions rolled back by a deadlock or a
+// timeout (SQLSTATE 40001, SQLCODE -911) and the statements failing on one
+// (SQLSTATE 57033, SQLCODE -913).
+func (d Db2Dialect) IsRetryable(err error) bool {
+	return db2HasSQLState(err, "40001") || db2HasSQLState(err, "57033")
+}
+
+// The drivers set the isolation levels of the standard, which DB2 calls
+// uncommitted read, cursor stability, read stability and repeatable read.
+func (d Db2Dialect) IsolationSql(opts sql.TxOptions) (string, sql.IsolationLevel, error) {
+	switch opts.Isolation {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted,
+		sql.LevelRepeatableRead, sql.LevelSerializable:
+		return "", opts.Isolation, nil
+	}
+	return "", 0, fmt.Errorf("godb: DB2 does not support isolation level %s", opts.Isolation)
+}
+
+// db2HasSQLState reports whether err has the SQLSTATE state. The messages
+// of the DB2 drivers give it as "{42704}" or "SQLSTATE=42704".
+func db2HasSQLState(err error, state string) bool {
+	if err == nil {
+		return false
+	}
+	if s, ok := errorSQLState(err); ok {
+		return s == state
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "{"+state+"}") || strings.Contains(msg, "SQLSTATE="+state)
+}
+
+// DB2 requires the behavior of cursors on rollback.
+func (d Db2Dialect) SavepointSql(name string) string {
+	return "savepoint " + d.QuoteField(name) + " on rollback retain cursors"
+}
+
+func (d Db2Dialect) RollbackToSavepointSql(name string) string {
+	return "rollback to savepoint " + d.QuoteField(name)
+}
+
+func (d Db2Dialect) ReleaseSavepointSql(name string) string {
+	return "release savepoint " + d.QuoteField(name)
+}
+
+// DB2 sorts NULL values after the others, so they are put first
+// explicitly. Strings sort by the collation of the database, which must be
+// IDENTITY, the binary one, for the merge of sharded selects.
+func (d Db2Dialect) MergeOrderSql(column string, text, desc bool) string {
+	return column + mergeOrderSuffix(desc, true)
+}
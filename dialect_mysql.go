@@ -4,6 +4,8 @@ import (
 	"strings"
 	"fmt"
 	"reflect"
+	"regexp"
+	"strconv"
 	"time"
 )
 
@@ -122,14 +124,64 @@ func (d MySQLDialect) DropIndexSuffix() string {
 	return "on"
 }
 
+// The index type follows the columns in MySQL.
+func (d MySQLDialect) CreateIndexSql(schema, table string, index *IndexMap, columns []string) string {
+	s := "create"
+	if index.Unique {
+		s += " unique"
+	}
+	s += fmt.Sprintf(" index %s on %s (%s)", d.QuoteField(index.IndexName),
+		d.QuotedTableForQuery(schema, table), quoteFields(d, columns, ""))
+	if index.IndexType != "" {
+		s += " " + d.CreateIndexSuffix() + " " + indexMethod(index.IndexType)
+	}
+	return s + d.QuerySuffix()
+}
+
+func (d MySQLDialect) DropIndexSql(schema, table, index string) string {
+	return fmt.Sprintf("drop index %s %s %s%s", d.QuoteField(index), d.DropIndexSuffix(),
+		d.QuotedTableForQuery(schema, table), d.QuerySuffix())
+}
+
 func (d MySQLDialect) TruncateClause() string {
 	return "truncate"
 }
 
+// MySQL cannot truncate a table referenced by a foreign key, so the checks
+// are disabled for the session meanwhile. Truncating a table always resets
+// its autoincrement counter.
+func (d MySQLDialect) Truncate(exec SqlQueryRunner, tables []QualifiedTable, opts TruncateOptions) (err error) {
+	if _, err = exec.Exec("set foreign_key_checks = 0"); err != nil {
+		return err
+	}
+	defer func() {
+		if _, resetErr := exec.Exec("set foreign_key_checks = 1"); err == nil {
+			err = resetErr
+		}
+	}()
+	for _, t := range tables {
+		_, err = exec.Exec(fmt.Sprintf("%s %s%s", d.TruncateClause(),
+			d.QuotedTableForQuery(t.Schema, t.Name), d.QuerySuffix()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d MySQLDialect) SleepClause(s time.Duration) string {
 	return fmt.Sprintf("sleep(%f)", s.Seconds())
 }
 
+// MySQL has no offset without limit, so the largest row count is used
+// when only an offset is given.
+func (d MySQLDialect) LimitClause(limit, offset int, ordered bool) string {
+	if limit < 0 {
+		return fmt.Sprintf(" limit 18446744073709551615 offset %d", offset)
+	}
+	return standardLimitClause(limit, offset)
+}
+
 // Returns "?"
 func (d MySQLDialect) BindVar(i int) string {
 	return "?"
@@ -139,6 +191,112 @@ func (d MySQLDialect) InsertAutoIncr(queryRunner SqlQueryRunner, insertSql strin
 	return standardInsertAutoIncr(queryRunner, insertSql, params...)
 }
 
+// Returns 65535, the maximum number of placeholders of a prepared statement
+func (d MySQLDialect) MaxBindVars() int {
+	return 65535
+}
+
+func (d MySQLDialect) MaxBatchRows() int {
+	return 0
+}
+
+// Returns "insert ... on duplicate key update". MySQL checks every unique
+// key of the table, so conflict is only used to leave the row untouched.
+func (d MySQLDialect) UpsertSql(schema, table string, columns, values, conflict, update []string) string {
+	s := fmt.Sprintf("insert into %s (%s) values (%s) on duplicate key update ",
+		d.QuotedTableForQuery(schema, table), quoteFields(d, columns, ""), strings.Join(values, ","))
+	if len(update) == 0 {
+		return s + d.QuoteField(conflict[0]) + "=" + d.QuoteField(conflict[0]) + d.QuerySuffix()
+	}
+	for i, col := range update {
+		if i > 0 {
+			s += ", "
+		}
+		s += d.QuoteField(col) + "=values(" + d.QuoteField(col) + ")"
+	}
+	return s + d.QuerySuffix()
+}
+
+// Setting the key to last_insert_id(key) on update makes LastInsertId report
+// the key of the existing row too. It also leaves the row untouched when
+// there is nothing else to update.
+func (d MySQLDialect) UpsertKeySql(schema, table string, columns, values, conflict, update []string, key string) (string, bool) {
+	s := fmt.Sprintf("insert into %s (%s) values (%s) on duplicate key update %s=last_insert_id(%s)",
+		d.QuotedTableForQuery(schema, table), quoteFields(d, columns, ""), strings.Join(values, ","),
+		d.QuoteField(key), d.QuoteField(key))
+	for _, col := range update {
+		s += ", " + d.QuoteField(col) + "=values(" + d.QuoteField(col) + ")"
+	}
+	return s + d.QuerySuffix(), false
+}
+
+// MySQL reports the key of the first inserted row as LastInsertId. The keys
+// of the following rows are consecutive as long as innodb_autoinc_lock_mode
+// is 0 or 1 and auto_increment_increment is 1.
+func (d MySQLDialect) InsertAutoIncrBatch(exec SqlQueryRunner, insertSql string, rows int, params ...interface{}) ([]int64, error) {
+	first, err := standardInsertAutoIncr(exec, insertSql, params...)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, rows)
+	for i := range ids {
+		ids[i] = first + int64(i)
+	}
+	return ids, nil
+}
+
+var mysqlCatalog = catalogQueries{
+	columns: "select c.table_schema, c.table_name, c.column_name, c.column_type, case when c.is_nullable = 'YES' then 1 else 0 end " +
+		"from information_schema.columns c join information_schema.tables t on t.table_schema = c.table_schema and t.table_name = c.table_name " +
+		"where t.table_type = 'BASE TABLE' and c.table_schema = coalesce(nullif(?, ''), database()) " +
+		"order by c.table_name, c.ordinal_position",
+	primaryKeys: "select table_schema, table_name, column_name from information_schema.key_column_usage " +
+		"where constraint_name = 'PRIMARY' and table_schema = coalesce(nullif(?, ''), database()) " +
+		"order by table_name, ordinal_position",
+	indexes: "select table_schema, table_name, index_name, case when non_unique = 0 then 1 else 0 end, lower(index_type), column_name " +
+		"from information_schema.statistics where index_name <> 'PRIMARY' and table_schema = coalesce(nullif(?, ''), database()) " +
+		"order by table_name, index_name, seq_in_index",
+	foreignKeys: "select table_schema, table_name, constraint_name, column_name, referenced_table_schema, referenced_table_name, referenced_column_name " +
+		"from information_schema.key_column_usage where referenced_table_name is not null and table_schema = coalesce(nullif(?, ''), database()) " +
+		"order by table_name, constraint_name, ordinal_position",
+}
+
+// InspectSchema reads the schema from information_schema. The schema is
+// the database in MySQL.
+func (d MySQLDialect) InspectSchema(queryRunner SqlQueryRunner, schema string) (*Schema, error) {
+	return inspectCatalog(queryRunner, mysqlCatalog, schema)
+}
+
+var mysqlIntWidthRe = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+
+// Maps boolean to tinyint(1) and removes the display width of the other
+// integer types, which MySQL 8.0.19 no longer reports.
+func (d MySQLDialect) NormalizeSqlType(sqlType string) string {
+	t := normalizeSqlType(sqlType, map[string]string{
+		"boolean": "tinyint(1)",
+		"bool":    "tinyint(1)",
+		"integer": "int",
+	})
+	if t == "tinyint(1)" {
+		return t
+	}
+	return mysqlIntWidthRe.ReplaceAllString(t, "$1")
+}
+
+func (d MySQLDialect) AddColumnSql(schema, table string, col *ColumnMap) string {
+	return standardAddColumnSql(d, schema, table, col)
+}
+
+func (d MySQLDialect) DropColumnSql(schema, table, column string) string {
+	return standardDropColumnSql(d, schema, table, column)
+}
+
+func (d MySQLDialect) AlterColumnSql(schema, table string, diff *ColumnDiff) []string {
+	col := diff.Column
+	return []string{fmt.Sprintf("alter table %s modify column %s %s%s", d.QuotedTableForQuery(schema, table),
+		d.QuoteField(col.ColumnName), columnDefinition(d, col), d.QuerySuffix())}
+}
+
 func (d MySQLDialect) QuoteField(f string) string {
 	return "`" + f + "`"
 }
@@ -161,4 +319,60 @@ func (d MySQLDialect) IfTableExists(command, schema, table string) string {
 
 func (d MySQLDialect) IfTableNotExists(command, schema, table string) string {
 	return fmt.Sprintf("%s if not exists", command)
-}
\ No newline at end of file
+}
+
+// Parses the numbers and dates that the text protocol of MySQL returns as
+// bytes, which happens for queries without arguments or without parseTime
+// in the DSN.
+func (d MySQLDialect) NormalizeMapValue(typeName string, v interface{}) interface{} {
+	b, ok := v.([]byte)
+	if !ok {
+		return v
+	}
+	s := string(b)
+	switch strings.ToUpper(typeName) {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return u
+		}
+	case "UNSIGNED TINYINT", "UNSIGNED SMALLINT", "UNSIGNED MEDIUMINT", "UNSIGNED INT", "UNSIGNED BIGINT":
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return u
+		}
+	case "FLOAT", "DOUBLE":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case "DATETIME", "TIMESTAMP":
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999", s, time.UTC); err == nil {
+			return t
+		}
+	case "DATE":
+		if t, err := time.ParseInLocation("2006-01-02", s, time.UTC); err == nil {
+			return t
+		}
+	}
+	return standardNormalizeMapValue(typeName, v)
+}
+
+// IsRetryable reports deadlocks (error 1213), given as the Number of the
+// driver error or in its message.
+func (d MySQLDialect) IsRetryable(err error) bool {
+	if n, ok := errorNumber(err); ok {
+		return n == 1213
+	}
+	return err != nil && strings.HasPrefix(err.Error(), "Error 1213")
+}
+
+// Strings sort by their bytes once cast to binary, rather than by the
+// collation of the column, often case insensitive. NULL values come first
+// in ascending order.
+func (d MySQLDialect) MergeOrderSql(column string, text, desc bool) string {
+	if text {
+		column = "cast(" + column + " as binary)"
+	}
+	return column + mergeOrderSuffix(desc, false)
+}
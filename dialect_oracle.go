@@ -1,6 +1,7 @@
 package godb
 
 import (
+	"database/sql"
 	"reflect"
 	"fmt"
 	"strings"
@@ -14,6 +15,23 @@ func (d OracleDialect) CreateIndexSuffix() string { return "" }
 
 func (d OracleDialect) DropIndexSuffix() string { return "" }
 
+// The index type is "bitmap" or the default b-tree. Indexes are created in
+// the schema of their table.
+func (d OracleDialect) CreateIndexSql(schema, table string, index *IndexMap, columns []string) string {
+	s := "create"
+	if index.Unique {
+		s += " unique"
+	} else if indexMethod(index.IndexType) == "bitmap" {
+		s += " bitmap"
+	}
+	return fmt.Sprintf("%s index %s on %s (%s)", s, d.QuotedTableForQuery(schema, index.IndexName),
+		d.QuotedTableForQuery(schema, table), quoteFields(d, columns, ""))
+}
+
+func (d OracleDialect) DropIndexSql(schema, table, index string) string {
+	return "drop index " + d.QuotedTableForQuery(schema, index)
+}
+
 func (d OracleDialect) ToSqlType(val reflect.Type, maxsize int, isAutoIncr bool) string {
 	switch val.Kind() {
 	case reflect.Ptr:
@@ -81,6 +99,43 @@ func (d OracleDialect) TruncateClause() string {
 	return "truncate"
 }
 
+// Oracle cannot truncate a table referenced by an enabled foreign key, so
+// the rows are deleted instead. Identities are not restarted.
+func (d OracleDialect) Truncate(exec SqlQueryRunner, tables []QualifiedTable, opts TruncateOptions) error {
+	if opts.RestartIdentity {
+		return fmt.Errorf("godb: dialect %T cannot restart identities", d)
+	}
+	for _, t := range tables {
+		if _, err := exec.Exec("delete from " + d.QuotedTableForQuery(t.Schema, t.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns a "merge" statement selecting the new row from dual
+func (d OracleDialect) UpsertSql(schema, table string, columns, values, conflict, update []string) string {
+	fields := ""
+	for i, col := range columns {
+		if i > 0 {
+			fields += ", "
+		}
+		fields += values[i] + " " + d.QuoteField(col)
+	}
+	target := d.QuotedTableForQuery(schema, table) + " t"
+	source := fmt.Sprintf("using (select %s from dual) s", fields)
+	return mergeUpsertSql(d, target, source, columns, conflict, update) + d.QuerySuffix()
+}
+
+// Returns "offset m rows fetch next n rows only", available since Oracle 12c
+func (d OracleDialect) LimitClause(limit, offset int, ordered bool) string {
+	s := fmt.Sprintf(" offset %d rows", offset)
+	if limit >= 0 {
+		s += fmt.Sprintf(" fetch next %d rows only", limit)
+	}
+	return s
+}
+
 // Returns "$(i+1)"
 func (d OracleDialect) BindVar(i int) string {
 	return fmt.Sprintf(":%d", i+1)
@@ -109,6 +164,83 @@ func (d OracleDialect) InsertQueryToTarget(exec SqlQueryRunner, insertSql, idSql
 	return nil
 }
 
+var oracleCatalog = catalogQueries{
+	columns: "select c.owner, c.table_name, c.column_name, " +
+		"case when c.data_type in ('VARCHAR2', 'NVARCHAR2', 'CHAR', 'NCHAR') then c.data_type || '(' || c.char_length || ')' " +
+		"when c.data_type = 'NUMBER' and c.data_precision is not null then c.data_type || '(' || c.data_precision || " +
+		"case when c.data_scale > 0 then ',' || c.data_scale end || ')' " +
+		"when c.data_type = 'NUMBER' and c.data_scale = 0 then 'INTEGER' else c.data_type end, " +
+		"case when c.nullable = 'Y' then 1 else 0 end " +
+		"from all_tab_columns c join all_tables t on t.owner = c.owner and t.table_name = c.table_name " +
+		"where c.owner = nvl(:1, sys_context('USERENV', 'CURRENT_SCHEMA')) " +
+		"order by c.table_name, c.column_id",
+	primaryKeys: "select c.owner, c.table_name, cc.column_name " +
+		"from all_constraints c join all_cons_columns cc on cc.owner = c.owner and cc.constraint_name = c.constraint_name " +
+		"where c.constraint_type = 'P' and c.owner = nvl(:1, sys_context('USERENV', 'CURRENT_SCHEMA')) " +
+		"order by c.table_name, cc.position",
+	indexes: "select i.table_owner, i.table_name, i.index_name, case when i.uniqueness = 'UNIQUE' then 1 else 0 end, lower(i.index_type), ic.column_name " +
+		"from all_indexes i join all_ind_columns ic on ic.index_owner = i.owner and ic.index_name = i.index_name " +
+		"where i.table_owner = nvl(:1, sys_context('USERENV', 'CURRENT_SCHEMA')) " +
+		"and not exists (select 1 from all_constraints c where c.owner = i.owner and c.index_name = i.index_name and c.constraint_type = 'P') " +
+		"order by i.table_name, i.index_name, ic.column_position",
+	foreignKeys: "select c.owner, c.table_name, c.constraint_name, cc.column_name, r.owner, r.table_name, rc.column_name " +
+		"from all_constraints c join all_cons_columns cc on cc.owner = c.owner and cc.constraint_name = c.constraint_name " +
+		"join all_constraints r on r.owner = c.r_owner and r.constraint_name = c.r_constraint_name " +
+		"join all_cons_columns rc on rc.owner = r.owner and rc.constraint_name = r.constraint_name and rc.position = cc.position " +
+		"where c.constraint_type = 'R' and c.owner = nvl(:1, sys_context('USERENV', 'CURRENT_SCHEMA')) " +
+		"order by c.table_name, c.constraint_name, cc.position",
+}
+
+// InspectSchema reads the schema from the all_* catalog views. The schema
+// is the owner of the tables.
+func (d OracleDialect) InspectSchema(queryRunner SqlQueryRunner, schema string) (*Schema, error) {
+	return inspectCatalog(queryRunner, oracleCatalog, strings.ToUpper(schema))
+}
+
+// Maps the ANSI type names to the ones Oracle stores.
+func (d OracleDialect) NormalizeSqlType(sqlType string) string {
+	t := normalizeSqlType(sqlType, map[string]string{
+		"serial":           "integer",
+		"bigserial":        "integer",
+		"bigint":           "integer",
+		"smallint":         "integer",
+		"number(38)":       "integer",
+		"double precision": "float",
+		"float(126)":       "float",
+		"real":             "float",
+		"float(63)":        "float",
+		"text":             "clob",
+		"bytea":            "blob",
+	})
+	t = strings.Replace(t, "varchar2(", "varchar(", 1)
+	return strings.Replace(t, "timestamp(6) with time zone", "timestamp with time zone", 1)
+}
+
+func (d OracleDialect) AddColumnSql(schema, table string, col *ColumnMap) string {
+	return fmt.Sprintf("alter table %s add (%s %s)", d.QuotedTableForQuery(schema, table),
+		d.QuoteField(col.ColumnName), addColumnDefinition(d, col))
+}
+
+func (d OracleDialect) DropColumnSql(schema, table, column string) string {
+	return standardDropColumnSql(d, schema, table, column)
+}
+
+// Oracle rejects a "null" or "not null" matching the current nullability
+// of the column, so it is only given when it changes.
+func (d OracleDialect) AlterColumnSql(schema, table string, diff *ColumnDiff) []string {
+	col := diff.Column
+	s := fmt.Sprintf("alter table %s modify (%s", d.QuotedTableForQuery(schema, table), d.QuoteField(col.ColumnName))
+	if diff.TypeChanged {
+		s += " " + d.ToSqlType(col.gotype, col.MaxSize, false)
+	}
+	if diff.NullableChanged && (col.isPK || col.isNotNull) {
+		s += " not null"
+	} else if diff.NullableChanged {
+		s += " null"
+	}
+	return []string{s + ")"}
+}
+
 func (d OracleDialect) QuoteField(f string) string {
 	return `"` + strings.ToUpper(f) + `"`
 }
@@ -133,3 +265,62 @@ func (d OracleDialect) IfTableNotExists(command, schema, table string) string {
 	return fmt.Sprintf("%s if not exists", command)
 }
 
+
+// IsRetryable reports deadlocks (ORA-00060) and the serialization failures
+// of serializable transactions (ORA-08177), which the drivers only give in
+// the message.
+func (d OracleDialect) IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "ORA-00060") || strings.Contains(msg, "ORA-08177")
+}
+
+func (d OracleDialect) SavepointSql(name string) string {
+	return "savepoint " + d.QuoteField(name)
+}
+
+func (d OracleDialect) RollbackToSavepointSql(name string) string {
+	return "rollback to savepoint " + d.QuoteField(name)
+}
+
+// Oracle cannot release savepoints, they last until the end of the
+// transaction.
+func (d OracleDialect) ReleaseSavepointSql(name string) string {
+	return ""
+}
+
+// Oracle provides read committed and serializable, set with "set
+// transaction", which the drivers do not all issue. Weaker levels run as
+// read committed and the snapshot levels as serializable. Read only
+// transactions see the data committed when they start, as serializable
+// ones do.
+func (d OracleDialect) IsolationSql(opts sql.TxOptions) (string, sql.IsolationLevel, error) {
+	switch opts.Isolation {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted,
+		sql.LevelRepeatableRead, sql.LevelSnapshot, sql.LevelSerializable:
+	default:
+		return "", 0, fmt.Errorf("godb: Oracle does not support isolation level %s", opts.Isolation)
+	}
+
+	switch {
+	case opts.ReadOnly:
+		return "set transaction read only", sql.LevelSerializable, nil
+	case opts.Isolation == sql.LevelDefault:
+		return "", sql.LevelDefault, nil
+	case opts.Isolation <= sql.LevelReadCommitted:
+		return "set transaction isolation level read committed", sql.LevelReadCommitted, nil
+	}
+	return "set transaction isolation level serializable", sql.LevelSerializable, nil
+}
+
+// Strings sort by their bytes with the binary linguistic sort, whatever
+// the NLS_SORT of the session. Oracle sorts NULL values after the others,
+// so they are put first explicitly.
+func (d OracleDialect) MergeOrderSql(column string, text, desc bool) string {
+	if text {
+		column = "nlssort(" + column + ", 'NLS_SORT=BINARY')"
+	}
+	return column + mergeOrderSuffix(desc, true)
+}
@@ -85,10 +85,53 @@ func (d PostgresDialect) DropIndexSuffix() string {
 	return ""
 }
 
+// The index type precedes the columns in PostgreSQL.
+func (d PostgresDialect) CreateIndexSql(schema, table string, index *IndexMap, columns []string) string {
+	s := "create"
+	if index.Unique {
+		s += " unique"
+	}
+	s += fmt.Sprintf(" index %s on %s", d.QuoteField(index.IndexName), d.QuotedTableForQuery(schema, table))
+	if index.IndexType != "" {
+		s += " " + d.CreateIndexSuffix() + " " + indexMethod(index.IndexType)
+	}
+	return s + " (" + quoteFields(d, columns, "") + ")" + d.QuerySuffix()
+}
+
+// Indexes belong to the schema of their table.
+func (d PostgresDialect) DropIndexSql(schema, table, index string) string {
+	return "drop index " + d.QuotedTableForQuery(schema, index) + d.QuerySuffix()
+}
+
 func (d PostgresDialect) TruncateClause() string {
 	return "truncate"
 }
 
+// PostgreSQL refuses to truncate a table referenced by a foreign key unless
+// the referencing table is truncated by the same statement.
+func (d PostgresDialect) Truncate(exec SqlQueryRunner, tables []QualifiedTable, opts TruncateOptions) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	_, err := exec.Exec(d.truncateSql(tables, opts))
+	return err
+}
+
+func (d PostgresDialect) truncateSql(tables []QualifiedTable, opts TruncateOptions) string {
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = d.QuotedTableForQuery(t.Schema, t.Name)
+	}
+	s := d.TruncateClause() + " " + strings.Join(names, ", ")
+	if opts.RestartIdentity {
+		s += " restart identity"
+	}
+	if opts.Cascade {
+		s += " cascade"
+	}
+	return s + d.QuerySuffix()
+}
+
 func (d PostgresDialect) SleepClause(s time.Duration) string {
 	return fmt.Sprintf("pg_sleep(%f)", s.Seconds())
 }
@@ -117,6 +160,129 @@ func (d PostgresDialect) InsertAutoIncrToTarget(exec SqlQueryRunner, insertSql s
 	return rows.Err()
 }
 
+// Returns 65535, the maximum number of parameters of the wire protocol
+func (d PostgresDialect) MaxBindVars() int {
+	return 65535
+}
+
+func (d PostgresDialect) MaxBatchRows() int {
+	return 0
+}
+
+// TransactionalDDL is true: schema changes can be rolled back.
+func (d PostgresDialect) TransactionalDDL() bool {
+	return true
+}
+
+// Returns "insert ... on conflict do update"
+func (d PostgresDialect) UpsertSql(schema, table string, columns, values, conflict, update []string) string {
+	return standardUpsertSql(d, schema, table, columns, values, conflict, update)
+}
+
+// The returning clause yields no row when the existing row is left
+// untouched.
+func (d PostgresDialect) UpsertKeySql(schema, table string, columns, values, conflict, update []string, key string) (string, bool) {
+	return returningUpsertSql(d, schema, table, columns, values, conflict, update, key), true
+}
+
+// The returning clause yields one key per row, in the order of the values.
+func (d PostgresDialect) InsertAutoIncrBatchToTargets(exec SqlQueryRunner, insertSql string, targets []interface{}, params ...interface{}) error {
+	rows, err := exec.Query(insertSql, params...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for _, target := range targets {
+		if !rows.Next() {
+			return fmt.Errorf("Not enough serial values returned for insert: %s Encountered error: %s", insertSql, rows.Err())
+		}
+		if err := rows.Scan(target); err != nil {
+			return err
+		}
+	}
+	if rows.Next() {
+		return fmt.Errorf("more serial values than rows returned for insert: %s", insertSql)
+	}
+	return rows.Err()
+}
+
+var postgresCatalog = catalogQueries{
+	columns: "select n.nspname, c.relname, a.attname, format_type(a.atttypid, a.atttypmod), case when a.attnotnull then 0 else 1 end " +
+		"from pg_attribute a join pg_class c on c.oid = a.attrelid join pg_namespace n on n.oid = c.relnamespace " +
+		"where c.relkind in ('r', 'p') and a.attnum > 0 and not a.attisdropped and n.nspname = coalesce(nullif($1, ''), current_schema()) " +
+		"order by c.relname, a.attnum",
+	primaryKeys: "select n.nspname, c.relname, a.attname " +
+		"from pg_index i join pg_class c on c.oid = i.indrelid join pg_namespace n on n.oid = c.relnamespace " +
+		"join pg_attribute a on a.attrelid = c.oid and a.attnum = any(i.indkey) " +
+		"where i.indisprimary and n.nspname = coalesce(nullif($1, ''), current_schema()) " +
+		"order by c.relname, array_position(i.indkey::int2[], a.attnum)",
+	indexes: "select n.nspname, c.relname, ic.relname, case when i.indisunique then 1 else 0 end, am.amname, a.attname " +
+		"from pg_index i join pg_class c on c.oid = i.indrelid join pg_class ic on ic.oid = i.indexrelid " +
+		"join pg_am am on am.oid = ic.relam join pg_namespace n on n.oid = c.relnamespace " +
+		"join pg_attribute a on a.attrelid = c.oid and a.attnum = any(i.indkey) " +
+		"where not i.indisprimary and n.nspname = coalesce(nullif($1, ''), current_schema()) " +
+		"order by c.relname, ic.relname, array_position(i.indkey::int2[], a.attnum)",
+	foreignKeys: "select n.nspname, c.relname, con.conname, a.attname, rn.nspname, rc.relname, ra.attname " +
+		"from pg_constraint con join pg_class c on c.oid = con.conrelid join pg_namespace n on n.oid = c.relnamespace " +
+		"join pg_class rc on rc.oid = con.confrelid join pg_namespace rn on rn.oid = rc.relnamespace " +
+		"cross join lateral unnest(con.conkey, con.confkey) with ordinality as k(attnum, refattnum, ord) " +
+		"join pg_attribute a on a.attrelid = con.conrelid and a.attnum = k.attnum " +
+		"join pg_attribute ra on ra.attrelid = con.confrelid and ra.attnum = k.refattnum " +
+		"where con.contype = 'f' and n.nspname = coalesce(nullif($1, ''), current_schema()) " +
+		"order by c.relname, con.conname, k.ord",
+}
+
+// InspectSchema reads the schema from pg_catalog.
+func (d PostgresDialect) InspectSchema(queryRunner SqlQueryRunner, schema string) (*Schema, error) {
+	return inspectCatalog(queryRunner, postgresCatalog, schema)
+}
+
+var postgresTypeAliases = map[string]string{
+	"serial":      "integer",
+	"bigserial":   "bigint",
+	"int":         "integer",
+	"int4":        "integer",
+	"int8":        "bigint",
+	"int2":        "smallint",
+	"bool":        "boolean",
+	"float8":      "double precision",
+	"float4":      "real",
+	"timestamptz": "timestamp with time zone",
+}
+
+// Maps the short and serial type names to the ones reported by
+// format_type().
+func (d PostgresDialect) NormalizeSqlType(sqlType string) string {
+	t := normalizeSqlType(sqlType, postgresTypeAliases)
+	t = strings.Replace(t, "character varying", "varchar", 1)
+	return strings.Replace(t, "character(", "char(", 1)
+}
+
+func (d PostgresDialect) AddColumnSql(schema, table string, col *ColumnMap) string {
+	return standardAddColumnSql(d, schema, table, col)
+}
+
+func (d PostgresDialect) DropColumnSql(schema, table, column string) string {
+	return standardDropColumnSql(d, schema, table, column)
+}
+
+func (d PostgresDialect) AlterColumnSql(schema, table string, diff *ColumnDiff) []string {
+	col := diff.Column
+	prefix := fmt.Sprintf("alter table %s alter column %s ", d.QuotedTableForQuery(schema, table), d.QuoteField(col.ColumnName))
+	var stmts []string
+	if diff.TypeChanged {
+		// serial is not a type but an integer with a sequence as default
+		stmts = append(stmts, prefix+"type "+d.ToSqlType(col.gotype, col.MaxSize, false)+d.QuerySuffix())
+	}
+	if diff.NullableChanged && (col.isPK || col.isNotNull) {
+		stmts = append(stmts, prefix+"set not null"+d.QuerySuffix())
+	} else if diff.NullableChanged {
+		stmts = append(stmts, prefix+"drop not null"+d.QuerySuffix())
+	}
+	return stmts
+}
+
 func (d PostgresDialect) QuoteField(f string) string {
 	if d.LowercaseFields {
 		return `"` + strings.ToLower(f) + `"`
@@ -143,3 +309,19 @@ func (d PostgresDialect) IfTableExists(command, schema, table string) string {
 func (d PostgresDialect) IfTableNotExists(command, schema, table string) string {
 	return fmt.Sprintf("%s if not exists", command)
 }
+
+// IsRetryable reports serialization failures (SQLSTATE 40001) and
+// deadlocks (SQLSTATE 40P01).
+func (d PostgresDialect) IsRetryable(err error) bool {
+	state, ok := errorSQLState(err)
+	return ok && (state == "40001" || state == "40P01")
+}
+
+// Strings sort by their bytes with the "C" collation. Postgres sorts NULL
+// values after the others, so they are put first explicitly.
+func (d PostgresDialect) MergeOrderSql(column string, text, desc bool) string {
+	if text {
+		column += ` collate "C"`
+	}
+	return column + mergeOrderSuffix(desc, true)
+}
@@ -3,6 +3,7 @@ package godb
 import (
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 type SqliteDialect struct {
@@ -70,6 +71,20 @@ func (d SqliteDialect) DropIndexSuffix() string {
 	return ""
 }
 
+// sqlite has a single index type.
+func (d SqliteDialect) CreateIndexSql(schema, table string, index *IndexMap, columns []string) string {
+	s := "create"
+	if index.Unique {
+		s += " unique"
+	}
+	return fmt.Sprintf("%s index %s on %s (%s)%s", s, d.QuoteField(index.IndexName),
+		d.QuotedTableForQuery(schema, table), quoteFields(d, columns, ""), d.QuerySuffix())
+}
+
+func (d SqliteDialect) DropIndexSql(schema, table, index string) string {
+	return "drop index " + d.QuoteField(index) + d.QuerySuffix()
+}
+
 // With sqlite, there technically isn't a TRUNCATE statement,
 // but a DELETE FROM uses a truncate optimization:
 // http://www.sqlite.org/lang_delete.html
@@ -77,6 +92,41 @@ func (d SqliteDialect) TruncateClause() string {
 	return "delete from"
 }
 
+// The counters of autoincrement columns are kept in sqlite_sequence, which
+// is only created along with the first table having one.
+func (d SqliteDialect) Truncate(exec SqlQueryRunner, tables []QualifiedTable, opts TruncateOptions) error {
+	for _, t := range tables {
+		_, err := exec.Exec(fmt.Sprintf("%s %s%s", d.TruncateClause(),
+			d.QuotedTableForQuery(t.Schema, t.Name), d.QuerySuffix()))
+		if err != nil {
+			return err
+		}
+	}
+	if !opts.RestartIdentity || len(tables) == 0 {
+		return nil
+	}
+
+	n, err := exec.SelectInt("select count(*) from sqlite_master where type = 'table' and name = 'sqlite_sequence'")
+	if err != nil || n == 0 {
+		return err
+	}
+	names := make([]interface{}, len(tables))
+	for i, t := range tables {
+		names[i] = t.Name
+	}
+	_, err = exec.Exec("delete from sqlite_sequence where name in ("+
+		strings.TrimSuffix(strings.Repeat("?,", len(names)), ",")+")", names...)
+	return err
+}
+
+// Returns "limit n offset m", with a limit of -1 when only an offset is given
+func (d SqliteDialect) LimitClause(limit, offset int, ordered bool) string {
+	if limit < 0 {
+		return fmt.Sprintf(" limit -1 offset %d", offset)
+	}
+	return standardLimitClause(limit, offset)
+}
+
 // Returns "?"
 func (d SqliteDialect) BindVar(i int) string {
 	return "?"
@@ -86,6 +136,90 @@ func (d SqliteDialect) InsertAutoIncr(exec SqlQueryRunner, insertSql string, par
 	return standardInsertAutoIncr(exec, insertSql, params...)
 }
 
+// Returns 999, the default SQLITE_MAX_VARIABLE_NUMBER before sqlite 3.32
+func (d SqliteDialect) MaxBindVars() int {
+	return 999
+}
+
+func (d SqliteDialect) MaxBatchRows() int {
+	return 0
+}
+
+// TransactionalDDL is true: schema changes can be rolled back.
+func (d SqliteDialect) TransactionalDDL() bool {
+	return true
+}
+
+// Returns "insert ... on conflict do update", available since sqlite 3.24
+func (d SqliteDialect) UpsertSql(schema, table string, columns, values, conflict, update []string) string {
+	return standardUpsertSql(d, schema, table, columns, values, conflict, update)
+}
+
+// The returning clause is available since sqlite 3.35, and yields no row
+// when the existing row is left untouched.
+func (d SqliteDialect) UpsertKeySql(schema, table string, columns, values, conflict, update []string, key string) (string, bool) {
+	return returningUpsertSql(d, schema, table, columns, values, conflict, update, key), true
+}
+
+// sqlite reports the rowid of the last inserted row as LastInsertId, and a
+// single statement gets consecutive rowids.
+func (d SqliteDialect) InsertAutoIncrBatch(exec SqlQueryRunner, insertSql string, rows int, params ...interface{}) ([]int64, error) {
+	last, err := standardInsertAutoIncr(exec, insertSql, params...)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, rows)
+	for i := range ids {
+		ids[i] = last - int64(rows-1-i)
+	}
+	return ids, nil
+}
+
+// InspectSchema reads the schema from sqlite_master and the table_info,
+// index_list and foreign_key_list pragmas. The schema is the name of an
+// attached database, "main" by default.
+func (d SqliteDialect) InspectSchema(queryRunner SqlQueryRunner, schema string) (*Schema, error) {
+	if schema == "" {
+		schema = "main"
+	}
+	tables := fmt.Sprintf("from %s.sqlite_master m ", d.QuoteField(schema))
+	isTable := "where m.type = 'table' and m.name not like 'sqlite_%' "
+	return inspectCatalog(queryRunner, catalogQueries{
+		columns: "select ?1, m.name, p.name, p.type, case when p.\"notnull\" = 1 then 0 else 1 end " +
+			tables + "join pragma_table_info(m.name, ?1) p " + isTable +
+			"order by m.name, p.cid",
+		primaryKeys: "select ?1, m.name, p.name " +
+			tables + "join pragma_table_info(m.name, ?1) p " + isTable + "and p.pk > 0 " +
+			"order by m.name, p.pk",
+		indexes: "select ?1, m.name, il.name, il.\"unique\", '', ii.name " +
+			tables + "join pragma_index_list(m.name, ?1) il join pragma_index_info(il.name, ?1) ii " +
+			isTable + "and il.origin <> 'pk' " +
+			"order by m.name, il.name, ii.seqno",
+		foreignKeys: "select ?1, m.name, cast(fk.id as text), fk.\"from\", ?1, fk.\"table\", fk.\"to\" " +
+			tables + "join pragma_foreign_key_list(m.name, ?1) fk " + isTable +
+			"order by m.name, fk.id, fk.seq",
+	}, schema)
+}
+
+// The declared type of a column is reported verbatim.
+func (d SqliteDialect) NormalizeSqlType(sqlType string) string {
+	return normalizeSqlType(sqlType, nil)
+}
+
+func (d SqliteDialect) AddColumnSql(schema, table string, col *ColumnMap) string {
+	return standardAddColumnSql(d, schema, table, col)
+}
+
+// Requires sqlite 3.35.0.
+func (d SqliteDialect) DropColumnSql(schema, table, column string) string {
+	return standardDropColumnSql(d, schema, table, column)
+}
+
+// sqlite cannot alter the type or nullability of a column.
+func (d SqliteDialect) AlterColumnSql(schema, table string, diff *ColumnDiff) []string {
+	return nil
+}
+
 func (d SqliteDialect) QuoteField(f string) string {
 	return `"` + f + `"`
 }
@@ -107,3 +241,11 @@ func (d SqliteDialect) IfTableNotExists(command, schema, table string) string {
 	return fmt.Sprintf("%s if not exists", command)
 }
 
+// Strings sort by their bytes with the binary collation, which columns
+// may override. NULL values come first in ascending order.
+func (d SqliteDialect) MergeOrderSql(column string, text, desc bool) string {
+	if text {
+		column += " collate binary"
+	}
+	return column + mergeOrderSuffix(desc, false)
+}
@@ -88,6 +88,42 @@ func (d SqlServerDialect) TruncateClause() string {
 	return "truncate table"
 }
 
+// SQL Server cannot truncate a table referenced by a foreign key, so the
+// rows are deleted instead. An identity is reseeded only when it has been
+// used, as the next value is otherwise the reseed value itself.
+func (d SqlServerDialect) Truncate(exec SqlQueryRunner, tables []QualifiedTable, opts TruncateOptions) error {
+	for _, t := range tables {
+		table := d.QuotedTableForQuery(t.Schema, t.Name)
+		if _, err := exec.Exec("delete from " + table + d.QuerySuffix()); err != nil {
+			return err
+		}
+		if !opts.RestartIdentity {
+			continue
+		}
+		_, err := exec.Exec(fmt.Sprintf("declare @reseed numeric(38) = (select cast(seed_value as numeric(38)) - cast(increment_value as numeric(38)) "+
+			"from sys.identity_columns where object_id = object_id(N'%[1]s') and last_value is not null); "+
+			"if @reseed is not null dbcc checkident (N'%[1]s', reseed, @reseed);", table))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns "offset m rows fetch next n rows only". SQL Server only accepts
+// it after an order by clause, so a neutral one is added when missing.
+func (d SqlServerDialect) LimitClause(limit, offset int, ordered bool) string {
+	s := ""
+	if !ordered {
+		s = " order by (select null)"
+	}
+	s += fmt.Sprintf(" offset %d rows", offset)
+	if limit >= 0 {
+		s += fmt.Sprintf(" fetch next %d rows only", limit)
+	}
+	return s
+}
+
 // Returns "?"
 func (d SqlServerDialect) BindVar(i int) string {
 	return "?"
@@ -97,6 +133,106 @@ func (d SqlServerDialect) InsertAutoIncr(exec SqlQueryRunner, insertSql string,
 	return standardInsertAutoIncr(exec, insertSql, params...)
 }
 
+// Returns 2100, the maximum number of parameters of a request
+func (d SqlServerDialect) MaxBindVars() int {
+	return 2100
+}
+
+// Returns 1000, the maximum number of rows of a table value constructor
+func (d SqlServerDialect) MaxBatchRows() int {
+	return 1000
+}
+
+// TransactionalDDL is true: schema changes can be rolled back.
+func (d SqlServerDialect) TransactionalDDL() bool {
+	return true
+}
+
+// GeneratedKeys is true: identity columns reject explicit values.
+func (d SqlServerDialect) GeneratedKeys() bool {
+	return true
+}
+
+// Returns a "merge" statement. holdlock keeps concurrent merges of the same
+// key from both inserting.
+func (d SqlServerDialect) UpsertSql(schema, table string, columns, values, conflict, update []string) string {
+	target := d.QuotedTableForQuery(schema, table) + " with (holdlock) as t"
+	source := fmt.Sprintf("using (values (%s)) as s (%s)", strings.Join(values, ","), quoteFields(d, columns, ""))
+	return mergeUpsertSql(d, target, source, columns, conflict, update) + ";"
+}
+
+// The output clause yields no row when the existing row is left untouched.
+func (d SqlServerDialect) UpsertKeySql(schema, table string, columns, values, conflict, update []string, key string) (string, bool) {
+	s := strings.TrimSuffix(d.UpsertSql(schema, table, columns, values, conflict, update), ";")
+	return s + " output inserted." + d.QuoteField(key) + ";", true
+}
+
+var sqlServerCatalog = catalogQueries{
+	columns: "select s.name, t.name, c.name, type_name(c.user_type_id) + " +
+		"case when type_name(c.user_type_id) in ('varchar', 'nvarchar', 'char', 'nchar', 'varbinary', 'binary') then '(' + " +
+		"case when c.max_length = -1 then 'max' when type_name(c.user_type_id) in ('nvarchar', 'nchar') then cast(c.max_length / 2 as varchar) " +
+		"else cast(c.max_length as varchar) end + ')' else '' end, " +
+		"case when c.is_nullable = 1 then 1 else 0 end " +
+		"from sys.columns c join sys.tables t on t.object_id = c.object_id join sys.schemas s on s.schema_id = t.schema_id " +
+		"where s.name = coalesce(nullif(?, ''), schema_name()) " +
+		"order by t.name, c.column_id",
+	primaryKeys: "select s.name, t.name, c.name " +
+		"from sys.indexes i join sys.index_columns ic on ic.object_id = i.object_id and ic.index_id = i.index_id " +
+		"join sys.columns c on c.object_id = ic.object_id and c.column_id = ic.column_id " +
+		"join sys.tables t on t.object_id = i.object_id join sys.schemas s on s.schema_id = t.schema_id " +
+		"where i.is_primary_key = 1 and s.name = coalesce(nullif(?, ''), schema_name()) " +
+		"order by t.name, ic.key_ordinal",
+	indexes: "select s.name, t.name, i.name, case when i.is_unique = 1 then 1 else 0 end, lower(i.type_desc), c.name " +
+		"from sys.indexes i join sys.index_columns ic on ic.object_id = i.object_id and ic.index_id = i.index_id " +
+		"join sys.columns c on c.object_id = ic.object_id and c.column_id = ic.column_id " +
+		"join sys.tables t on t.object_id = i.object_id join sys.schemas s on s.schema_id = t.schema_id " +
+		"where i.is_primary_key = 0 and i.type > 0 and ic.is_included_column = 0 and s.name = coalesce(nullif(?, ''), schema_name()) " +
+		"order by t.name, i.name, ic.key_ordinal",
+	foreignKeys: "select s.name, t.name, fk.name, c.name, rs.name, rt.name, rc.name " +
+		"from sys.foreign_keys fk join sys.foreign_key_columns fkc on fkc.constraint_object_id = fk.object_id " +
+		"join sys.tables t on t.object_id = fk.parent_object_id join sys.schemas s on s.schema_id = t.schema_id " +
+		"join sys.columns c on c.object_id = fkc.parent_object_id and c.column_id = fkc.parent_column_id " +
+		"join sys.tables rt on rt.object_id = fk.referenced_object_id join sys.schemas rs on rs.schema_id = rt.schema_id " +
+		"join sys.columns rc on rc.object_id = fkc.referenced_object_id and rc.column_id = fkc.referenced_column_id " +
+		"where s.name = coalesce(nullif(?, ''), schema_name()) " +
+		"order by t.name, fk.name, fkc.constraint_column_id",
+}
+
+// InspectSchema reads the schema from the sys catalog views.
+func (d SqlServerDialect) InspectSchema(queryRunner SqlQueryRunner, schema string) (*Schema, error) {
+	return inspectCatalog(queryRunner, sqlServerCatalog, schema)
+}
+
+// Maps float(53) and float(24), which the catalog reports as float and
+// real.
+func (d SqlServerDialect) NormalizeSqlType(sqlType string) string {
+	return normalizeSqlType(sqlType, map[string]string{
+		"float(53)": "float",
+		"float(24)": "real",
+		"varbinary": "varbinary(1)",
+	})
+}
+
+// SQL Server has no "column" keyword in "alter table ... add".
+func (d SqlServerDialect) AddColumnSql(schema, table string, col *ColumnMap) string {
+	return fmt.Sprintf("alter table %s add %s %s%s", d.QuotedTableForQuery(schema, table),
+		d.QuoteField(col.ColumnName), addColumnDefinition(d, col), d.QuerySuffix())
+}
+
+func (d SqlServerDialect) DropColumnSql(schema, table, column string) string {
+	return standardDropColumnSql(d, schema, table, column)
+}
+
+func (d SqlServerDialect) AlterColumnSql(schema, table string, diff *ColumnDiff) []string {
+	col := diff.Column
+	nullable := " null"
+	if col.isPK || col.isNotNull {
+		nullable = " not null"
+	}
+	return []string{fmt.Sprintf("alter table %s alter column %s %s%s%s", d.QuotedTableForQuery(schema, table),
+		d.QuoteField(col.ColumnName), d.ToSqlType(col.gotype, col.MaxSize, col.isAutoIncr), nullable, d.QuerySuffix())}
+}
+
 func (d SqlServerDialect) QuoteField(f string) string {
 	return "[" + strings.Replace(f, "]", "]]", -1) + "]"
 }
@@ -135,3 +271,50 @@ func (d SqlServerDialect) IfTableNotExists(command, schema, table string) string
 
 func (d SqlServerDialect) CreateIndexSuffix() string { return "" }
 func (d SqlServerDialect) DropIndexSuffix() string   { return "" }
+
+// The index type is "clustered" or "nonclustered".
+func (d SqlServerDialect) CreateIndexSql(schema, table string, index *IndexMap, columns []string) string {
+	s := "create"
+	if index.Unique {
+		s += " unique"
+	}
+	if t := indexMethod(index.IndexType); t == "clustered" || t == "nonclustered" {
+		s += " " + t
+	}
+	return fmt.Sprintf("%s index %s on %s (%s)%s", s, d.QuoteField(index.IndexName),
+		d.QuotedTableForQuery(schema, table), quoteFields(d, columns, ""), d.QuerySuffix())
+}
+
+func (d SqlServerDialect) DropIndexSql(schema, table, index string) string {
+	return fmt.Sprintf("drop index %s on %s%s", d.QuoteField(index), d.QuotedTableForQuery(schema, table), d.QuerySuffix())
+}
+
+// IsRetryable reports the transactions chosen as deadlock victims (error
+// 1205).
+func (d SqlServerDialect) IsRetryable(err error) bool {
+	n, ok := errorNumber(err)
+	return ok && n == 1205
+}
+
+func (d SqlServerDialect) SavepointSql(name string) string {
+	return "save transaction " + d.QuoteField(name)
+}
+
+func (d SqlServerDialect) RollbackToSavepointSql(name string) string {
+	return "rollback transaction " + d.QuoteField(name)
+}
+
+// SQL Server cannot release savepoints, they last until the end of the
+// transaction.
+func (d SqlServerDialect) ReleaseSavepointSql(name string) string {
+	return ""
+}
+
+// Strings sort by their code points with the Latin1_General_BIN2
+// collation. NULL values come first in ascending order.
+func (d SqlServerDialect) MergeOrderSql(column string, text, desc bool) string {
+	if text {
+		column += " collate Latin1_General_BIN2"
+	}
+	return column + mergeOrderSuffix(desc, false)
+}
@@ -18,6 +18,86 @@ func (err *NoFieldInTypeError) Error() string {
 	return fmt.Sprintf("godb: no fields %+v in type %s", err.MissingColNames, err.TypeName)
 }
 
+// OptimisticLockError is returned by Update() or Delete() if the
+// struct being modified has a version column set with SetVersionCol
+// and the version in the database no longer matches the one of the struct.
+type OptimisticLockError struct {
+	// Table name where the lock error occurred
+	TableName string
+
+	// Primary key values of the row being updated/deleted
+	Keys []interface{}
+
+	// true if a row was found with those keys, indicating the
+	// LocalVersion is stale.  false if no value was found with those
+	// keys, suggesting the row has been deleted since loaded, or
+	// was never inserted to begin with
+	RowExists bool
+
+	// Version value on the struct passed to Update/Delete. This value is
+	// out of sync with the database.
+	LocalVersion int64
+}
+
+// Error returns a description of the cause of the lock error
+func (e *OptimisticLockError) Error() string {
+	if e.RowExists {
+		return fmt.Sprintf("godb: OptimisticLockError table=%s keys=%v out of date version=%d", e.TableName, e.Keys, e.LocalVersion)
+	}
+
+	return fmt.Sprintf("godb: OptimisticLockError no row found for table=%s keys=%v", e.TableName, e.Keys)
+}
+
+// MigrationError is returned by a Migrator when a step of a migration
+// fails.
+type MigrationError struct {
+	Version int64
+	Name    string
+
+	// true if the failing step applied the migration, false if it rolled
+	// it back
+	Up bool
+
+	Err error
+}
+
+func (e *MigrationError) Error() string {
+	step := "down"
+	if e.Up {
+		step = "up"
+	}
+	return fmt.Sprintf("godb: migration %d_%s %s: %v", e.Version, e.Name, step, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// MigrationChecksumError is returned by a Migrator when the SQL of an
+// applied migration was changed after it was applied.
+type MigrationChecksumError struct {
+	Version int64
+	Name    string
+}
+
+func (e *MigrationChecksumError) Error() string {
+	return fmt.Sprintf("godb: migration %d_%s was modified after it was applied", e.Version, e.Name)
+}
+
+// MigrationLockedError is returned by a Migrator when another process held
+// the migration lock for longer than its LockTimeout.
+type MigrationLockedError struct {
+	// Owner is the host name and process id of the lock holder
+	Owner string
+
+	// LockedAt is when the lock was taken, in RFC 3339 format
+	LockedAt string
+}
+
+func (e *MigrationLockedError) Error() string {
+	return fmt.Sprintf("godb: migrations locked by %s since %s", e.Owner, e.LockedAt)
+}
+
 // returns true if the error is non-fatal (ie, we shouldn't immediately return)
 func NonFatalError(err error) bool {
 	switch err.(type) {
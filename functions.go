@@ -71,10 +71,7 @@ func toSliceType(i interface{}) (reflect.Type, error) {
 		}
 
 		return nil, nil
-	}else {
-		fmt.Println(t.Kind())
 	}
-	fmt.Println(t.Elem())
 	if t = t.Elem(); t.Kind() != reflect.Slice {
 		return nil, nil
 	}
@@ -83,6 +80,9 @@ func toSliceType(i interface{}) (reflect.Type, error) {
 
 func toType(i interface{}) (reflect.Type, error) {
 	t := reflect.TypeOf(i)
+	if t == nil {
+		return nil, fmt.Errorf("godb: cannot SELECT into this type: %v", t)
+	}
 
 	// If a Pointer to a type, follow
 	for t.Kind() == reflect.Ptr {
@@ -0,0 +1,71 @@
+//go:build go1.18
+
+package godb
+
+// AddTableT registers the struct type T, like AddTable.
+func AddTableT[T any](dbUtils *DbUtils) *TableMap {
+	var zero T
+	return dbUtils.AddTable(zero)
+}
+
+// AddTableWithNameT registers the struct type T with the given table name,
+// like AddTableWithName.
+func AddTableWithNameT[T any](dbUtils *DbUtils, name string) *TableMap {
+	var zero T
+	return dbUtils.AddTableWithName(zero, name)
+}
+
+// GetT runs Get for the table registered for T. It returns nil, and no
+// error, if no row has the given keys.
+func GetT[T any](runner SqlQueryRunner, keys ...interface{}) (*T, error) {
+	obj, err := runner.Get((*T)(nil), keys...)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	return obj.(*T), nil
+}
+
+// SelectT runs query and returns a new T per row. T is a struct whose
+// fields are matched with the columns, or the type of a single column.
+// As with Select, the rows are returned along with a NonFatalError.
+func SelectT[T any](runner SqlQueryRunner, query string, args ...interface{}) ([]*T, error) {
+	var list []*T
+	_, err := runner.Select(&list, query, args...)
+	if err != nil && !NonFatalError(err) {
+		return nil, err
+	}
+	return list, err
+}
+
+// SelectOneT runs query, which must return a single row, and returns it
+// as a new T. sql.ErrNoRows is returned when there is no row.
+func SelectOneT[T any](runner SqlQueryRunner, query string, args ...interface{}) (*T, error) {
+	holder := new(T)
+	if err := runner.SelectOne(holder, query, args...); err != nil {
+		return nil, err
+	}
+	return holder, nil
+}
+
+// InsertT inserts list, like Insert.
+func InsertT[T any](runner SqlQueryRunner, list ...*T) error {
+	return runner.Insert(toInterfaces(list)...)
+}
+
+// UpdateT updates list, like Update.
+func UpdateT[T any](runner SqlQueryRunner, list ...*T) (int64, error) {
+	return runner.Update(toInterfaces(list)...)
+}
+
+// DeleteT deletes list, like Delete.
+func DeleteT[T any](runner SqlQueryRunner, list ...*T) (int64, error) {
+	return runner.Delete(toInterfaces(list)...)
+}
+
+func toInterfaces[T any](list []*T) []interface{} {
+	s := make([]interface{}, len(list))
+	for i, v := range list {
+		s[i] = v
+	}
+	return s
+}
@@ -0,0 +1,67 @@
+//go:build go1.18
+
+package godb
+
+import (
+	"database/sql"
+	"testing"
+)
+
+type GenericPerson struct {
+	Id   int64  `db:"id, primarykey, autoincrement"`
+	Name string `db:"name, size:50"`
+}
+
+func Test_GenericT(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	AddTableWithNameT[GenericPerson](dbmap, "generic_person_test")
+	dbmap.DropTablesIfExists()
+	if err := dbmap.CreateTables(); err != nil {
+		panic(err)
+	}
+	defer dbmap.DropTablesIfExists()
+
+	a, b := &GenericPerson{Name: "a"}, &GenericPerson{Name: "b"}
+	if err := InsertT(dbmap, a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	trans, err := dbmap.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetT[GenericPerson](trans, b.Id)
+	if err != nil || got == nil || got.Name != "b" {
+		t.Errorf("GetT returned %+v, %v", got, err)
+	}
+	if missing, err := GetT[GenericPerson](trans, -1); missing != nil || err != nil {
+		t.Errorf("GetT of a missing row returned %+v, %v", missing, err)
+	}
+	trans.Rollback()
+
+	list, err := SelectT[GenericPerson](dbmap, "select * from generic_person_test order by id")
+	if err != nil || len(list) != 2 || list[0].Name != "a" || list[1].Id != b.Id {
+		t.Errorf("SelectT returned %+v, %v", list, err)
+	}
+	names, err := SelectT[string](dbmap, "select name from generic_person_test order by id")
+	if err != nil || len(names) != 2 || *names[1] != "b" {
+		t.Errorf("SelectT of a column returned %v, %v", names, err)
+	}
+
+	one, err := SelectOneT[GenericPerson](dbmap, "select * from generic_person_test where name = ?", "a")
+	if err != nil || one.Id != a.Id {
+		t.Errorf("SelectOneT returned %+v, %v", one, err)
+	}
+	if _, err := SelectOneT[GenericPerson](dbmap, "select * from generic_person_test where id = -1"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	a.Name = "c"
+	if n, err := UpdateT(dbmap, a); err != nil || n != 1 {
+		t.Errorf("UpdateT updated %d: %v", n, err)
+	}
+	if n, err := DeleteT(dbmap, a, b); err != nil || n != 2 {
+		t.Errorf("DeleteT deleted %d: %v", n, err)
+	}
+}
@@ -40,6 +40,9 @@ type SqlQueryRunner interface {
 	SelectStr(query string, args ...interface{}) (string, error)
 	SelectNullStr(query string, args ...interface{}) (sql.NullString, error)
 	SelectOne(holder interface{}, query string, args ...interface{}) error
+	SelectIter(holderType interface{}, query string, args ...interface{}) (*RowIter, error)
+	QueryMap(query string, args ...interface{}) (*RowsMap, error)
+	SelectMaps(query string, args ...interface{}) ([]map[string]interface{}, error)
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
 }
@@ -82,8 +85,6 @@ func maybeExpandNamedQuery(dbUtils *DbUtils, query string, args []interface{}) (
 		return query, args
 	}
 
-	fmt.Println(query)
-
 	return expandNamedQuery(dbUtils, query, argval.FieldByName)
 }
 
@@ -114,8 +115,7 @@ func maybeExpandNamedQueryAndExec(queryRunner SqlQueryRunner, query string, args
 	if len(args) == 1 {
 		query, args = maybeExpandNamedQuery(dbUtils, query, args)
 	}
-	//return exec(queryRunner, query, args...)
-	return dbUtils.Db.Exec(query,args...)
+	return exec(queryRunner, query, args...)
 }
 
 func extractDbUtils(queryRunner SqlQueryRunner) *DbUtils {
@@ -128,54 +128,22 @@ func extractDbUtils(queryRunner SqlQueryRunner) *DbUtils {
 	return nil
 }
 
-/*
-func extractExecutorAndContext(e SqlQueryRunner) (reflect.Value, context.Context) {
-	switch m := e.(type) {
-	case *DbUtils:
-		return reflect.ValueOf(m.Db), m.ctx
-	case *Transaction:
-		return reflect.ValueOf(m.tx), m.ctx
-	}
-	return reflect.ValueOf(nil), nil
-}*/
-
+// columnToFieldIndex returns the index of the field receiving each column.
+// Columns are matched with the mapped column names, and with the prefix of
+// embedded structs tagged `db:"prefix=addr_"`. Aliases such as "user.id"
+// fill the fields of nested structs, matched by their db tag or field name.
 func columnToFieldIndex(m *DbUtils, t reflect.Type,name string, cols []string) ([][]int, error) {
 
 	colToFieldIndex := make([][]int, len(cols))
 
-	tableMapped := false
 	table := tableOrNil(m, t, name)
-	if table != nil {
-		tableMapped = true
-	}
 	missingColNames := []string{}
 
 	for x := range cols {
 		colName := strings.ToLower(cols[x])
-		field, found := t.FieldByNameFunc(func(fieldName string) bool {
-
-			field, _ := t.FieldByName(fieldName)
-			cArguments := strings.Split(field.Tag.Get("db"), ",")
-			fieldName = cArguments[0]
-
-			if fieldName == "-" {
-				return false
-			} else if fieldName == "" {
-				fieldName = field.Name
-			}
-			if tableMapped {
-
-				colMap := colMapOrNil(table, fieldName)
-				//fmt.Println(colMap)
-				//fmt.Println(fieldName)
-				if colMap != nil {
-					fieldName = colMap.ColumnName
-				}
-			}
-			return colName == strings.ToLower(fieldName)
-		})
-		if found {
-			colToFieldIndex[x] = field.Index
+		colToFieldIndex[x] = fieldIndexForColumn(t, table, colName)
+		if colToFieldIndex[x] == nil && strings.Contains(colName, ".") {
+			colToFieldIndex[x] = nestedFieldIndex(m, t, colName)
 		}
 		if colToFieldIndex[x] == nil {
 			missingColNames = append(missingColNames, colName)
@@ -190,6 +158,109 @@ func columnToFieldIndex(m *DbUtils, t reflect.Type,name string, cols []string) (
 	return colToFieldIndex, nil
 }
 
+// fieldIndexForColumn returns the index of the field of t mapped to the
+// lower cased colName, or nil.
+func fieldIndexForColumn(t reflect.Type, table *TableMap, colName string) []int {
+	if table != nil {
+		for _, col := range table.Columns {
+			if !col.Transient && colName == strings.ToLower(col.ColumnName) {
+				return col.fieldIndex
+			}
+		}
+	}
+	return findField(t, "", func(f reflect.StructField, prefix string) bool {
+		fieldName := strings.TrimSpace(strings.Split(f.Tag.Get("db"), ",")[0])
+		if fieldName == "-" {
+			return false
+		} else if fieldName == "" {
+			fieldName = f.Name
+		}
+		fieldName = prefix + fieldName
+		return colName == strings.ToLower(fieldName)
+	})
+}
+
+// nestedFieldIndex returns the index of the field filled by a column
+// aliased "user.id": the field Id of the struct in the field user.
+func nestedFieldIndex(m *DbUtils, t reflect.Type, colName string) []int {
+	i := strings.Index(colName, ".")
+	head, rest := colName[:i], colName[i+1:]
+	index := findField(t, "", func(f reflect.StructField, prefix string) bool {
+		if indirectType(f.Type).Kind() != reflect.Struct {
+			return false
+		}
+		fieldName := strings.TrimSpace(strings.Split(f.Tag.Get("db"), ",")[0])
+		if fieldName == "" || fieldName == "-" {
+			fieldName = f.Name
+		}
+		return head == strings.ToLower(fieldName)
+	})
+	if index == nil {
+		return nil
+	}
+
+	sub := indirectType(t.FieldByIndex(index).Type)
+	subIndex := fieldIndexForColumn(sub, tableOrNil(m, sub, ""), rest)
+	if subIndex == nil && strings.Contains(rest, ".") {
+		subIndex = nestedFieldIndex(m, sub, rest)
+	}
+	if subIndex == nil {
+		return nil
+	}
+	return append(index, subIndex...)
+}
+
+// findField returns the index of the first exported field of t accepted by
+// match, looking at the fields of embedded structs after those of t. The
+// prefix passed to match is the one of the embedded structs.
+func findField(t reflect.Type, prefix string, match func(f reflect.StructField, prefix string) bool) []int {
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && indirectType(f.Type).Kind() == reflect.Struct {
+			embedded = append(embedded, f)
+			continue
+		}
+		if f.PkgPath == "" && match(f, prefix) {
+			return []int{i}
+		}
+	}
+	for _, f := range embedded {
+		if index := findField(indirectType(f.Type), prefix+embeddedPrefix(f), match); index != nil {
+			return append([]int{f.Index[0]}, index...)
+		}
+	}
+	return nil
+}
+
+// embeddedPrefix returns the prefix of the columns of an embedded struct,
+// set with a `db:"prefix=addr_"` tag.
+func embeddedPrefix(f reflect.StructField) string {
+	for _, arg := range strings.Split(f.Tag.Get("db"), ",") {
+		arg = strings.TrimSpace(arg)
+		if strings.HasPrefix(arg, "prefix=") {
+			return strings.TrimPrefix(arg, "prefix=")
+		}
+	}
+	return ""
+}
+
+// fieldByName returns the field of the struct v named name, which is a
+// path such as "Home.Street" for the fields of prefixed embedded structs.
+func fieldByName(v reflect.Value, name string) reflect.Value {
+	for _, part := range strings.Split(name, ".") {
+		v = v.FieldByName(part)
+	}
+	return v
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
 func tableFor(dbUtils *DbUtils, t reflect.Type, i interface{}) (*TableMap, error) {
 
 	table, err := dbUtils.TableFor(t, true)
@@ -202,6 +273,7 @@ func tableFor(dbUtils *DbUtils, t reflect.Type, i interface{}) (*TableMap, error
 func get(dbUtils *DbUtils, queryRunner SqlQueryRunner, i interface{},
 	keys ...interface{}) (interface{}, error) {
 
+	keys, preloads := extractPreloads(keys)
 	t, err := toType(i)
 	if err != nil {
 		return nil, err
@@ -212,7 +284,7 @@ func get(dbUtils *DbUtils, queryRunner SqlQueryRunner, i interface{},
 		return nil, err
 	}
 
-	plan := table.bindGet()
+	plan := table.bindGet(dbUtils.unscoped)
 
 	v := reflect.New(t)
 
@@ -223,7 +295,7 @@ func get(dbUtils *DbUtils, queryRunner SqlQueryRunner, i interface{},
 
 	for x, fieldName := range plan.argFields {
 
-		f := v.Elem().FieldByName(fieldName)
+		f := fieldByName(v.Elem(), fieldName)
 		target := f.Addr().Interface()
 		if conv != nil {
 			scanner, ok := conv.FromDb(target)
@@ -253,11 +325,23 @@ func get(dbUtils *DbUtils, queryRunner SqlQueryRunner, i interface{},
 		}
 	}
 
+	if v, ok := v.Interface().(HasPostGet); ok {
+		err := v.PostGet(queryRunner)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := preload(dbUtils, queryRunner, t, []reflect.Value{v.Elem()}, preloads); err != nil {
+		return nil, err
+	}
 
 	return v.Interface(),nil
 }
 
-func delete(dbUtils *DbUtils, queryRunner SqlQueryRunner, list ...interface{}) (int64, error) {
+// delete deletes the rows of list. Rows of tables with a soft delete column
+// are marked deleted unless hard is set.
+func delete(dbUtils *DbUtils, queryRunner SqlQueryRunner, hard bool, list ...interface{}) (int64, error) {
 	count := int64(0)
 	for _, ptr := range list {
 		table, elem, err := dbUtils.tableForPointer(ptr, true)
@@ -265,20 +349,57 @@ func delete(dbUtils *DbUtils, queryRunner SqlQueryRunner, list ...interface{}) (
 			return -1, err
 		}
 
+		eptr := elem.Addr().Interface()
+		if v, ok := eptr.(HasPreDelete); ok {
+			err = v.PreDelete(queryRunner)
+			if err != nil {
+				return -1, err
+			}
+		}
 
-		bi, err := table.bindDelete(elem)
+		var (
+			bi      bindInstance
+			restore = func() {}
+		)
+		if table.softDelete != nil && !hard {
+			f := elem.FieldByIndex(table.softDelete.fieldIndex)
+			old := reflect.ValueOf(f.Interface())
+			now, _ := timeValue(f.Type(), dbUtils.now())
+			f.Set(now)
+			restore = func() { f.Set(old) }
+			bi, err = table.bindSoftDelete(elem)
+		} else {
+			bi, err = table.bindDelete(elem)
+		}
 		if err != nil {
+			restore()
 			return -1, err
 		}
 
 		res, err := queryRunner.Exec(bi.query, bi.args...)
 		if err != nil {
+			restore()
 			return -1, err
 		}
 		rows, err := res.RowsAffected()
 		if err != nil {
+			restore()
 			return -1, err
 		}
+		if rows == 0 {
+			restore()
+		}
+
+		if rows == 0 && bi.versField != "" {
+			return -1, lockError(queryRunner, table, bi.existingVersion, bi.keys...)
+		}
+
+		if v, ok := eptr.(HasPostDelete); ok {
+			err = v.PostDelete(queryRunner)
+			if err != nil {
+				return -1, err
+			}
+		}
 
 		count += rows
 
@@ -287,6 +408,25 @@ func delete(dbUtils *DbUtils, queryRunner SqlQueryRunner, list ...interface{}) (
 	return count, nil
 }
 
+func lockError(queryRunner SqlQueryRunner, table *TableMap, existingVersion int64, keys ...interface{}) error {
+
+	// count the row rather than get it, so that neither the hooks nor the
+	// soft delete filter apply, and on the primary, which ran the update
+	_, ctx := extractExecutorAndContext(queryRunner)
+	var count int64
+	err := queryRow(queryRunner.WithContext(ForcePrimary(ctx)), table.bindExists().query, keys...).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	return &OptimisticLockError{
+		TableName:    table.TableName,
+		Keys:         keys,
+		RowExists:    count > 0,
+		LocalVersion: existingVersion,
+	}
+}
+
 func update(dbUtils *DbUtils, queryRunner SqlQueryRunner, list ...interface{}) (int64, error) {
 
 	count := int64(0)
@@ -296,22 +436,54 @@ func update(dbUtils *DbUtils, queryRunner SqlQueryRunner, list ...interface{}) (
 		if err != nil {
 			return -1, err
 		}
+
+		restore := table.setTimestamps(elem, dbUtils.now(), false)
+		eptr := elem.Addr().Interface()
+		if v, ok := eptr.(HasPreUpdate); ok {
+			err = v.PreUpdate(queryRunner)
+			if err != nil {
+				restore()
+				return -1, err
+			}
+		}
+
 		bi, err := table.bindUpdate(elem)
 
 		if err != nil {
+			restore()
 			return -1, err
 		}
 
 		res, err := queryRunner.Exec(bi.query, bi.args...)
 		if err != nil {
+			restore()
 			return -1, err
 		}
 
 		rows, err := res.RowsAffected()
 		if err != nil {
+			restore()
 			return -1, err
 		}
 
+		if rows == 0 {
+			restore()
+		}
+
+		if bi.versField != "" {
+			if rows == 0 {
+				return -1, lockError(queryRunner, table, bi.existingVersion, bi.keys...)
+			}
+			fieldByName(elem, bi.versField).SetInt(bi.existingVersion + 1)
+		}
+
+		if v, ok := eptr.(HasPostUpdate); ok {
+			err = v.PostUpdate(queryRunner)
+			if err != nil {
+				return -1, err
+			}
+		}
+
 		count += rows
 	}
 
@@ -321,101 +493,175 @@ func update(dbUtils *DbUtils, queryRunner SqlQueryRunner, list ...interface{}) (
 
 func insert(dbUtils *DbUtils, queryRunner SqlQueryRunner, list ...interface{}) error {
 
-	for i, ptr := range list {
-        fmt.Println(i)
+	for _, ptr := range list {
 		table, elem, err := dbUtils.tableForPointer(ptr, false)
 
 		if err != nil {
 			return err
 		}
-		bi,err:=table.insert(elem)
-		fmt.Println(bi)
 
+		restore := table.setTimestamps(elem, dbUtils.now(), true)
+		eptr := elem.Addr().Interface()
+		if v, ok := eptr.(HasPreInsert); ok {
+			err = v.PreInsert(queryRunner)
+			if err != nil {
+				restore()
+				return err
+			}
+		}
+
+		bi,err:=table.insert(elem)
 		if err != nil {
+			restore()
 			return err
 		}
 
 		if bi.autoIncrIdx > -1 {
-			f := elem.FieldByName(bi.autoIncrFieldName)
+			f := fieldByName(elem, bi.autoIncrFieldName)
 			switch inserter := dbUtils.Dialect.(type) {
 			case IntegerAutoIncrInserter:
 				id, err := inserter.InsertAutoIncr(queryRunner, bi.query, bi.args...)
 				if err != nil {
+					restore()
 					return err
 				}
-				k := f.Kind()
-				if (k == reflect.Int) || (k == reflect.Int16) || (k == reflect.Int32) || (k == reflect.Int64) {
-					f.SetInt(id)
-				} else if (k == reflect.Uint) || (k == reflect.Uint16) || (k == reflect.Uint32) || (k == reflect.Uint64) {
-					f.SetUint(uint64(id))
-				} else {
+				if !setAutoIncrValue(f, id) {
 					return fmt.Errorf("godb: cannot set autoincrement value on non-Int field. SQL=%s  autoIncrIdx=%d autoIncrFieldName=%s", bi.query, bi.autoIncrIdx, bi.autoIncrFieldName)
 				}
 			case TargetedAutoIncrInserter:
 				err := inserter.InsertAutoIncrToTarget(queryRunner, bi.query, f.Addr().Interface(), bi.args...)
 				if err != nil {
+					restore()
 					return err
 				}
 			case TargetQueryInserter:
 				var idQuery = table.ColMap(bi.autoIncrFieldName).GeneratedIdQuery
 				if idQuery == "" {
+					restore()
 					return fmt.Errorf("godb: cannot set %s value if its ColumnMap.GeneratedIdQuery is empty", bi.autoIncrFieldName)
 				}
-				err := inserter.InsertQueryToTarget(queryRunner, bi.query, idQuery, f.Addr().Interface(), bi.args...)
+				// the id is read back on the primary, which ran the insert
+				_, ctx := extractExecutorAndContext(queryRunner)
+				err := inserter.InsertQueryToTarget(queryRunner.WithContext(ForcePrimary(ctx)), bi.query, idQuery, f.Addr().Interface(), bi.args...)
 				if err != nil {
+					restore()
 					return err
 				}
 			default:
+				restore()
 				return fmt.Errorf("godb: cannot use autoincrement fields on dialects that do not implement an autoincrementing interface")
 			}
 		}else {
 			_, err := queryRunner.Exec(bi.query, bi.args...)
 			if err != nil {
+				restore()
 				return err
 			}
 		}
 
+		if bi.versField != "" {
+			fieldByName(elem, bi.versField).SetInt(bi.existingVersion + 1)
+		}
+
+		if v, ok := eptr.(HasPostInsert); ok {
+			err := v.PostInsert(queryRunner)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
 
-func query(queryRunner SqlQueryRunner, query string, args ...interface{}) (*sql.Rows, error) {
-	switch m := queryRunner.(type) {
-	case *DbUtils:
-		return m.Db.Query(query,args...)
-	case *Transaction:
-		return m.tx.Query(query,args...)
+// setAutoIncrValue stores a generated key in an integer field, returning
+// false if the field is not an integer.
+func setAutoIncrValue(f reflect.Value, id int64) bool {
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f.SetUint(uint64(id))
+	default:
+		return false
 	}
-	return nil, nil
+	return true
 }
 
-func queryRow(queryRunner SqlQueryRunner, query string, args ...interface{}) *sql.Row {
+// executor is implemented by *sql.DB and *sql.Tx.
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// extractExecutorAndContext returns the connection pool or transaction the
+// queryRunner runs its statements on, along with its context.
+// context.Background() is used when no context was set with WithContext.
+func extractExecutorAndContext(queryRunner SqlQueryRunner) (executor, context.Context) {
+	var (
+		ex  executor
+		ctx context.Context
+	)
 	switch m := queryRunner.(type) {
 	case *DbUtils:
-		return m.Db.QueryRow(query,args...)
+		ex, ctx = m.Db, m.ctx
 	case *Transaction:
-		return m.tx.QueryRow(query,args...)
+		ex, ctx = m.tx, m.ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
 	}
+	return ex, ctx
+}
 
-	return nil
+func exec(queryRunner SqlQueryRunner, query string, args ...interface{}) (sql.Result, error) {
+	ex, ctx := extractExecutorAndContext(queryRunner)
+	trace, ctx := traceQuery(queryRunner, ctx, query, args)
+	res, err := ex.ExecContext(ctx, query, args...)
+	trace.done(res, err)
+	return res, err
 }
 
-func begin(dbUtils *DbUtils) (*sql.Tx, error) {
-	if dbUtils.ctx != nil {
-		return dbUtils.Db.BeginTx(dbUtils.ctx, nil)
+func query(queryRunner SqlQueryRunner, query string, args ...interface{}) (*sql.Rows, error) {
+	ex, ctx := extractExecutorAndContext(queryRunner)
+	trace, ctx := traceQuery(queryRunner, ctx, query, args)
+	var rows *sql.Rows
+	err := routeRead(queryRunner, ctx, query, ex, func(ex executor) (err error) {
+		rows, err = ex.QueryContext(ctx, query, args...)
+		return err
+	})
+	trace.done(nil, err)
+	return rows, err
+}
+
+func queryRow(queryRunner SqlQueryRunner, query string, args ...interface{}) *sql.Row {
+	ex, ctx := extractExecutorAndContext(queryRunner)
+	trace, ctx := traceQuery(queryRunner, ctx, query, args)
+	var row *sql.Row
+	routeRead(queryRunner, ctx, query, ex, func(ex executor) error {
+		row = ex.QueryRowContext(ctx, query, args...)
+		return row.Err()
+	})
+	trace.done(nil, row.Err())
+	return row
+}
+
+func begin(dbUtils *DbUtils, opts *sql.TxOptions) (*sql.Tx, error) {
+	if dbUtils.ctx != nil || opts != nil {
+		ctx := dbUtils.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		return dbUtils.Db.BeginTx(ctx, opts)
 	}
 
 	return dbUtils.Db.Begin()
 }
 
 func prepare(queryRunner SqlQueryRunner, query string) (*sql.Stmt, error) {
-	dbUtils:=extractDbUtils(queryRunner)
-
-	if dbUtils.ctx != nil {
-		return dbUtils.Db.PrepareContext(dbUtils.ctx, query)
-	}
-
-	return dbUtils.Db.Prepare(query)
+	ex, ctx := extractExecutorAndContext(queryRunner)
+	return ex.PrepareContext(ctx, query)
 }
@@ -1,11 +1,15 @@
 package godb
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 	"fmt"
+	"path/filepath"
 	"reflect"
+	"time"
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func initDB() *DbUtils {
@@ -21,6 +25,17 @@ func initDB() *DbUtils {
 	return dbUtils
 }
 
+// initSqliteDB returns a DbUtils on a new SQLite database file named name,
+// closed at the end of the test.
+func initSqliteDB(t *testing.T, name string) *DbUtils {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), name+".db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &DbUtils{Db: db, Dialect: SqliteDialect{}}
+}
+
 
 
 func TestDbUtils_SelectInt(t *testing.T) {
@@ -84,6 +99,60 @@ func TestDbUtils_selectlist(t *testing.T) {
 
 
 
+func TestDbUtils_ContextTimeout(t *testing.T) {
+	dbUtils := initDB()
+	sleep := "select " + MySQLDialect{}.SleepClause(5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := dbUtils.WithContext(ctx).SelectInt(sleep)
+	if err == nil {
+		t.Errorf("statement was not aborted by the context")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("statement ran for %v after the context deadline", elapsed)
+	}
+
+	trans, err := dbUtils.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer trans.Rollback()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	_, err = trans.WithContext(ctx).Exec("do " + MySQLDialect{}.SleepClause(5*time.Second))
+	if err == nil {
+		t.Errorf("statement in transaction was not aborted by the context")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("statement in transaction ran for %v after the context deadline", elapsed)
+	}
+}
+
+func TestDbUtils_ContextCanceled(t *testing.T) {
+	dbUtils := initDB()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	runner := dbUtils.WithContext(ctx)
+	if _, err := runner.Exec("select 1"); err != context.Canceled {
+		t.Errorf("Exec: expected %v, got %v", context.Canceled, err)
+	}
+	if _, err := runner.Query("select 1"); err != context.Canceled {
+		t.Errorf("Query: expected %v, got %v", context.Canceled, err)
+	}
+	var i int64
+	if err := runner.QueryRow("select 1").Scan(&i); err != context.Canceled {
+		t.Errorf("QueryRow: expected %v, got %v", context.Canceled, err)
+	}
+}
+
 func selectInt(dbUtils *DbUtils, query string, args ...interface{}) int64 {
 	i64, err := SelectInt(dbUtils, query, args...)
 	if err != nil {
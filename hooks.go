@@ -0,0 +1,39 @@
+package godb
+
+// HasPostGet provides PostGet() which will be executed after the GET statement.
+type HasPostGet interface {
+	PostGet(SqlQueryRunner) error
+}
+
+// HasPostDelete provides PostDelete() which will be executed after the DELETE statement
+type HasPostDelete interface {
+	PostDelete(SqlQueryRunner) error
+}
+
+// HasPostUpdate provides PostUpdate() which will be executed after the UPDATE statement
+type HasPostUpdate interface {
+	PostUpdate(SqlQueryRunner) error
+}
+
+// HasPostInsert provides PostInsert() which will be executed after the INSERT statement
+type HasPostInsert interface {
+	PostInsert(SqlQueryRunner) error
+}
+
+// HasPreDelete provides PreDelete() which will be executed before the DELETE statement.
+// An error aborts the delete.
+type HasPreDelete interface {
+	PreDelete(SqlQueryRunner) error
+}
+
+// HasPreUpdate provides PreUpdate() which will be executed before the UPDATE statement.
+// An error aborts the update.
+type HasPreUpdate interface {
+	PreUpdate(SqlQueryRunner) error
+}
+
+// HasPreInsert provides PreInsert() which will be executed before the INSERT statement.
+// An error aborts the insert.
+type HasPreInsert interface {
+	PreInsert(SqlQueryRunner) error
+}
@@ -0,0 +1,128 @@
+package godb
+
+import (
+	"errors"
+	"testing"
+)
+
+type HookInvoice struct {
+	Id      int64
+	Created int64
+	Updated int64
+	Memo    string
+
+	events  []string `db:"-"`
+	failPre bool     `db:"-"`
+}
+
+var errHookAbort = errors.New("hook abort")
+
+func (h *HookInvoice) PreInsert(s SqlQueryRunner) error {
+	if h.failPre {
+		return errHookAbort
+	}
+	h.Created = 100
+	h.events = append(h.events, "PreInsert")
+	return nil
+}
+
+func (h *HookInvoice) PostInsert(s SqlQueryRunner) error {
+	h.events = append(h.events, "PostInsert")
+	return nil
+}
+
+func (h *HookInvoice) PreUpdate(s SqlQueryRunner) error {
+	if h.failPre {
+		return errHookAbort
+	}
+	h.Updated = 200
+	h.events = append(h.events, "PreUpdate")
+	return nil
+}
+
+func (h *HookInvoice) PostUpdate(s SqlQueryRunner) error {
+	h.events = append(h.events, "PostUpdate")
+	return nil
+}
+
+func (h *HookInvoice) PreDelete(s SqlQueryRunner) error {
+	if h.failPre {
+		return errHookAbort
+	}
+	h.events = append(h.events, "PreDelete")
+	return nil
+}
+
+func (h *HookInvoice) PostDelete(s SqlQueryRunner) error {
+	h.events = append(h.events, "PostDelete")
+	return nil
+}
+
+func (h *HookInvoice) PostGet(s SqlQueryRunner) error {
+	h.Memo = "loaded " + h.Memo
+	return nil
+}
+
+func Test_Hooks(t *testing.T) {
+	dbmap := initDB()
+	dbmap.AddTableWithName(HookInvoice{}, "hook_invoice_test").SetKeys(true, "Id")
+	dbmap.CreateTablesIfNotExists()
+	defer close(dbmap)
+
+	inv := &HookInvoice{Memo: "hooks"}
+	_insert(dbmap, inv)
+	_update(dbmap, inv)
+
+	obj := _get(dbmap, HookInvoice{}, inv.Id).(*HookInvoice)
+	if obj.Memo != "loaded hooks" {
+		t.Errorf("PostGet was not run: %q", obj.Memo)
+	}
+	if obj.Created != 100 || obj.Updated != 200 {
+		t.Errorf("pre hooks did not change the stored row: %+v", obj)
+	}
+
+	_del(dbmap, inv)
+
+	want := []string{"PreInsert", "PostInsert", "PreUpdate", "PostUpdate", "PreDelete", "PostDelete"}
+	if len(inv.events) != len(want) {
+		t.Fatalf("%v != %v", inv.events, want)
+	}
+	for i := range want {
+		if inv.events[i] != want[i] {
+			t.Errorf("%v != %v", inv.events, want)
+		}
+	}
+}
+
+func Test_HooksAbort(t *testing.T) {
+	dbmap := initDB()
+	dbmap.AddTableWithName(HookInvoice{}, "hook_invoice_test").SetKeys(true, "Id")
+	dbmap.CreateTablesIfNotExists()
+	defer close(dbmap)
+
+	trans, err := dbmap.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer trans.Rollback()
+
+	inv := &HookInvoice{Memo: "aborted", failPre: true}
+	if err := trans.Insert(inv); err != errHookAbort {
+		t.Errorf("expected %v, got %v", errHookAbort, err)
+	}
+	if inv.Id != 0 {
+		t.Errorf("row was inserted with id %d", inv.Id)
+	}
+
+	inv.failPre = false
+	if err := trans.Insert(inv); err != nil {
+		panic(err)
+	}
+	inv.failPre = true
+	if _, err := trans.Update(inv); err != errHookAbort {
+		t.Errorf("expected %v, got %v", errHookAbort, err)
+	}
+	if _, err := trans.Delete(inv); err != errHookAbort {
+		t.Errorf("expected %v, got %v", errHookAbort, err)
+	}
+}
@@ -1,5 +1,10 @@
 package godb
 
+import (
+	"fmt"
+	"strings"
+)
+
 type IndexMap struct {
 	// Index name in db table
 	IndexName string
@@ -34,4 +39,73 @@ func (idx *IndexMap) SetUnique(b bool) *IndexMap {
 func (idx *IndexMap) SetIndexType(indtype string) *IndexMap {
 	idx.IndexType = indtype
 	return idx
-}
\ No newline at end of file
+}
+
+// Indexer is implemented by dialects with their own syntax for creating
+// and dropping indexes. Other dialects use "create [unique] index name on
+// table (columns)" and "drop index name".
+type Indexer interface {
+	// CreateIndexSql returns the statement creating index on the columns
+	// of the table.
+	CreateIndexSql(schema, table string, index *IndexMap, columns []string) string
+
+	// DropIndexSql returns the statement dropping the index of the table.
+	DropIndexSql(schema, table, index string) string
+}
+
+// indexMethod returns the index type as spelled in "using" clauses, like
+// "btree" for "B-tree".
+func indexMethod(indexType string) string {
+	return strings.ToLower(strings.Replace(indexType, "-", "", -1))
+}
+
+// createIndexSql returns the statement creating idx, with the columns
+// given by field or column name resolved to column names.
+func (t *TableMap) createIndexSql(idx *IndexMap) string {
+	dialect := t.dbUtils.Dialect
+	columns := make([]string, len(idx.columns))
+	for i, name := range idx.columns {
+		columns[i] = name
+		if col := colMapOrNil(t, name); col != nil {
+			columns[i] = col.ColumnName
+		}
+	}
+
+	if indexer, ok := dialect.(Indexer); ok {
+		return indexer.CreateIndexSql(t.SchemaName, t.TableName, idx, columns)
+	}
+
+	s := "create"
+	if idx.Unique {
+		s += " unique"
+	}
+	return fmt.Sprintf("%s index %s on %s (%s)%s", s, dialect.QuoteField(idx.IndexName),
+		dialect.QuotedTableForQuery(t.SchemaName, t.TableName), quoteFields(dialect, columns, ""), dialect.QuerySuffix())
+}
+
+func (t *TableMap) dropIndexSql(name string) string {
+	dialect := t.dbUtils.Dialect
+	if indexer, ok := dialect.(Indexer); ok {
+		return indexer.DropIndexSql(t.SchemaName, t.TableName, name)
+	}
+	return fmt.Sprintf("drop index %s%s", dialect.QuoteField(name), dialect.QuerySuffix())
+}
+
+// CreateIndex creates the indexes added to the table with AddIndex.
+func (t *TableMap) CreateIndex() error {
+	for _, idx := range t.indexes {
+		if _, err := t.dbUtils.Exec(t.createIndexSql(idx)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropIndex drops the index of the table with the given name.
+func (t *TableMap) DropIndex(name string) error {
+	if t.IdxMap(name) == nil {
+		return fmt.Errorf("godb: no index %s in table %s", name, t.TableName)
+	}
+	_, err := t.dbUtils.Exec(t.dropIndexSql(name))
+	return err
+}
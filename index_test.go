@@ -0,0 +1,103 @@
+package godb
+
+import (
+	"strings"
+	"testing"
+)
+
+type IndexedPerson struct {
+	Id      int64  `db:"id, primarykey, autoincrement"`
+	Name    string `db:"name, size:50"`
+	Email   string `db:"email, size:100"`
+	Comment string `db:"-"`
+}
+
+func TestTableMap_createIndexSql(t *testing.T) {
+	tests := []struct {
+		dialect      Dialect
+		create, drop string
+	}{
+		{MySQLDialect{}, "create unique index `person_name` on app.`person` (`name`,`email`) using btree;", "drop index `person_name` on app.`person`;"},
+		{PostgresDialect{}, `create unique index "person_name" on app."person" using btree ("name","email");`, `drop index app."person_name";`},
+		{SqliteDialect{}, `create unique index "person_name" on "person" ("name","email");`, `drop index "person_name";`},
+		{SqlServerDialect{}, "create unique index [person_name] on [app].[person] ([name],[email]);", "drop index [person_name] on [app].[person];"},
+		{Db2Dialect{}, `create unique index "APP"."PERSON_NAME" on "APP"."PERSON" ("NAME","EMAIL")`, `drop index "APP"."PERSON_NAME"`},
+		{OracleDialect{}, `create unique index app."PERSON_NAME" on app."PERSON" ("NAME","EMAIL")`, `drop index app."PERSON_NAME"`},
+	}
+	for _, test := range tests {
+		dbUtils := &DbUtils{Dialect: test.dialect}
+		table := dbUtils.AddTableWithNameAndSchema(IndexedPerson{}, "app", "person")
+		idx := table.AddIndex("person_name", "B-tree", []string{"Name", "email"}).SetUnique(true)
+
+		if create := table.createIndexSql(idx); create != test.create {
+			t.Errorf("%T: got %s, want %s", test.dialect, create, test.create)
+		}
+		if drop := table.dropIndexSql("person_name"); drop != test.drop {
+			t.Errorf("%T: got %s, want %s", test.dialect, drop, test.drop)
+		}
+	}
+
+	dbUtils := &DbUtils{Dialect: PostgresDialect{}}
+	idx := dbUtils.AddTable(IndexedPerson{}).AddIndex("person_email", "GIN", []string{"Email"})
+	if create := dbUtils.tables[0].createIndexSql(idx); !strings.Contains(create, " using gin (") {
+		t.Errorf("unexpected index type in %s", create)
+	}
+}
+
+func TestTableMap_AddIndexUnknownColumn(t *testing.T) {
+	table := (&DbUtils{Dialect: MySQLDialect{}}).AddTable(IndexedPerson{})
+	for _, column := range []string{"Missing", "Comment"} {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(r.(string), column) {
+					t.Errorf("expected a panic naming %s, got %v", column, r)
+				}
+			}()
+			table.AddIndex("bad", "", []string{"Name", column})
+		}()
+	}
+	if table.IdxMap("bad") != nil {
+		t.Error("index on an unknown column was added")
+	}
+}
+
+func Test_CreateIndex(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	dbmap.Exec("drop table if exists indexed_person_test")
+	defer dbmap.Exec("drop table if exists indexed_person_test")
+
+	table := dbmap.AddTableWithName(IndexedPerson{}, "indexed_person_test")
+	table.AddIndex("person_name", "Btree", []string{"Name"})
+	table.AddIndex("person_email", "Hash", []string{"Email"}).SetUnique(true)
+	if err := dbmap.CreateTablesIfNotExists(); err != nil {
+		panic(err)
+	}
+
+	if err := dbmap.CreateIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbmap.CreateIndexesIfNotExists(); err != nil {
+		t.Errorf("indexes were created twice: %v", err)
+	}
+
+	s, err := dbmap.InspectSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := s.Table("indexed_person_test")
+	if idx := actual.Index("person_email"); idx == nil || !idx.Unique {
+		t.Errorf("unique index was not created: %+v", actual.Indexes)
+	}
+
+	if err := dbmap.DropIndex("person_name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbmap.DropIndex("missing"); err == nil {
+		t.Error("expected an error for an unknown index")
+	}
+	if err := dbmap.CreateIndexesIfNotExists(); err != nil {
+		t.Errorf("dropped index was not recreated: %v", err)
+	}
+}
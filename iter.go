@@ -0,0 +1,92 @@
+package godb
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// RowIter is a cursor over the rows of a query, returned by SelectIter.
+// Rows are scanned one at a time, so that result sets larger than the
+// memory can be read. It must be closed.
+type RowIter struct {
+	rows    *sql.Rows
+	t       reflect.Type
+	scanner *rowScanner
+	err     error
+
+	// nonFatalErr is reported by Err once the rows are read
+	nonFatalErr error
+}
+
+// selectIter runs query and returns a cursor scanning its rows into values
+// of the type of holderType, a struct or the type of a single column.
+func selectIter(dbUtils *DbUtils, queryRunner SqlQueryRunner, holderType interface{}, query string,
+	args ...interface{}) (*RowIter, error) {
+
+	if holderType == nil {
+		return nil, fmt.Errorf("godb: SelectIter needs a holder type")
+	}
+	t := reflect.TypeOf(holderType)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if len(args) == 1 {
+		query, args = maybeExpandNamedQuery(dbUtils, query, args)
+	}
+
+	rows, err := queryRunner.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	scanner, err := newRowScanner(dbUtils, rows, t, t.Kind() == reflect.Struct)
+	if err != nil && !NonFatalError(err) {
+		rows.Close()
+		return nil, err
+	}
+	return &RowIter{rows: rows, t: t, scanner: scanner, nonFatalErr: err}, nil
+}
+
+// Next prepares the next row for Scan. It returns false when there are no
+// more rows or an error occurred, which Err reports. The cursor is closed
+// after the last row.
+func (it *RowIter) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	return it.rows.Next()
+}
+
+// Scan scans the current row into dst, a pointer to the holder type.
+// Fields without a column keep their value, so that dst can be reused
+// from row to row.
+func (it *RowIter) Scan(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Type().Elem() != it.t {
+		return fmt.Errorf("godb: Scan needs a non-nil *%v, got %T", it.t, dst)
+	}
+	if err := it.scanner.scan(it.rows, v.Elem()); err != nil {
+		it.err = err
+		return err
+	}
+	return nil
+}
+
+// Err returns the error that stopped the iteration, if any. Otherwise, as
+// with Select, a NonFatalError reports the columns of the query without a
+// field in the holder type.
+func (it *RowIter) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	if err := it.rows.Err(); err != nil {
+		return err
+	}
+	return it.nonFatalErr
+}
+
+// Close closes the cursor. It may be called several times.
+func (it *RowIter) Close() error {
+	return it.rows.Close()
+}
@@ -0,0 +1,43 @@
+//go:build go1.23
+
+package godb
+
+import (
+	"fmt"
+	"iter"
+)
+
+// SelectSeq runs query and yields a new T per row, T being a struct or the
+// type of a single column. The rows are read as the loop advances, and
+// the cursor is closed when it ends. An error is yielded with the zero T,
+// and ends the iteration. A NonFatalError is yielded after the last row.
+func SelectSeq[T any](runner SqlQueryRunner, query string, args ...interface{}) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		dbUtils := extractDbUtils(runner)
+		if dbUtils == nil {
+			yield(zero, fmt.Errorf("godb: SelectSeq needs a *DbUtils or a *Transaction, got %T", runner))
+			return
+		}
+		it, err := selectIter(dbUtils, runner, (*T)(nil), query, args...)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer it.Close()
+
+		for it.Next() {
+			var v T
+			if err := it.Scan(&v); err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+//go:build go1.23
+
+package godb
+
+import (
+	"testing"
+)
+
+func Test_SelectSeq(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	dbmap.AddTableWithName(IterPerson{}, "iter_person_test")
+	dbmap.DropTablesIfExists()
+	if err := dbmap.CreateTables(); err != nil {
+		panic(err)
+	}
+	defer dbmap.DropTablesIfExists()
+	for _, name := range []string{"a", "b", "c"} {
+		_insert(dbmap, &IterPerson{Name: name})
+	}
+
+	var names string
+	for p, err := range SelectSeq[IterPerson](dbmap, "select * from iter_person_test order by id") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names += p.Name
+		if p.Name == "b" {
+			break
+		}
+	}
+	if names != "ab" {
+		t.Errorf("iterated over %q", names)
+	}
+
+	for _, err := range SelectSeq[IterPerson](dbmap, "select * from missing_table") {
+		if err == nil {
+			t.Error("expected an error for a missing table")
+		}
+	}
+}
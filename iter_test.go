@@ -0,0 +1,99 @@
+package godb
+
+import (
+	"testing"
+)
+
+type IterPerson struct {
+	Id   int64  `db:"id, primarykey, autoincrement"`
+	Name string `db:"name, size:50"`
+}
+
+func Test_SelectIter(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	dbmap.AddTableWithName(IterPerson{}, "iter_person_test")
+	dbmap.DropTablesIfExists()
+	if err := dbmap.CreateTables(); err != nil {
+		panic(err)
+	}
+	defer dbmap.DropTablesIfExists()
+	for _, name := range []string{"a", "b", "c"} {
+		_insert(dbmap, &IterPerson{Name: name})
+	}
+
+	it, err := dbmap.SelectIter(IterPerson{}, "select * from iter_person_test order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	var names string
+	var p IterPerson
+	for it.Next() {
+		if err := it.Scan(&p); err != nil {
+			t.Fatal(err)
+		}
+		names += p.Name
+	}
+	if err := it.Err(); err != nil || names != "abc" {
+		t.Errorf("iterated over %q: %v", names, err)
+	}
+	if err := it.Close(); err != nil {
+		t.Error(err)
+	}
+
+	ids, err := dbmap.SelectIter(int64(0), "select id from iter_person_test where name <> ?", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ids.Close()
+	count := 0
+	for ids.Next() {
+		var id int64
+		if err := ids.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected 2 ids, got %d", count)
+	}
+
+	bad, err := dbmap.SelectIter(IterPerson{}, "select * from iter_person_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bad.Close()
+	bad.Next()
+	if err := bad.Scan(p); err == nil {
+		t.Error("expected an error for a non-pointer destination")
+	}
+}
+
+func Test_SelectIterNonFatalError(t *testing.T) {
+	dbmap := initSqliteDB(t, "iter")
+	dbmap.AddTableWithName(IterPerson{}, "iter_person_test")
+	if err := dbmap.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbmap.Insert(&IterPerson{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := dbmap.SelectIter(IterPerson{}, "select id, name, 1 as missing from iter_person_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	count := 0
+	for it.Next() {
+		var p IterPerson
+		if err := it.Scan(&p); err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+	if err := it.Err(); !NonFatalError(err) || count != 1 {
+		t.Errorf("expected 1 row and a NonFatalError, got %d: %v", count, err)
+	}
+}
@@ -0,0 +1,120 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// QueryEvent describes a statement run by a DbUtils or a Transaction.
+type QueryEvent struct {
+	Query string
+
+	// Args holds the arguments of the statement, as returned by the
+	// ArgRedactor of the DbUtils if one is set.
+	Args []interface{}
+
+	Duration time.Duration
+
+	// RowsAffected is the number of rows changed by an Exec statement, or
+	// -1 for queries and when the driver does not report it.
+	RowsAffected int64
+
+	Err error
+
+	// TxID is the ID of the transaction running the statement, or empty
+	// outside a transaction.
+	TxID string
+}
+
+// QueryLogger receives an event for each statement once it has run.
+type QueryLogger interface {
+	LogQuery(ctx context.Context, event QueryEvent)
+}
+
+// QueryTracer is a QueryLogger also notified before a statement runs. The
+// context returned by TraceQueryStart is passed to the database driver and
+// to LogQuery, so a tracer can store a span in it.
+type QueryTracer interface {
+	QueryLogger
+	TraceQueryStart(ctx context.Context, query string, args []interface{}) context.Context
+}
+
+// ArgRedactor returns the arguments of query as they should be logged, for
+// instance with passwords or personal data replaced. It must not modify
+// args, which are the arguments passed to the database.
+type ArgRedactor func(query string, args []interface{}) []interface{}
+
+// NopLogger is a QueryLogger discarding every event. It is used when no
+// logger is set on the DbUtils.
+type NopLogger struct{}
+
+func (NopLogger) LogQuery(ctx context.Context, event QueryEvent) {}
+
+var txCounter uint64
+
+func nextTxID() string {
+	return strconv.FormatUint(atomic.AddUint64(&txCounter, 1), 10)
+}
+
+// queryTrace measures a statement for the logger of a DbUtils.
+type queryTrace struct {
+	logger QueryLogger
+	ctx    context.Context
+	start  time.Time
+	event  QueryEvent
+}
+
+// traceQuery starts measuring query for the logger of the DbUtils behind
+// queryRunner. It returns a nil trace when no logger is set, and the
+// context the statement should run with.
+func traceQuery(queryRunner SqlQueryRunner, ctx context.Context, query string, args []interface{}) (*queryTrace, context.Context) {
+	var txID string
+	dbUtils := extractDbUtils(queryRunner)
+	if t, ok := queryRunner.(*Transaction); ok {
+		txID = t.id
+	}
+	if dbUtils == nil || dbUtils.Logger == nil {
+		return nil, ctx
+	}
+	if _, ok := dbUtils.Logger.(NopLogger); ok {
+		return nil, ctx
+	}
+
+	logged := args
+	if dbUtils.ArgRedactor != nil {
+		logged = dbUtils.ArgRedactor(query, args)
+	}
+	if tracer, ok := dbUtils.Logger.(QueryTracer); ok {
+		ctx = tracer.TraceQueryStart(ctx, query, logged)
+	}
+
+	return &queryTrace{
+		logger: dbUtils.Logger,
+		ctx:    ctx,
+		start:  time.Now(),
+		event: QueryEvent{
+			Query:        query,
+			Args:         logged,
+			RowsAffected: -1,
+			TxID:         txID,
+		},
+	}, ctx
+}
+
+// done reports the statement to the logger. result is nil for queries.
+func (t *queryTrace) done(result sql.Result, err error) {
+	if t == nil {
+		return
+	}
+	t.event.Duration = time.Since(t.start)
+	t.event.Err = err
+	if result != nil && err == nil {
+		if rows, rerr := result.RowsAffected(); rerr == nil {
+			t.event.RowsAffected = rows
+		}
+	}
+	t.logger.LogQuery(t.ctx, t.event)
+}
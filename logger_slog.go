@@ -0,0 +1,50 @@
+//go:build go1.21
+
+package godb
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger is a QueryLogger writing events to a slog.Logger. Statements
+// are logged at Level, and failed statements at slog.LevelError.
+type SlogLogger struct {
+	Logger *slog.Logger
+	Level  slog.Level
+}
+
+// NewSlogLogger returns a SlogLogger logging statements to l at debug
+// level. slog.Default() is used when l is nil.
+func NewSlogLogger(l *slog.Logger) *SlogLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogLogger{Logger: l, Level: slog.LevelDebug}
+}
+
+func (s *SlogLogger) LogQuery(ctx context.Context, event QueryEvent) {
+	level := s.Level
+	if event.Err != nil {
+		level = slog.LevelError
+	}
+	if !s.Logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("query", event.Query),
+		slog.Any("args", event.Args),
+		slog.Duration("duration", event.Duration),
+	}
+	if event.RowsAffected >= 0 {
+		attrs = append(attrs, slog.Int64("rows", event.RowsAffected))
+	}
+	if event.TxID != "" {
+		attrs = append(attrs, slog.String("tx", event.TxID))
+	}
+	if event.Err != nil {
+		attrs = append(attrs, slog.Any("error", event.Err))
+	}
+	s.Logger.LogAttrs(ctx, level, "godb: query", attrs...)
+}
@@ -0,0 +1,119 @@
+package godb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingLogger struct {
+	mu     sync.Mutex
+	events []QueryEvent
+	starts int
+}
+
+type traceKey struct{}
+
+func (r *recordingLogger) TraceQueryStart(ctx context.Context, query string, args []interface{}) context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.starts++
+	return context.WithValue(ctx, traceKey{}, query)
+}
+
+func (r *recordingLogger) LogQuery(ctx context.Context, event QueryEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ctx.Value(traceKey{}) != event.Query {
+		event.Err = errors.New("context of TraceQueryStart was not passed to LogQuery")
+	}
+	r.events = append(r.events, event)
+}
+
+func Test_QueryLogger(t *testing.T) {
+	dbmap := initDB()
+	dbmap.AddTableWithName(Invoice{}, "invoice_log_test").SetKeys(true, "Id")
+	dbmap.CreateTablesIfNotExists()
+	defer close(dbmap)
+
+	logger := &recordingLogger{}
+	dbmap.Logger = logger
+	dbmap.ArgRedactor = func(query string, args []interface{}) []interface{} {
+		redacted := make([]interface{}, len(args))
+		for i := range redacted {
+			redacted[i] = "***"
+		}
+		return redacted
+	}
+	defer func() { dbmap.Logger = nil }()
+
+	trans, err := dbmap.Begin()
+	if err != nil {
+		panic(err)
+	}
+	inv := &Invoice{Memo: "secret", IsPaid: true}
+	if err := trans.Insert(inv); err != nil {
+		panic(err)
+	}
+	if _, err := trans.Exec("update invoice_log_test set Memo=? where Id=?", "other", inv.Id); err != nil {
+		panic(err)
+	}
+	if err := trans.Commit(); err != nil {
+		panic(err)
+	}
+	if _, err := dbmap.Exec("select * from missing_table_log_test"); err == nil {
+		t.Fatal("expected an error for a missing table")
+	}
+
+	if len(logger.events) != 3 || logger.starts != 3 {
+		t.Fatalf("expected 3 traced statements, got %d events and %d starts", len(logger.events), logger.starts)
+	}
+	update := logger.events[1]
+	if update.TxID == "" || update.TxID != trans.ID() || logger.events[0].TxID != trans.ID() {
+		t.Errorf("statements in transaction %q reported transaction %q", trans.ID(), update.TxID)
+	}
+	if update.RowsAffected != 1 {
+		t.Errorf("RowsAffected %d != 1", update.RowsAffected)
+	}
+	for _, arg := range update.Args {
+		if arg != "***" {
+			t.Errorf("argument was not redacted: %v", update.Args)
+		}
+	}
+	failed := logger.events[2]
+	if failed.Err == nil || failed.TxID != "" {
+		t.Errorf("unexpected event for failed statement: %+v", failed)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	logger.LogQuery(context.Background(), QueryEvent{Query: "select 1", RowsAffected: -1})
+	if buf.Len() != 0 {
+		t.Errorf("debug event logged at info level: %s", buf.String())
+	}
+
+	logger.Level = slog.LevelInfo
+	logger.LogQuery(context.Background(), QueryEvent{
+		Query:        "update t set a=?",
+		Args:         []interface{}{1},
+		Duration:     time.Millisecond,
+		RowsAffected: 2,
+		TxID:         "7",
+	})
+	logger.LogQuery(context.Background(), QueryEvent{Query: "select", RowsAffected: -1, Err: errors.New("syntax error")})
+
+	out := buf.String()
+	for _, want := range []string{`query="update t set a=?"`, "args=[1]", "duration=1ms", "rows=2", "tx=7", "level=ERROR", `error="syntax error"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q not in %s", want, out)
+		}
+	}
+}
@@ -0,0 +1,593 @@
+package godb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// DefaultMigrationTable is the table recording applied migrations.
+	DefaultMigrationTable = "godb_migrations"
+
+	// DefaultMigrationLockTable is the table holding the migration lock.
+	DefaultMigrationLockTable = "godb_migrations_lock"
+
+	// DefaultMigrationLockTimeout is how long a Migrator waits for the lock
+	// held by another process when LockTimeout is not set.
+	DefaultMigrationLockTimeout = time.Minute
+)
+
+// TransactionalDDLer is implemented by dialects that can roll back schema
+// changes. Migrations run in a transaction when TransactionalDDL returns
+// true.
+type TransactionalDDLer interface {
+	TransactionalDDL() bool
+}
+
+// MigrationFunc is a migration step written in Go.
+type MigrationFunc func(SqlQueryRunner) error
+
+// Migration is a versioned schema change. A step is either a MigrationFunc
+// or SQL statements separated by semicolons; Up takes precedence over UpSql
+// and Down over DownSql.
+type Migration struct {
+	Version int64
+	Name    string
+
+	Up      MigrationFunc
+	Down    MigrationFunc
+	UpSql   string
+	DownSql string
+
+	// NoTransaction runs the migration outside a transaction even when the
+	// dialect supports transactional DDL, for statements like PostgreSQL's
+	// "create index concurrently". A .sql file requests it with a
+	// "-- godb:no-transaction" line.
+	NoTransaction bool
+}
+
+// checksum identifies the SQL of the up and down steps, to detect
+// migrations edited after they were applied. Migrations written in Go are
+// not checked. Without down step, the SQL of the up step alone is hashed.
+func (mig *Migration) checksum() string {
+	sql := mig.UpSql
+	if mig.DownSql != "" {
+		sql += "\x00" + mig.DownSql
+	}
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+func (mig *Migration) step(up bool) (MigrationFunc, error) {
+	fn, stmts := mig.Down, mig.DownSql
+	if up {
+		fn, stmts = mig.Up, mig.UpSql
+	}
+	if fn != nil {
+		return fn, nil
+	}
+	if strings.TrimSpace(stmts) == "" {
+		if up {
+			return nil, fmt.Errorf("godb: migration %d has no up step", mig.Version)
+		}
+		return nil, fmt.Errorf("godb: migration %d has no down step", mig.Version)
+	}
+	return func(queryRunner SqlQueryRunner) error {
+		for _, stmt := range splitSqlStatements(stmts) {
+			if _, err := queryRunner.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// MigrationStatus describes a migration known to a Migrator or recorded in
+// its table.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+
+	// Modified is true if the SQL of the migration changed since it was
+	// applied.
+	Modified bool
+
+	// Missing is true if the migration was applied but is not known to the
+	// Migrator.
+	Missing bool
+}
+
+// Migrator applies migrations to the database of a DbUtils, recording the
+// applied versions in TableName. A lock row in LockTableName ensures only
+// one process migrates at a time.
+type Migrator struct {
+	TableName     string
+	LockTableName string
+
+	// LockTimeout is how long to wait for the lock held by another
+	// process. DefaultMigrationLockTimeout is used when zero.
+	LockTimeout time.Duration
+
+	dbUtils    *DbUtils
+	migrations []*Migration
+}
+
+// Migrator returns a Migrator running its statements with dbUtils, which
+// should be the *DbUtils returned by WithContext to migrate with a
+// context.
+func (dbUtils *DbUtils) Migrator() *Migrator {
+	return &Migrator{
+		TableName:     DefaultMigrationTable,
+		LockTableName: DefaultMigrationLockTable,
+		dbUtils:       dbUtils,
+	}
+}
+
+// Add registers migrations. It panics if a version is not positive, is
+// already registered, or has no up step.
+func (m *Migrator) Add(migrations ...*Migration) *Migrator {
+	for _, mig := range migrations {
+		if mig.Version <= 0 {
+			panic(fmt.Sprintf("godb: migration %q has invalid version %d", mig.Name, mig.Version))
+		}
+		if mig.Up == nil && strings.TrimSpace(mig.UpSql) == "" {
+			panic(fmt.Sprintf("godb: migration %d has no up step", mig.Version))
+		}
+		if m.migration(mig.Version) != nil {
+			panic(fmt.Sprintf("godb: migration %d registered twice", mig.Version))
+		}
+		m.migrations = append(m.migrations, mig)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+	return m
+}
+
+// AddFunc registers a migration written in Go. down may be nil.
+func (m *Migrator) AddFunc(version int64, name string, up, down MigrationFunc) *Migrator {
+	return m.Add(&Migration{Version: version, Name: name, Up: up, Down: down})
+}
+
+// AddSql registers a migration made of SQL statements separated by
+// semicolons. down may be empty.
+func (m *Migrator) AddSql(version int64, name, up, down string) *Migrator {
+	return m.Add(&Migration{Version: version, Name: name, UpSql: up, DownSql: down})
+}
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// AddFS registers the migrations of dir in fsys, named like
+// "0001_create_users.up.sql" and "0001_create_users.down.sql". Files are
+// split into statements on semicolons outside quotes and comments, so
+// steps containing procedural blocks must be written in Go.
+func (m *Migrator) AddFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	var found []*Migration
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := migrationFileRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return fmt.Errorf("godb: migration file %s is not named <version>_<name>.up.sql or <version>_<name>.down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("godb: migration file %s: %w", entry.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+			found = append(found, mig)
+		} else if mig.Name != match[2] {
+			return fmt.Errorf("godb: migration %d is named both %s and %s", version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			mig.UpSql = string(data)
+			mig.NoTransaction = noTransactionDirective(mig.UpSql)
+		} else {
+			mig.DownSql = string(data)
+		}
+	}
+
+	for _, mig := range found {
+		if mig.UpSql == "" {
+			return fmt.Errorf("godb: migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		if m.migration(mig.Version) != nil {
+			return fmt.Errorf("godb: migration %d registered twice", mig.Version)
+		}
+	}
+	m.Add(found...)
+	return nil
+}
+
+func noTransactionDirective(stmts string) bool {
+	for _, line := range strings.Split(stmts, "\n") {
+		if strings.TrimSpace(line) == "-- godb:no-transaction" {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Migrator) migration(version int64) *Migration {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig
+		}
+	}
+	return nil
+}
+
+// Up applies every pending migration and returns the number applied.
+func (m *Migrator) Up() (int, error) {
+	return m.run(true, -1)
+}
+
+// UpTo applies the pending migrations up to and including version.
+func (m *Migrator) UpTo(version int64) (int, error) {
+	return m.run(true, version)
+}
+
+// Down rolls back the last applied migration.
+func (m *Migrator) Down() (int, error) {
+	return m.run(false, -1)
+}
+
+// DownTo rolls back the applied migrations newer than version, in reverse
+// order. DownTo(0) rolls back every migration.
+func (m *Migrator) DownTo(version int64) (int, error) {
+	return m.run(false, version)
+}
+
+// Status returns the migrations known to the Migrator or recorded as
+// applied, ordered by version.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	h := m.history()
+	if err := h.CreateTablesIfNotExists(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(h)
+	if err != nil {
+		return nil, err
+	}
+
+	var status []MigrationStatus
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if rec, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = rec.appliedAt()
+			s.Modified = rec.Checksum != mig.checksum()
+		}
+		status = append(status, s)
+	}
+	for _, rec := range applied {
+		if m.migration(rec.Version) == nil {
+			status = append(status, MigrationStatus{Version: rec.Version, Name: rec.Name,
+				Applied: true, AppliedAt: rec.appliedAt(), Missing: true})
+		}
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+	return status, nil
+}
+
+// ForceUnlock releases the migration lock left by a process that did not
+// finish migrating.
+func (m *Migrator) ForceUnlock() error {
+	h := m.history()
+	if err := h.CreateTablesIfNotExists(); err != nil {
+		return err
+	}
+	_, err := h.Delete(&migrationLock{Id: 1})
+	return err
+}
+
+// run applies the pending migrations up to target, or rolls back the
+// applied migrations newer than target. A negative target means every
+// migration when applying and the last one when rolling back.
+func (m *Migrator) run(up bool, target int64) (int, error) {
+	h := m.history()
+	if err := h.CreateTablesIfNotExists(); err != nil {
+		return 0, err
+	}
+	if err := m.lock(h); err != nil {
+		return 0, err
+	}
+	// release the lock even if the context of the migration is done
+	defer h.WithContext(context.Background()).Delete(&migrationLock{Id: 1})
+
+	applied, err := m.applied(h)
+	if err != nil {
+		return 0, err
+	}
+	for _, rec := range applied {
+		if mig := m.migration(rec.Version); mig != nil && rec.Checksum != mig.checksum() {
+			return 0, &MigrationChecksumError{Version: rec.Version, Name: rec.Name}
+		}
+	}
+
+	var todo []*Migration
+	if up {
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok && (target < 0 || mig.Version <= target) {
+				todo = append(todo, mig)
+			}
+		}
+	} else {
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			if target < 0 || version > target {
+				versions = append(versions, version)
+			}
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		if target < 0 && len(versions) > 1 {
+			versions = versions[:1]
+		}
+		for _, version := range versions {
+			mig := m.migration(version)
+			if mig == nil {
+				return 0, fmt.Errorf("godb: applied migration %d is unknown", version)
+			}
+			todo = append(todo, mig)
+		}
+	}
+
+	for i, mig := range todo {
+		if err := m.apply(h, mig, up); err != nil {
+			return i, err
+		}
+	}
+	return len(todo), nil
+}
+
+// apply runs a step of mig and records it in the same transaction when the
+// dialect supports transactional DDL.
+func (m *Migrator) apply(h *DbUtils, mig *Migration, up bool) error {
+	step, err := mig.step(up)
+	if err != nil {
+		return err
+	}
+	record := func(queryRunner SqlQueryRunner) error {
+		if up {
+			return queryRunner.Insert(&migrationRecord{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				Checksum:  mig.checksum(),
+				AppliedAt: time.Now().UTC().Format(time.RFC3339Nano),
+			})
+		}
+		_, err := queryRunner.Delete(&migrationRecord{Version: mig.Version})
+		return err
+	}
+
+	ddl, ok := m.dbUtils.Dialect.(TransactionalDDLer)
+	if !ok || !ddl.TransactionalDDL() || mig.NoTransaction {
+		if err := step(m.dbUtils); err != nil {
+			return &MigrationError{Version: mig.Version, Name: mig.Name, Up: up, Err: err}
+		}
+		return record(h)
+	}
+
+	trans, err := m.dbUtils.Begin()
+	if err != nil {
+		return err
+	}
+	if err := step(trans); err != nil {
+		trans.Rollback()
+		return &MigrationError{Version: mig.Version, Name: mig.Name, Up: up, Err: err}
+	}
+	// record with the history tables, in the transaction of the step
+	err = record(trans.withDbUtils(h))
+	if err != nil {
+		trans.Rollback()
+		return err
+	}
+	return trans.Commit()
+}
+
+// lock inserts the lock row, waiting up to LockTimeout while another
+// process holds it.
+func (m *Migrator) lock(h *DbUtils) error {
+	timeout := m.LockTimeout
+	if timeout == 0 {
+		timeout = DefaultMigrationLockTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", host, os.Getpid())
+	released := false
+	for {
+		err := h.Insert(&migrationLock{Id: 1, LockedAt: time.Now().UTC().Format(time.RFC3339Nano), Owner: owner})
+		if err == nil {
+			return nil
+		}
+		held, gerr := h.Get(migrationLock{}, 1)
+		if gerr != nil {
+			return gerr
+		}
+		if held == nil {
+			if released {
+				// the insert failed again for another reason than an
+				// existing lock
+				return err
+			}
+			// the holder released the lock since the insert, try again
+			released = true
+			continue
+		}
+		released = false
+		if time.Now().After(deadline) {
+			lock := held.(*migrationLock)
+			return &MigrationLockedError{Owner: lock.Owner, LockedAt: lock.LockedAt}
+		}
+
+		ctx := h.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func (m *Migrator) applied(h *DbUtils) (map[int64]*migrationRecord, error) {
+	var records []*migrationRecord
+	if err := h.From(&migrationRecord{}).SelectInto(&records); err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]*migrationRecord, len(records))
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+	return applied, nil
+}
+
+// history returns a DbUtils sharing the connection of the Migrator with the
+// migration tables mapped, so they are not added to the tables of the
+// application.
+func (m *Migrator) history() *DbUtils {
+	h := &DbUtils{
+		ctx:         m.dbUtils.ctx,
+		Db:          m.dbUtils.Db,
+		Dialect:     m.dbUtils.Dialect,
+		Logger:      m.dbUtils.Logger,
+		ArgRedactor: m.dbUtils.ArgRedactor,
+	}
+	h.AddTableWithName(migrationRecord{}, m.TableName)
+	h.AddTableWithName(migrationLock{}, m.LockTableName)
+	return h
+}
+
+type migrationRecord struct {
+	Version   int64  `db:"version, primarykey"`
+	Name      string `db:"name, size:255"`
+	Checksum  string `db:"checksum, size:64"`
+	AppliedAt string `db:"applied_at, size:40"`
+}
+
+func (rec *migrationRecord) appliedAt() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, rec.AppliedAt)
+	return t
+}
+
+type migrationLock struct {
+	Id       int64  `db:"id, primarykey"`
+	LockedAt string `db:"locked_at, size:40"`
+	Owner    string `db:"owner, size:255"`
+}
+
+// splitSqlStatements splits stmts on the semicolons outside quoted strings,
+// quoted identifiers, comments and PostgreSQL dollar-quoted strings, and
+// drops the empty statements.
+func splitSqlStatements(stmts string) []string {
+	var (
+		result []string
+		start  int
+	)
+	add := func(stmt string) {
+		if stmt = strings.TrimSpace(stmt); stmt != "" && !onlyComments(stmt) {
+			result = append(result, stmt)
+		}
+	}
+
+	for i := 0; i < len(stmts); i++ {
+		switch c := stmts[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(stmts[i+1:], c)
+			if end < 0 {
+				i = len(stmts)
+			} else {
+				i += end + 1
+			}
+		case c == '-' && strings.HasPrefix(stmts[i:], "--"):
+			end := strings.IndexByte(stmts[i:], '\n')
+			if end < 0 {
+				i = len(stmts)
+			} else {
+				i += end
+			}
+		case c == '/' && strings.HasPrefix(stmts[i:], "/*"):
+			end := strings.Index(stmts[i+2:], "*/")
+			if end < 0 {
+				i = len(stmts)
+			} else {
+				i += end + 3
+			}
+		case c == '$':
+			tag := dollarQuoteTag(stmts[i:])
+			if tag == "" {
+				continue
+			}
+			end := strings.Index(stmts[i+len(tag):], tag)
+			if end < 0 {
+				i = len(stmts)
+			} else {
+				i += len(tag) + end + len(tag) - 1
+			}
+		case c == ';':
+			add(stmts[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(stmts) {
+		add(stmts[start:])
+	}
+	return result
+}
+
+// dollarQuoteTag returns the "$tag$" opening a PostgreSQL dollar-quoted
+// string at the start of s, or "".
+func dollarQuoteTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// onlyComments reports whether stmt holds nothing but line comments.
+func onlyComments(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
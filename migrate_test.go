@@ -0,0 +1,162 @@
+package godb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSplitSqlStatements(t *testing.T) {
+	stmts := `-- create the table
+create table a (id int, memo varchar(10) default 'x;y');
+insert into a values (1, 'it''s; fine');
+/* a; comment */ insert into "a;b" values (2, $$;$$);
+create function f() returns int as $body$ begin return 1; end; $body$ language plpgsql;
+-- trailing comment
+`
+	want := []string{
+		"-- create the table\ncreate table a (id int, memo varchar(10) default 'x;y')",
+		"insert into a values (1, 'it''s; fine')",
+		`/* a; comment */ insert into "a;b" values (2, $$;$$)`,
+		"create function f() returns int as $body$ begin return 1; end; $body$ language plpgsql",
+	}
+	got := splitSqlStatements(stmts)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMigrator_AddFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_add_index.up.sql":  {Data: []byte("-- godb:no-transaction\ncreate index i on a (id);")},
+		"migrations/0001_create_a.up.sql":   {Data: []byte("create table a (id int);")},
+		"migrations/0001_create_a.down.sql": {Data: []byte("drop table a;")},
+		"migrations/README.md":              {Data: []byte("not a migration")},
+	}
+	m := (&DbUtils{Dialect: PostgresDialect{}}).Migrator()
+	if err := m.AddFS(fsys, "migrations"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(m.migrations))
+	}
+	first, second := m.migrations[0], m.migrations[1]
+	if first.Version != 1 || first.Name != "create_a" || first.DownSql != "drop table a;" || first.NoTransaction {
+		t.Errorf("unexpected migration %+v", first)
+	}
+	if second.Version != 2 || !second.NoTransaction || second.DownSql != "" {
+		t.Errorf("unexpected migration %+v", second)
+	}
+
+	if err := m.AddFS(fsys, "migrations"); err == nil {
+		t.Error("expected an error for migrations registered twice")
+	}
+	bad := fstest.MapFS{"m/1_a.up.sql": {Data: []byte("x")}, "m/create.sql": {Data: []byte("x")}}
+	if err := (&DbUtils{}).Migrator().AddFS(bad, "m"); err == nil {
+		t.Error("expected an error for a badly named file")
+	}
+}
+
+func TestMigration_Checksum(t *testing.T) {
+	up := &Migration{UpSql: "create table a (id int);"}
+	sum := sha256.Sum256([]byte(up.UpSql))
+	if up.checksum() != hex.EncodeToString(sum[:]) {
+		t.Error("expected the checksum of a migration without down step to hash the up step")
+	}
+
+	down := &Migration{UpSql: up.UpSql, DownSql: "drop table a;"}
+	edited := &Migration{UpSql: up.UpSql, DownSql: "drop table if exists a;"}
+	if down.checksum() == up.checksum() || down.checksum() == edited.checksum() {
+		t.Error("expected the checksum to change with the down step")
+	}
+}
+
+func Test_Migrator(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	dbmap.Exec("drop table if exists godb_migrations, godb_migrations_lock, migrate_test")
+
+	m := dbmap.Migrator()
+	m.AddSql(1, "create_table", "create table migrate_test (id int primary key, memo varchar(20));", "drop table migrate_test;")
+	m.AddFunc(2, "insert_row", func(s SqlQueryRunner) error {
+		_, err := s.Exec("insert into migrate_test values (1, 'first')")
+		return err
+	}, func(s SqlQueryRunner) error {
+		_, err := s.Exec("delete from migrate_test")
+		return err
+	})
+
+	n, err := m.Up()
+	if err != nil || n != 2 {
+		t.Fatalf("Up applied %d: %v", n, err)
+	}
+	if n, err = m.Up(); err != nil || n != 0 {
+		t.Errorf("second Up applied %d: %v", n, err)
+	}
+	if count := selectInt(dbmap, "select count(*) from migrate_test"); count != 1 {
+		t.Errorf("expected 1 row, got %d", count)
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 2 || !status[0].Applied || !status[1].Applied || status[0].AppliedAt.IsZero() {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	if n, err = m.Down(); err != nil || n != 1 {
+		t.Errorf("Down rolled back %d: %v", n, err)
+	}
+	if count := selectInt(dbmap, "select count(*) from migrate_test"); count != 0 {
+		t.Errorf("expected 0 rows, got %d", count)
+	}
+
+	edited := dbmap.Migrator()
+	edited.AddSql(1, "create_table", "create table migrate_test (id bigint primary key);", "")
+	var checksumErr *MigrationChecksumError
+	if _, err = edited.Up(); !errors.As(err, &checksumErr) || checksumErr.Version != 1 {
+		t.Errorf("expected a checksum error, got %v", err)
+	}
+
+	if n, err = m.DownTo(0); err != nil || n != 1 {
+		t.Errorf("DownTo rolled back %d: %v", n, err)
+	}
+	if count := selectInt(dbmap, "select count(*) from godb_migrations"); count != 0 {
+		t.Errorf("expected no applied migration, got %d", count)
+	}
+}
+
+func Test_MigratorLock(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	dbmap.Exec("drop table if exists godb_migrations, godb_migrations_lock")
+
+	holder := dbmap.Migrator()
+	h := holder.history()
+	if err := h.CreateTablesIfNotExists(); err != nil {
+		panic(err)
+	}
+	if err := holder.lock(h); err != nil {
+		t.Fatal(err)
+	}
+
+	m := dbmap.Migrator()
+	m.LockTimeout = 100 * time.Millisecond
+	m.AddSql(1, "noop", "select 1;", "")
+	var lockedErr *MigrationLockedError
+	if _, err := m.Up(); !errors.As(err, &lockedErr) {
+		t.Errorf("expected a lock error, got %v", err)
+	}
+
+	if err := holder.ForceUnlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Errorf("Up after unlock: %v", err)
+	}
+}
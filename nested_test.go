@@ -0,0 +1,146 @@
+package godb
+
+import (
+	"reflect"
+	"testing"
+)
+
+type NestedAddress struct {
+	Street string `db:"street"`
+	City   string `db:"city"`
+}
+
+type NestedUser struct {
+	Id            int64  `db:"id, primarykey, autoincrement"`
+	Name          string `db:"name, size:50"`
+	NestedAddress `db:"prefix=addr_"`
+}
+
+type NestedHome struct {
+	Street string `db:"street"`
+}
+
+type NestedWork struct {
+	Street string `db:"street"`
+}
+
+type NestedContact struct {
+	Id         int64 `db:"id, primarykey, autoincrement"`
+	NestedHome `db:"prefix=home_"`
+	NestedWork `db:"prefix=work_"`
+}
+
+type NestedOrder struct {
+	Id     int64   `db:"id, primarykey, autoincrement"`
+	UserId int64   `db:"user_id"`
+	Total  float64 `db:"total"`
+}
+
+type NestedReport struct {
+	User  NestedUser   `db:"user"`
+	Order *NestedOrder `db:"order"`
+	Count int64        `db:"count"`
+}
+
+func Test_ColumnToFieldIndex(t *testing.T) {
+	dbmap := &DbUtils{Dialect: MySQLDialect{}}
+	table := dbmap.AddTableWithName(NestedUser{}, "nested_user_test")
+	if col := colMapOrNil(table, "Street"); col == nil || col.ColumnName != "addr_street" {
+		t.Errorf("expected the embedded column to be prefixed, got %v", col)
+	}
+
+	cols := []string{"count", "user.id", "user.addr_city", "Order.Total", "order.missing"}
+	index, err := columnToFieldIndex(dbmap, reflect.TypeOf(NestedReport{}), "", cols)
+	if !NonFatalError(err) {
+		t.Errorf("expected a NonFatalError for the missing column, got %v", err)
+	}
+	want := [][]int{{2}, {0, 0}, {0, 2, 1}, {1, 2}, nil}
+	if !reflect.DeepEqual(index, want) {
+		t.Errorf("got %v, want %v", index, want)
+	}
+}
+
+func Test_PrefixedEmbeds(t *testing.T) {
+	dbmap := initSqliteDB(t, "prefixed")
+	table := dbmap.AddTableWithName(NestedContact{}, "nested_contact_test")
+	var names []string
+	for _, col := range table.Columns {
+		names = append(names, col.ColumnName)
+	}
+	if want := []string{"id", "home_street", "work_street"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected the columns %v, got %v", want, names)
+	}
+	if col := colMapOrNil(table, "Street"); col != nil {
+		t.Errorf("expected an ambiguous field name to match no column, got %v", col.ColumnName)
+	}
+	if col := colMapOrNil(table, "NestedWork.Street"); col == nil || col.ColumnName != "work_street" {
+		t.Errorf("expected the field path to match work_street, got %v", col)
+	}
+
+	index, err := columnToFieldIndex(dbmap, reflect.TypeOf(NestedContact{}), "", []string{"home_street", "work_street"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]int{{1, 0}, {2, 0}}; !reflect.DeepEqual(index, want) {
+		t.Errorf("got %v, want %v", index, want)
+	}
+
+	if err := dbmap.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	c := &NestedContact{NestedHome: NestedHome{"1 Home St"}, NestedWork: NestedWork{"2 Work St"}}
+	if err := dbmap.Insert(c); err != nil {
+		t.Fatal(err)
+	}
+	var got NestedContact
+	if err := dbmap.SelectOne(&got, "select * from nested_contact_test where id = ?", c.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got != *c {
+		t.Errorf("expected %+v, got %+v", *c, got)
+	}
+	c.NestedWork.Street = "3 Work St"
+	if _, err := dbmap.Update(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbmap.SelectOne(&got, "select * from nested_contact_test where id = ?", c.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got != *c {
+		t.Errorf("expected the update of work_street, got %+v", got)
+	}
+}
+
+func Test_SelectNested(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	dbmap.AddTableWithName(NestedUser{}, "nested_user_test")
+	dbmap.AddTableWithName(NestedOrder{}, "nested_order_test")
+	dbmap.DropTablesIfExists()
+	if err := dbmap.CreateTables(); err != nil {
+		panic(err)
+	}
+	defer dbmap.DropTablesIfExists()
+
+	cly := &NestedUser{Name: "cly", NestedAddress: NestedAddress{Street: "main", City: "gz"}}
+	bob := &NestedUser{Name: "bob"}
+	_insert(dbmap, cly, bob)
+	_insert(dbmap, &NestedOrder{UserId: cly.Id, Total: 12.5})
+
+	var reports []NestedReport
+	_, err := dbmap.Select(&reports, `select u.id as "user.id", u.name as "user.name", u.addr_city as "user.addr_city", `+
+		`o.id as "order.id", o.total as "order.total", 1 as count `+
+		`from nested_user_test u left join nested_order_test o on o.user_id = u.id order by u.id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(reports))
+	}
+	if r := reports[0]; r.User.Id != cly.Id || r.User.City != "gz" || r.Order == nil || r.Order.Total != 12.5 || r.Count != 1 {
+		t.Errorf("unexpected report %+v %+v", r, r.Order)
+	}
+	if r := reports[1]; r.User.Name != "bob" || r.Order != nil {
+		t.Errorf("expected no order for bob, got %+v", r.Order)
+	}
+}
@@ -0,0 +1,108 @@
+package godb
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func planTable() (*TableMap, reflect.Value) {
+	dbUtils := &DbUtils{Dialect: PostgresDialect{}}
+	table := dbUtils.AddTableWithName(Invoice{}, "invoice_test").SetKeys(true, "Id")
+	inv := &Invoice{Id: 1, Memo: "memo", PersonId: 2}
+	return table, reflect.ValueOf(inv).Elem()
+}
+
+func TestTableMap_cachedPlan(t *testing.T) {
+	table, elem := planTable()
+
+	if table.bindGet(false) != table.bindGet(false) {
+		t.Errorf("get plan was not cached")
+	}
+
+	bi, err := table.bindUpdate(elem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `update "invoice_test" set "Created"=$1, "Updated"=$2, "Memo"=$3, "PersonId"=$4, "IsPaid"=$5 where "Id"=$6;`
+	if bi.query != want {
+		t.Errorf("%s != %s", bi.query, want)
+	}
+
+	table.ColMap("Memo").Rename("memo")
+	bi, _ = table.bindUpdate(elem)
+	want = `update "invoice_test" set "Created"=$1, "Updated"=$2, "memo"=$3, "PersonId"=$4, "IsPaid"=$5 where "Id"=$6;`
+	if bi.query != want {
+		t.Errorf("plan not reset after Rename: %s", bi.query)
+	}
+
+	table.TableName = "invoice_renamed"
+	bi, _ = table.bindDelete(elem)
+	want = `delete from "invoice_renamed" where "Id"=$1;`
+	if bi.query != want {
+		t.Errorf("plan not reset after table rename: %s", bi.query)
+	}
+
+	table.SetKeys(false, "Id", "PersonId")
+	bi, _ = table.bindDelete(elem)
+	want = `delete from "invoice_renamed" where "Id"=$1 and "PersonId"=$2;`
+	if bi.query != want {
+		t.Errorf("plan not reset after SetKeys: %s", bi.query)
+	}
+}
+
+func TestTableMap_cachedPlanConcurrent(t *testing.T) {
+	table, _ := planTable()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				inv := &Invoice{Id: int64(j)}
+				if _, err := table.insert(reflect.ValueOf(inv).Elem()); err != nil {
+					t.Error(err)
+				}
+				if i == 0 && j%10 == 0 {
+					table.ResetSql()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkTableMap_insertCached(b *testing.B) {
+	table, elem := planTable()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		table.insert(elem)
+	}
+}
+
+func BenchmarkTableMap_insertUncached(b *testing.B) {
+	table, elem := planTable()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		table.ResetSql()
+		table.insert(elem)
+	}
+}
+
+func BenchmarkTableMap_bindUpdateCached(b *testing.B) {
+	table, elem := planTable()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		table.bindUpdate(elem)
+	}
+}
+
+func BenchmarkTableMap_bindUpdateUncached(b *testing.B) {
+	table, elem := planTable()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		table.ResetSql()
+		table.bindUpdate(elem)
+	}
+}
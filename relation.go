@@ -0,0 +1,449 @@
+package godb
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type relationKind int
+
+const (
+	hasOneRelation relationKind = iota
+	hasManyRelation
+	belongsToRelation
+	manyToManyRelation
+)
+
+// relation links a field of the struct of a table to rows of the table of
+// target, loaded by Preload.
+type relation struct {
+	kind       relationKind
+	field      string
+	target     reflect.Type
+	foreignKey string
+
+	// joinTable, with the foreignKey and targetKey columns, links the
+	// rows of a many to many relation.
+	joinTable string
+	targetKey string
+}
+
+// PreloadOption lists the relations loaded by Select or Get when passed
+// among their arguments. It is created with Preload.
+type PreloadOption []string
+
+// Preload returns an option loading the given relations of the rows
+// returned by Select or Get, with one "in (...)" query per relation.
+// Relations of the loaded rows are given as paths such as "Orders.Items".
+//
+//	dbUtils.Select(&users, "select * from users", godb.Preload("Orders"))
+func Preload(relations ...string) PreloadOption {
+	return PreloadOption(relations)
+}
+
+// HasOne declares that field, a struct or a pointer to a struct of the
+// type of target, holds the row of its table whose foreignKey field
+// references the primary key of t.
+func (t *TableMap) HasOne(field string, target interface{}, foreignKey string) *TableMap {
+	return t.addRelation(&relation{kind: hasOneRelation, field: field, foreignKey: foreignKey}, target)
+}
+
+// HasMany declares that field, a slice of the type of target or of
+// pointers to it, holds the rows of its table whose foreignKey field
+// references the primary key of t.
+//
+//	t.HasMany("Orders", &Order{}, "UserId")
+func (t *TableMap) HasMany(field string, target interface{}, foreignKey string) *TableMap {
+	return t.addRelation(&relation{kind: hasManyRelation, field: field, foreignKey: foreignKey}, target)
+}
+
+// BelongsTo declares that field, a struct or a pointer to a struct of the
+// type of target, holds the row of its table referenced by the foreignKey
+// field of t.
+func (t *TableMap) BelongsTo(field string, target interface{}, foreignKey string) *TableMap {
+	return t.addRelation(&relation{kind: belongsToRelation, field: field, foreignKey: foreignKey}, target)
+}
+
+// ManyToMany declares that field, a slice of the type of target or of
+// pointers to it, holds the rows of its table linked to t by joinTable,
+// whose foreignKey and targetKey columns reference the primary keys of t
+// and of the table of target. joinTable is in the schema of t.
+func (t *TableMap) ManyToMany(field string, target interface{}, joinTable, foreignKey, targetKey string) *TableMap {
+	return t.addRelation(&relation{kind: manyToManyRelation, field: field, foreignKey: foreignKey,
+		joinTable: joinTable, targetKey: targetKey}, target)
+}
+
+// addRelation checks and adds rel, replacing the relation of the same
+// field. The field becomes transient.
+func (t *TableMap) addRelation(rel *relation, target interface{}) *TableMap {
+	targetType, err := toType(target)
+	if err != nil {
+		panic(fmt.Sprintf("godb: relation %s: %v", rel.field, err))
+	}
+	rel.target = targetType
+
+	f, ok := t.gotype.FieldByName(rel.field)
+	if !ok {
+		panic(fmt.Sprintf("godb: relation %s: no field %s in type %s", rel.field, rel.field, t.gotype.Name()))
+	}
+	ft := f.Type
+	if rel.kind == hasManyRelation || rel.kind == manyToManyRelation {
+		if ft.Kind() != reflect.Slice {
+			panic(fmt.Sprintf("godb: relation %s: field must be a slice, not %v", rel.field, ft))
+		}
+		ft = ft.Elem()
+	}
+	if indirectType(ft) != targetType {
+		panic(fmt.Sprintf("godb: relation %s: field of type %v does not hold %v", rel.field, f.Type, targetType))
+	}
+	if rel.kind == belongsToRelation {
+		t.ColMap(rel.foreignKey)
+	}
+
+	if col := colMapOrNil(t, rel.field); col != nil {
+		col.SetTransient(true)
+	}
+	for i, r := range t.relations {
+		if r.field == rel.field {
+			t.relations[i] = rel
+			return t
+		}
+	}
+	t.relations = append(t.relations, rel)
+	return t
+}
+
+func (t *TableMap) relation(field string) *relation {
+	for _, r := range t.relations {
+		if r.field == field {
+			return r
+		}
+	}
+	return nil
+}
+
+// extractPreloads removes the PreloadOptions from args, returning the
+// relation paths they list.
+func extractPreloads(args []interface{}) ([]interface{}, []string) {
+	var paths []string
+	for _, arg := range args {
+		if p, ok := arg.(PreloadOption); ok {
+			paths = append(paths, p...)
+		}
+	}
+	if paths == nil {
+		return args, nil
+	}
+
+	rest := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		if _, ok := arg.(PreloadOption); !ok {
+			rest = append(rest, arg)
+		}
+	}
+	return rest, paths
+}
+
+// preload loads the relations named by paths into parents, addressable
+// structs of type t, then the relations of the loaded rows.
+func preload(dbUtils *DbUtils, queryRunner SqlQueryRunner, t reflect.Type, parents []reflect.Value, paths []string) error {
+	if len(parents) == 0 || len(paths) == 0 {
+		return nil
+	}
+	table, err := dbUtils.TableFor(t, false)
+	if err != nil {
+		return err
+	}
+
+	var (
+		names []string
+		subs  = make(map[string][]string)
+	)
+	for _, path := range paths {
+		name, sub := path, ""
+		if i := strings.Index(path, "."); i >= 0 {
+			name, sub = path[:i], path[i+1:]
+		}
+		if _, ok := subs[name]; !ok {
+			names = append(names, name)
+			subs[name] = nil
+		}
+		if sub != "" {
+			subs[name] = append(subs[name], sub)
+		}
+	}
+
+	for _, name := range names {
+		rel := table.relation(name)
+		if rel == nil {
+			return fmt.Errorf("godb: no relation %s declared on table %s", name, table.TableName)
+		}
+		children, matches, err := rel.load(dbUtils, queryRunner, table, parents)
+		if err != nil {
+			return err
+		}
+		// the relations of the children are loaded first, as fields
+		// holding structs rather than pointers get copies of them
+		if err := preload(dbUtils, queryRunner, rel.target, children, subs[name]); err != nil {
+			return err
+		}
+		for i, parent := range parents {
+			assignRelation(parent.FieldByName(rel.field), matches[i])
+		}
+	}
+	return nil
+}
+
+// load queries the rows related to parents, returning them along with the
+// rows related to each parent.
+func (rel *relation) load(dbUtils *DbUtils, queryRunner SqlQueryRunner, table *TableMap, parents []reflect.Value) ([]reflect.Value, [][]reflect.Value, error) {
+	target, err := dbUtils.TableFor(rel.target, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		parentField string // field of the parents holding the key
+		childField  string // field of the children matched with the key
+		children    []reflect.Value
+		owners      []interface{} // keys of the parents of each child, for many to many relations
+	)
+	switch rel.kind {
+	case hasOneRelation, hasManyRelation:
+		pk, err := singleKey(table)
+		if err != nil {
+			return nil, nil, err
+		}
+		fk := colMapOrNil(target, rel.foreignKey)
+		if fk == nil {
+			return nil, nil, fmt.Errorf("godb: relation %s: no field %s in table %s", rel.field, rel.foreignKey, target.TableName)
+		}
+		parentField, childField = pk.fieldName, fk.fieldName
+		children, err = selectIn(dbUtils, queryRunner, target, fk.ColumnName, relationKeys(parents, parentField))
+		if err != nil {
+			return nil, nil, err
+		}
+	case belongsToRelation:
+		pk, err := singleKey(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		parentField, childField = table.ColMap(rel.foreignKey).fieldName, pk.fieldName
+		children, err = selectIn(dbUtils, queryRunner, target, pk.ColumnName, relationKeys(parents, parentField))
+		if err != nil {
+			return nil, nil, err
+		}
+	case manyToManyRelation:
+		pk, err := singleKey(table)
+		if err != nil {
+			return nil, nil, err
+		}
+		parentField = pk.fieldName
+		children, owners, err = selectJoined(dbUtils, queryRunner, table, target, rel, relationKeys(parents, parentField))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	byKey := make(map[interface{}][]reflect.Value)
+	for i, child := range children {
+		var key interface{}
+		if owners != nil {
+			key = owners[i]
+		} else {
+			key, _ = relationKey(fieldByName(child, childField))
+		}
+		byKey[key] = append(byKey[key], child)
+	}
+
+	matches := make([][]reflect.Value, len(parents))
+	for i, parent := range parents {
+		if key, ok := relationKey(fieldByName(parent, parentField)); ok {
+			matches[i] = byKey[key]
+		}
+	}
+	return children, matches, nil
+}
+
+// assignRelation sets f, a slice, a struct or a pointer to a struct, to
+// the related rows.
+func assignRelation(f reflect.Value, rows []reflect.Value) {
+	switch {
+	case f.Kind() == reflect.Slice:
+		s := reflect.MakeSlice(f.Type(), 0, len(rows))
+		for _, row := range rows {
+			if f.Type().Elem().Kind() == reflect.Ptr {
+				row = row.Addr()
+			}
+			s = reflect.Append(s, row)
+		}
+		f.Set(s)
+	case len(rows) == 0:
+		f.Set(reflect.Zero(f.Type()))
+	case f.Kind() == reflect.Ptr:
+		f.Set(rows[0].Addr())
+	default:
+		f.Set(rows[0])
+	}
+}
+
+func singleKey(table *TableMap) (*ColumnMap, error) {
+	if len(table.keys) != 1 {
+		return nil, fmt.Errorf("godb: relations need a single column primary key on table %s", table.TableName)
+	}
+	return table.keys[0], nil
+}
+
+// relationKey converts the key held by v to a value comparable with the
+// keys of other types, such as an int key referenced by an int64 field.
+// It returns false for NULL keys.
+func relationKey(v reflect.Value) (interface{}, bool) {
+	key, err := driver.DefaultParameterConverter.ConvertValue(v.Interface())
+	if err != nil || key == nil {
+		return nil, false
+	}
+	if b, ok := key.([]byte); ok {
+		return string(b), true
+	}
+	return key, true
+}
+
+// relationKeys returns the distinct keys held by the field of parents.
+func relationKeys(parents []reflect.Value, field string) []interface{} {
+	var (
+		keys []interface{}
+		seen = make(map[interface{}]bool)
+	)
+	for _, parent := range parents {
+		key, ok := relationKey(fieldByName(parent, field))
+		if ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// preloadChunkSize is the maximum number of keys of an "in (...)" list,
+// Oracle allowing 1000 expressions.
+func preloadChunkSize(d Dialect) int {
+	n := 1000
+	if b, ok := d.(BatchInserter); ok && b.MaxBindVars() < n {
+		n = b.MaxBindVars()
+	}
+	return n
+}
+
+// selectIn returns the rows of table whose column is one of keys, as
+// addressable structs.
+func selectIn(dbUtils *DbUtils, queryRunner SqlQueryRunner, table *TableMap, column string, keys []interface{}) ([]reflect.Value, error) {
+	var (
+		rows    []reflect.Value
+		dialect = dbUtils.Dialect
+		size    = preloadChunkSize(dialect)
+	)
+	for len(keys) > 0 {
+		chunk := keys
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+		keys = keys[len(chunk):]
+
+		query := fmt.Sprintf("select %s from %s where %s in (%s)%s%s", relationColumns(dialect, table, ""),
+			dialect.QuotedTableForQuery(table.SchemaName, table.TableName), dialect.QuoteField(column),
+			bindVars(dialect, len(chunk)), notDeletedAnd(dbUtils, table, ""), dialect.QuerySuffix())
+		list := reflect.New(reflect.SliceOf(reflect.PtrTo(table.gotype)))
+		if _, err := rawselect(dbUtils, queryRunner, list.Interface(), query, chunk...); err != nil {
+			return nil, err
+		}
+		for i := 0; i < list.Elem().Len(); i++ {
+			rows = append(rows, list.Elem().Index(i).Elem())
+		}
+	}
+	return rows, nil
+}
+
+// selectJoined returns the rows of target linked by the join table of rel
+// to the rows of table with the given keys, along with the key of the row
+// of table each one is linked to.
+func selectJoined(dbUtils *DbUtils, queryRunner SqlQueryRunner, table, target *TableMap, rel *relation, keys []interface{}) ([]reflect.Value, []interface{}, error) {
+	tpk, err := singleKey(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		rows    []reflect.Value
+		owners  []interface{}
+		dialect = dbUtils.Dialect
+		size    = preloadChunkSize(dialect)
+		key     = table.gotype.FieldByIndex(table.keys[0].fieldIndex)
+		owner   = reflect.StructOf([]reflect.StructField{{Name: "Key", Type: key.Type}})
+	)
+	for len(keys) > 0 {
+		chunk := keys
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+		keys = keys[len(chunk):]
+
+		query := fmt.Sprintf("select %s, j.%s from %s t join %s j on j.%s = t.%s where j.%s in (%s)%s%s",
+			relationColumns(dialect, target, "t."), dialect.QuoteField(rel.foreignKey),
+			dialect.QuotedTableForQuery(target.SchemaName, target.TableName),
+			dialect.QuotedTableForQuery(table.SchemaName, rel.joinTable),
+			dialect.QuoteField(rel.targetKey), dialect.QuoteField(tpk.ColumnName),
+			dialect.QuoteField(rel.foreignKey), bindVars(dialect, len(chunk)),
+			notDeletedAnd(dbUtils, target, "t."), dialect.QuerySuffix())
+		rsMap, err := queryMap(dbUtils, queryRunner, query, chunk...)
+		if err != nil {
+			return nil, nil, err
+		}
+		for rsMap.Next() {
+			row, key := reflect.New(target.gotype), reflect.New(owner)
+			if err := rsMap.ScanStructByIndex(row.Interface(), key.Interface()); err != nil {
+				rsMap.Close()
+				return nil, nil, err
+			}
+			k, _ := relationKey(key.Elem().Field(0))
+			rows = append(rows, row.Elem())
+			owners = append(owners, k)
+		}
+		if err := rsMap.Close(); err != nil {
+			return nil, nil, err
+		}
+		if err := rsMap.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return rows, owners, nil
+}
+
+// relationColumns returns the quoted mapped columns of table, in the order
+// scanned by ScanStructByIndex.
+func relationColumns(d Dialect, table *TableMap, alias string) string {
+	var cols []string
+	for _, col := range table.Columns {
+		if !col.Transient {
+			cols = append(cols, alias+d.QuoteField(col.ColumnName))
+		}
+	}
+	return strings.Join(cols, ", ")
+}
+
+// notDeletedAnd returns the condition skipping the soft deleted rows of
+// table, prefixed with " and ", unless dbUtils is unscoped.
+func notDeletedAnd(dbUtils *DbUtils, table *TableMap, alias string) string {
+	if cond := table.notDeletedCond(alias); cond != "" && !dbUtils.unscoped {
+		return " and " + cond
+	}
+	return ""
+}
+
+func bindVars(d Dialect, n int) string {
+	vars := make([]string, n)
+	for i := range vars {
+		vars[i] = d.BindVar(i)
+	}
+	return strings.Join(vars, ", ")
+}
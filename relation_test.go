@@ -0,0 +1,135 @@
+package godb
+
+import (
+	"testing"
+)
+
+type PreloadUser struct {
+	Id      int64  `db:"id, primarykey, autoincrement"`
+	Name    string `db:"name, size:50"`
+	Profile *PreloadProfile
+	Orders  []*PreloadOrder
+	History []PreloadOrder
+	Groups  []PreloadGroup
+}
+
+type PreloadProfile struct {
+	Id     int64  `db:"id, primarykey, autoincrement"`
+	UserId int64  `db:"user_id"`
+	Bio    string `db:"bio, size:100"`
+}
+
+type PreloadOrder struct {
+	Id     int64        `db:"id, primarykey, autoincrement"`
+	UserId int          `db:"user_id"`
+	Total  float64      `db:"total"`
+	User   *PreloadUser `db:"-"`
+	Items  []PreloadItem
+}
+
+type PreloadItem struct {
+	Id      int64  `db:"id, primarykey, autoincrement"`
+	OrderId int64  `db:"order_id"`
+	Sku     string `db:"sku, size:20"`
+}
+
+type PreloadGroup struct {
+	Id   int64  `db:"id, primarykey, autoincrement"`
+	Name string `db:"name, size:50"`
+}
+
+type PreloadMembership struct {
+	UserId  int64 `db:"user_id"`
+	GroupId int64 `db:"group_id"`
+}
+
+func Test_RelationDeclarations(t *testing.T) {
+	dbmap := &DbUtils{Dialect: MySQLDialect{}}
+	table := dbmap.AddTableWithName(PreloadUser{}, "preload_user_test")
+	table.HasMany("Orders", &PreloadOrder{}, "UserId")
+	if !table.ColMap("Orders").Transient {
+		t.Error("expected the relation field to be transient")
+	}
+
+	for _, declare := range []func(){
+		func() { table.HasMany("Missing", &PreloadOrder{}, "UserId") },
+		func() { table.HasMany("Profile", &PreloadProfile{}, "UserId") },
+		func() { table.HasOne("Profile", &PreloadOrder{}, "UserId") },
+		func() { table.BelongsTo("Profile", &PreloadProfile{}, "ProfileId") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected an invalid relation to panic")
+				}
+			}()
+			declare()
+		}()
+	}
+}
+
+func Test_Preload(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	users := dbmap.AddTableWithName(PreloadUser{}, "preload_user_test")
+	orders := dbmap.AddTableWithName(PreloadOrder{}, "preload_order_test")
+	dbmap.AddTableWithName(PreloadProfile{}, "preload_profile_test")
+	dbmap.AddTableWithName(PreloadItem{}, "preload_item_test")
+	dbmap.AddTableWithName(PreloadGroup{}, "preload_group_test")
+	dbmap.AddTableWithName(PreloadMembership{}, "preload_membership_test").SetKeys(false, "UserId", "GroupId")
+	users.HasOne("Profile", &PreloadProfile{}, "UserId").
+		HasMany("Orders", &PreloadOrder{}, "UserId").
+		HasMany("History", &PreloadOrder{}, "UserId").
+		ManyToMany("Groups", &PreloadGroup{}, "preload_membership_test", "user_id", "group_id")
+	orders.BelongsTo("User", &PreloadUser{}, "UserId").
+		HasMany("Items", &PreloadItem{}, "OrderId")
+	dbmap.DropTablesIfExists()
+	if err := dbmap.CreateTables(); err != nil {
+		panic(err)
+	}
+	defer dbmap.DropTablesIfExists()
+
+	cly, bob := &PreloadUser{Name: "cly"}, &PreloadUser{Name: "bob"}
+	_insert(dbmap, cly, bob)
+	o1, o2 := &PreloadOrder{UserId: int(cly.Id), Total: 1}, &PreloadOrder{UserId: int(cly.Id), Total: 2}
+	_insert(dbmap, o1, o2, &PreloadProfile{UserId: bob.Id, Bio: "hi"})
+	_insert(dbmap, &PreloadItem{OrderId: o1.Id, Sku: "a"}, &PreloadItem{OrderId: o1.Id, Sku: "b"})
+	admins, devs := &PreloadGroup{Name: "admins"}, &PreloadGroup{Name: "devs"}
+	_insert(dbmap, admins, devs)
+	_insert(dbmap, &PreloadMembership{cly.Id, admins.Id}, &PreloadMembership{cly.Id, devs.Id}, &PreloadMembership{bob.Id, devs.Id})
+
+	var list []*PreloadUser
+	_, err := dbmap.Select(&list, "select id, name from preload_user_test order by id", Preload("Profile", "Orders.Items", "Groups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 users, got %d", len(list))
+	}
+	if u := list[0]; u.Profile != nil || len(u.Orders) != 2 || len(u.Orders[0].Items) != 2 || len(u.Orders[1].Items) != 0 || len(u.Groups) != 2 {
+		t.Errorf("unexpected relations of cly: %+v", u)
+	}
+	if u := list[1]; u.Profile == nil || u.Profile.Bio != "hi" || u.Orders == nil || len(u.Orders) != 0 || len(u.Groups) != 1 || u.Groups[0].Name != "devs" {
+		t.Errorf("unexpected relations of bob: %+v", u)
+	}
+
+	var values []PreloadUser
+	if _, err := dbmap.Select(&values, "select id, name from preload_user_test order by id", Preload("History.Items", "History.User")); err != nil {
+		t.Fatal(err)
+	}
+	if h := values[0].History; len(h) != 2 || len(h[0].Items) != 2 || h[0].Items[1].Sku != "b" || h[0].User == nil || h[0].User.Name != "cly" || len(h[1].Items) != 0 {
+		t.Errorf("expected the nested relations in the value slice, got %+v", h)
+	}
+
+	obj, err := dbmap.Get(PreloadOrder{}, o2.Id, Preload("User.Groups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := obj.(*PreloadOrder); o.User == nil || o.User.Name != "cly" || len(o.User.Groups) != 2 || o.Items != nil {
+		t.Errorf("unexpected order %+v", o)
+	}
+
+	if _, err := dbmap.Select(&list, "select id, name from preload_user_test", Preload("Missing")); err == nil {
+		t.Error("expected an error preloading an undeclared relation")
+	}
+}
@@ -0,0 +1,203 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// DefaultReplicaRetryInterval is how long a replica failing its health
+	// check is left out before being tried again.
+	DefaultReplicaRetryInterval = 30 * time.Second
+
+	// replicaPingTimeout bounds the health check of a replica whose query
+	// failed.
+	replicaPingTimeout = 5 * time.Second
+)
+
+// ReplicaPolicy chooses the replica running a read among the healthy ones.
+type ReplicaPolicy interface {
+	// Choose returns one of replicas, which is never empty. Returning nil
+	// runs the read on the primary.
+	Choose(replicas []*sql.DB) *sql.DB
+}
+
+// ReplicaPolicyFunc adapts a function to a ReplicaPolicy.
+type ReplicaPolicyFunc func(replicas []*sql.DB) *sql.DB
+
+func (f ReplicaPolicyFunc) Choose(replicas []*sql.DB) *sql.DB {
+	return f(replicas)
+}
+
+// RoundRobinPolicy chooses the replicas in turn. It is the default policy.
+type RoundRobinPolicy struct {
+	n uint64
+}
+
+func (p *RoundRobinPolicy) Choose(replicas []*sql.DB) *sql.DB {
+	n := atomic.AddUint64(&p.n, 1) - 1
+	return replicas[n%uint64(len(replicas))]
+}
+
+// RandomPolicy chooses a replica at random.
+type RandomPolicy struct{}
+
+func (p RandomPolicy) Choose(replicas []*sql.DB) *sql.DB {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+// LeastInUsePolicy chooses the replica with the fewest connections in use.
+type LeastInUsePolicy struct{}
+
+func (p LeastInUsePolicy) Choose(replicas []*sql.DB) *sql.DB {
+	best := replicas[0]
+	for _, db := range replicas[1:] {
+		if db.Stats().InUse < best.Stats().InUse {
+			best = db
+		}
+	}
+	return best
+}
+
+type replica struct {
+	db           *sql.DB
+	ejectedUntil time.Time
+}
+
+// replicaSet holds the replicas of a DbUtils, shared by its copies.
+type replicaSet struct {
+	mu       sync.Mutex
+	replicas []*replica
+	policy   ReplicaPolicy
+}
+
+type forcePrimaryKey struct{}
+
+// ForcePrimary returns a context running the reads of a DbUtils given it
+// with WithContext on the primary, to read back rows just written.
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryKey{}, true)
+}
+
+// SetReplicas registers read replicas of Db. Select, Get, Query and the
+// other reads of the DbUtils then run on a replica chosen by policy, or
+// the RoundRobinPolicy if nil, while writes, transactions and reads given
+// a ForcePrimary context run on Db. Calling SetReplicas without replicas
+// removes them.
+//
+// A replica whose query fails, other than with an error reported by the
+// server, and which does not answer a ping is left out for
+// DefaultReplicaRetryInterval, and the query is run again on another
+// replica, or on Db when none is left.
+func (dbUtils *DbUtils) SetReplicas(policy ReplicaPolicy, replicas ...*sql.DB) {
+	if len(replicas) == 0 {
+		dbUtils.replicas = nil
+		return
+	}
+	if policy == nil {
+		policy = &RoundRobinPolicy{}
+	}
+	set := &replicaSet{policy: policy}
+	for _, db := range replicas {
+		set.replicas = append(set.replicas, &replica{db: db})
+	}
+	dbUtils.replicas = set
+}
+
+// choose returns a healthy replica chosen by the policy, or nil.
+func (s *replicaSet) choose() *replica {
+	now := time.Now()
+	s.mu.Lock()
+	var (
+		healthy []*replica
+		dbs     []*sql.DB
+	)
+	for _, r := range s.replicas {
+		if now.After(r.ejectedUntil) {
+			healthy = append(healthy, r)
+			dbs = append(dbs, r.db)
+		}
+	}
+	s.mu.Unlock()
+	if len(dbs) == 0 {
+		return nil
+	}
+
+	db := s.policy.Choose(dbs)
+	for _, r := range healthy {
+		if r.db == db {
+			return r
+		}
+	}
+	return nil
+}
+
+// ejectIfDown pings r after its query, run with ctx, failed with err, and
+// leaves it out if the ping fails too. Errors carrying a vendor error
+// number or SQLSTATE were reported by the server, and are not followed by
+// a ping. It returns true if r was ejected.
+func (s *replicaSet) ejectIfDown(ctx context.Context, r *replica, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if _, ok := errorNumber(err); ok {
+		return false
+	}
+	if _, ok := errorSQLState(err); ok {
+		return false
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+	defer cancel()
+	if r.db.PingContext(pingCtx) == nil || ctx.Err() != nil {
+		// a ping cut short by the context of the query says nothing of r
+		return false
+	}
+
+	s.mu.Lock()
+	r.ejectedUntil = time.Now().Add(DefaultReplicaRetryInterval)
+	s.mu.Unlock()
+	return true
+}
+
+// routeRead runs f on a replica when queryRunner is a DbUtils with
+// replicas and query only reads, and on primary otherwise.
+func routeRead(queryRunner SqlQueryRunner, ctx context.Context, query string, primary executor, f func(ex executor) error) error {
+	m, ok := queryRunner.(*DbUtils)
+	if !ok || m.replicas == nil || ctx.Value(forcePrimaryKey{}) != nil || !isReadQuery(query) {
+		return f(primary)
+	}
+
+	for {
+		r := m.replicas.choose()
+		if r == nil {
+			return f(primary)
+		}
+		err := f(r.db)
+		if err == nil || !m.replicas.ejectIfDown(ctx, r, err) {
+			return err
+		}
+	}
+}
+
+// isReadQuery reports whether query is a select, which can run on a
+// replica. Inserts returning their keys through a query, such as Postgres
+// "insert ... returning" or DB2 "select ... from final table (insert ...)",
+// are writes.
+func isReadQuery(query string) bool {
+	q := strings.ToLower(strings.TrimLeft(query, " \t\r\n("))
+	if !strings.HasPrefix(q, "select") {
+		return false
+	}
+	for _, write := range []string{"final table", "new table", "old table", " for update", " for share"} {
+		if strings.Contains(q, write) {
+			return false
+		}
+	}
+	return true
+}
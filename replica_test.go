@@ -0,0 +1,142 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+type ReplicaRow struct {
+	Id     int64  `db:"id, primarykey, autoincrement"`
+	Server string `db:"server, size:20"`
+}
+
+func Test_IsReadQuery(t *testing.T) {
+	tests := map[string]bool{
+		"select * from users":                                                    true,
+		" (SELECT id FROM users) union (select id from a)":                       true,
+		"insert into users (name) values ($1) returning id":                      false,
+		`select "ID" from final table (insert into "USERS" ("NAME") values (?))`: false,
+		"select * from users for update":                                         false,
+		"update users set name = ?":                                              false,
+		"with x as (delete from users returning *) select * from x":              false,
+	}
+	for query, want := range tests {
+		if got := isReadQuery(query); got != want {
+			t.Errorf("isReadQuery(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+// openServer opens an SQLite file standing in for a server, holding a
+// row naming it.
+func openServer(t *testing.T, name string) *sql.DB {
+	db := initSqliteDB(t, name).Db
+	for _, q := range []string{
+		"create table replica_row_test (id integer primary key autoincrement, server varchar(20))",
+		"insert into replica_row_test (server) values ('" + name + "')",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func Test_Replicas(t *testing.T) {
+	primary, r1, r2 := openServer(t, "primary"), openServer(t, "r1"), openServer(t, "r2")
+	dbmap := &DbUtils{Db: primary, Dialect: SqliteDialect{}}
+	dbmap.AddTableWithName(ReplicaRow{}, "replica_row_test")
+	dbmap.SetReplicas(nil, r1, r2)
+
+	server := func(runner SqlQueryRunner) string {
+		s, err := SelectStr(runner, "select server from replica_row_test where id = 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	if a, b, c := server(dbmap), server(dbmap), server(dbmap); a != "r1" || b != "r2" || c != "r1" {
+		t.Errorf("expected round robin reads, got %s %s %s", a, b, c)
+	}
+
+	row := &ReplicaRow{Server: "written"}
+	if err := dbmap.Insert(row); err != nil {
+		t.Fatal(err)
+	}
+	if n := selectInt(dbmap.WithContext(ForcePrimary(context.Background())).(*DbUtils), "select count(*) from replica_row_test"); n != 2 {
+		t.Errorf("expected the insert on the primary, got %d rows", n)
+	}
+	if obj, err := dbmap.Get(ReplicaRow{}, row.Id); err != nil || obj != nil {
+		t.Errorf("expected Get to read a replica: %v %v", obj, err)
+	}
+
+	trans, err := dbmap.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := server(trans); s != "primary" {
+		t.Errorf("expected the transaction on the primary, got %s", s)
+	}
+	trans.Rollback()
+
+	r1.Close()
+	for i := 0; i < 3; i++ {
+		if s := server(dbmap); s != "r2" {
+			t.Errorf("expected the failing replica to be ejected, got %s", s)
+		}
+	}
+	r2.Close()
+	if s := server(dbmap); s != "primary" {
+		t.Errorf("expected reads on the primary without replicas, got %s", s)
+	}
+}
+
+func TestReplicaSet_EjectIfDown(t *testing.T) {
+	down := openServer(t, "down")
+	down.Close()
+	set := &replicaSet{policy: &RoundRobinPolicy{}, replicas: []*replica{{db: down}}}
+	r := set.replicas[0]
+	ctx := context.Background()
+
+	if set.ejectIfDown(ctx, r, &fakeNumberError{Number: 1064}) {
+		t.Error("expected an error of the server to keep the replica")
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if set.ejectIfDown(canceled, r, errors.New("bad connection")) {
+		t.Error("expected no ejection once the context of the query is done")
+	}
+	if set.choose() != r {
+		t.Fatal("expected the replica still chosen")
+	}
+	if !set.ejectIfDown(ctx, r, errors.New("bad connection")) || set.choose() != nil {
+		t.Error("expected the replica failing its ping ejected")
+	}
+}
+
+// queryTargetDialect inserts with the InsertQueryToTarget of Oracle, which
+// reads the generated id back with a second query.
+type queryTargetDialect struct {
+	Dialect
+}
+
+func (d queryTargetDialect) InsertQueryToTarget(exec SqlQueryRunner, insertSql, idSql string, target interface{}, params ...interface{}) error {
+	return OracleDialect{}.InsertQueryToTarget(exec, insertSql, idSql, target, params...)
+}
+
+func Test_ReplicasInsertQueryToTarget(t *testing.T) {
+	primary, replica := openServer(t, "primary"), openServer(t, "replica")
+	dbmap := &DbUtils{Db: primary, Dialect: queryTargetDialect{SqliteDialect{}}}
+	dbmap.AddTableWithName(ReplicaRow{}, "replica_row_test").ColMap("Id").GeneratedIdQuery = "select max(id) from replica_row_test"
+	dbmap.SetReplicas(nil, replica)
+
+	row := &ReplicaRow{Server: "written"}
+	if err := dbmap.Insert(row); err != nil {
+		t.Fatal(err)
+	}
+	if row.Id != 2 {
+		t.Errorf("expected the id read back on the primary, got %d", row.Id)
+	}
+}
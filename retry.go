@@ -0,0 +1,126 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+)
+
+var (
+	// DefaultTransactionRetries is how many times InTransaction runs a
+	// transaction again after a deadlock or serialization failure.
+	DefaultTransactionRetries = 3
+
+	// DefaultTransactionRetryBackoff is the delay before the first retry of
+	// InTransaction, doubled for each following retry.
+	DefaultTransactionRetryBackoff = 20 * time.Millisecond
+)
+
+// RetryClassifier is implemented by dialects that recognize the errors of
+// transactions that may succeed when run again, such as deadlocks and
+// serialization failures. InTransaction only retries for such dialects.
+type RetryClassifier interface {
+	// IsRetryable reports whether err, returned by a statement or the
+	// commit of a transaction, was caused by a deadlock or a
+	// serialization failure.
+	IsRetryable(err error) bool
+}
+
+// InTransaction runs fn in a transaction started with ctx and opts, which
+// may be nil. The transaction is committed if fn returns nil, and rolled
+// back if fn returns an error or panics, in which case the panic goes on
+// once the transaction is rolled back.
+//
+// When the dialect is a RetryClassifier and classifies the error of fn or
+// of the commit as a deadlock or serialization failure, the transaction is
+// run again, up to DefaultTransactionRetries times, after an exponential
+// backoff starting at DefaultTransactionRetryBackoff. fn must therefore not
+// have effects outside of the transaction, or must be able to repeat them.
+func (dbUtils *DbUtils) InTransaction(ctx context.Context, opts *sql.TxOptions, fn func(tx *Transaction) error) error {
+	m := dbUtils.WithContext(ctx).(*DbUtils)
+	classifier, _ := dbUtils.Dialect.(RetryClassifier)
+	backoff := DefaultTransactionRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := m.runTransaction(opts, fn)
+		if err == nil || classifier == nil || attempt >= DefaultTransactionRetries || !classifier.IsRetryable(err) {
+			return err
+		}
+
+		// the jitter keeps the transactions that deadlocked from
+		// retrying together
+		timer := time.NewTimer(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// runTransaction runs fn once in a transaction, committing it or rolling it
+// back.
+func (dbUtils *DbUtils) runTransaction(opts *sql.TxOptions, fn func(tx *Transaction) error) (err error) {
+	tx, err := dbUtils.BeginWithOptions(opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("godb: %w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// errorNumber returns the vendor error number found in the Number field of
+// err or of an error it wraps, as in the errors of the MySQL and SQL Server
+// drivers.
+func errorNumber(err error) (int64, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.Indirect(reflect.ValueOf(err))
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		f := v.FieldByName("Number")
+		switch f.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return f.Int(), true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int64(f.Uint()), true
+		}
+	}
+	return 0, false
+}
+
+// errorSQLState returns the SQLSTATE of err or of an error it wraps, given
+// by a SQLState method or a string Code field, as in the errors of the
+// Postgres drivers.
+func errorSQLState(err error) (string, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if s, ok := err.(interface{ SQLState() string }); ok {
+			return s.SQLState(), true
+		}
+		v := reflect.Indirect(reflect.ValueOf(err))
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		if f := v.FieldByName("Code"); f.Kind() == reflect.String {
+			return f.String(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,148 @@
+package godb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type fakeNumberError struct {
+	Number uint16
+}
+
+func (e *fakeNumberError) Error() string { return fmt.Sprintf("Error %d", e.Number) }
+
+type fakeCodeError struct {
+	Code string
+}
+
+func (e *fakeCodeError) Error() string { return "pq: " + e.Code }
+
+func TestDialect_IsRetryable(t *testing.T) {
+	tests := []struct {
+		dialect RetryClassifier
+		err     error
+		want    bool
+	}{
+		{MySQLDialect{}, &fakeNumberError{Number: 1213}, true},
+		{MySQLDialect{}, fmt.Errorf("insert: %w", &fakeNumberError{Number: 1213}), true},
+		{MySQLDialect{}, &fakeNumberError{Number: 1062}, false},
+		{MySQLDialect{}, errors.New("Error 1213 (40001): Deadlock found when trying to get lock"), true},
+		{PostgresDialect{}, &fakeCodeError{Code: "40001"}, true},
+		{PostgresDialect{}, &fakeCodeError{Code: "40P01"}, true},
+		{PostgresDialect{}, &fakeCodeError{Code: "23505"}, false},
+		{SqlServerDialect{}, &fakeNumberError{Number: 1205}, true},
+		{SqlServerDialect{}, &fakeNumberError{Number: 2627}, false},
+		{OracleDialect{}, errors.New("ORA-00060: deadlock detected while waiting for resource"), true},
+		{OracleDialect{}, errors.New("ORA-08177: can't serialize access for this transaction"), true},
+		{OracleDialect{}, errors.New("ORA-00001: unique constraint violated"), false},
+		{Db2Dialect{}, &fakeCodeError{Code: "40001"}, true},
+		{Db2Dialect{}, errors.New("SQL0911N  The current transaction has been rolled back because of a deadlock or timeout.  Reason code \"2\".  SQLSTATE=40001"), true},
+		{Db2Dialect{}, errors.New("SQLExecute: {57033} SQL0913N  Unsuccessful execution caused by deadlock or timeout."), true},
+		{Db2Dialect{}, errors.New("SQL0803N  One or more values in the INSERT statement are not valid.  SQLSTATE=23505"), false},
+		{MySQLDialect{}, nil, false},
+	}
+	for _, test := range tests {
+		if got := test.dialect.IsRetryable(test.err); got != test.want {
+			t.Errorf("%T.IsRetryable(%v) = %v, want %v", test.dialect, test.err, got, test.want)
+		}
+	}
+}
+
+var errFakeDeadlock = errors.New("fake deadlock")
+
+// retryingSqliteDialect classifies errFakeDeadlock as retryable.
+type retryingSqliteDialect struct {
+	SqliteDialect
+}
+
+func (d retryingSqliteDialect) IsRetryable(err error) bool {
+	return errors.Is(err, errFakeDeadlock)
+}
+
+func Test_InTransaction(t *testing.T) {
+	dbmap := initSqliteDB(t, "tx")
+	dbmap.Dialect = retryingSqliteDialect{}
+	if _, err := dbmap.Exec("create table tx_test (name varchar(20))"); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	count := func() int64 {
+		return selectInt(dbmap, "select count(*) from tx_test")
+	}
+	insert := func(tx *Transaction) error {
+		_, err := tx.Exec("insert into tx_test (name) values ('a')")
+		return err
+	}
+
+	if err := dbmap.InTransaction(ctx, nil, insert); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("expected the transaction committed, got %d rows", n)
+	}
+
+	failure := errors.New("failure")
+	err := dbmap.InTransaction(ctx, nil, func(tx *Transaction) error {
+		insert(tx)
+		return failure
+	})
+	if err != failure {
+		t.Errorf("expected the error of fn, got %v", err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("expected the transaction rolled back, got %d rows", n)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("expected the panic to go on, got %v", p)
+			}
+		}()
+		dbmap.InTransaction(ctx, nil, func(tx *Transaction) error {
+			insert(tx)
+			panic("boom")
+		})
+	}()
+	if n := count(); n != 1 {
+		t.Errorf("expected the transaction rolled back on panic, got %d rows", n)
+	}
+
+	attempts := 0
+	err = dbmap.InTransaction(ctx, nil, func(tx *Transaction) error {
+		attempts++
+		insert(tx)
+		if attempts < 3 {
+			return fmt.Errorf("update: %w", errFakeDeadlock)
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("expected success on the third attempt, got %v after %d", err, attempts)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("expected the failed attempts rolled back, got %d rows", n)
+	}
+
+	attempts = 0
+	err = dbmap.InTransaction(ctx, nil, func(tx *Transaction) error {
+		attempts++
+		return errFakeDeadlock
+	})
+	if !errors.Is(err, errFakeDeadlock) || attempts != DefaultTransactionRetries+1 {
+		t.Errorf("expected %d attempts, got %d: %v", DefaultTransactionRetries+1, attempts, err)
+	}
+
+	attempts = 0
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	dbmap.InTransaction(canceled, nil, func(tx *Transaction) error {
+		attempts++
+		return errFakeDeadlock
+	})
+	if attempts > 1 {
+		t.Errorf("expected no retry once the context is done, got %d attempts", attempts)
+	}
+}
@@ -2,51 +2,124 @@ package godb
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 )
 
+// RowsMap wraps the rows of a query run with QueryMap, and scans them into
+// maps, slices or several structs when there is no single struct to scan
+// into.
 type RowsMap struct {
-	Rows *sql.Rows
+	Rows    *sql.Rows
 	dbUtils *DbUtils
 }
 
+// MapValueNormalizer is implemented by dialects whose driver returns
+// values that need converting before being stored in a
+// map[string]interface{}, like MySQL returning numbers and dates as bytes.
+// Other dialects turn the []byte of non binary columns into strings.
+type MapValueNormalizer interface {
+	// NormalizeMapValue converts v, scanned from a column of the given
+	// database type name, as returned by sql.ColumnType.DatabaseTypeName.
+	NormalizeMapValue(typeName string, v interface{}) interface{}
+}
 
-/*
-func (rsMap *RowsMap)ScanStructByIndex(dest ...interface{}) error {
+// Next prepares the next row, like sql.Rows.Next.
+func (rsMap *RowsMap) Next() bool {
+	return rsMap.Rows.Next()
+}
 
-	if len(dest) == 0 {
-		return errors.New("at least one struct")
-	}
-	vvvs := make([]reflect.Value, len(dest))
-	for i, s := range dest {
-		vv := reflect.ValueOf(s)
-		if vv.Kind() != reflect.Ptr || vv.Elem().Kind() != reflect.Struct {
-			return errors.New("dest should be a struct's pointer")
-		}
+// Err returns the error met during the iteration, like sql.Rows.Err.
+func (rsMap *RowsMap) Err() error {
+	return rsMap.Rows.Err()
+}
+
+// Close closes the rows, like sql.Rows.Close.
+func (rsMap *RowsMap) Close() error {
+	return rsMap.Rows.Close()
+}
 
-		vvvs[i] = vv.Elem()
+// ScanStructByIndex scans the current row into the fields of several
+// structs, in the order of the columns: the mapped columns of the
+// registered tables, or all the exported fields otherwise. It reads the
+// rows of a join selecting the columns of each table in turn.
+func (rsMap *RowsMap) ScanStructByIndex(dest ...interface{}) error {
+	if len(dest) == 0 {
+		return errors.New("godb: ScanStructByIndex needs at least one struct")
 	}
 
 	cols, err := rsMap.Rows.Columns()
 	if err != nil {
 		return err
 	}
-	newDest := make([]interface{}, len(cols))
 
-	var i = 0
-	for _, vvv := range vvvs {
-		for j := 0; j < vvv.NumField(); j++ {
-			newDest[i] = vvv.Field(j).Addr().Interface()
-			i = i + 1
+	var (
+		newDest  []interface{}
+		custScan []CustomScanner
+		conv     = rsMap.dbUtils.TypeConverter
+	)
+	for _, s := range dest {
+		v := reflect.ValueOf(s)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("godb: ScanStructByIndex needs struct pointers, got %T", s)
+		}
+		for _, f := range structFieldsByIndex(rsMap.dbUtils, v.Elem()) {
+			target := f.Addr().Interface()
+			if conv != nil {
+				if scanner, ok := conv.FromDb(target); ok {
+					target = scanner.Holder
+					custScan = append(custScan, scanner)
+				}
+			}
+			newDest = append(newDest, target)
+		}
+	}
+	if len(newDest) != len(cols) {
+		return fmt.Errorf("godb: ScanStructByIndex got %d columns for %d fields", len(cols), len(newDest))
+	}
+
+	if err := rsMap.Rows.Scan(newDest...); err != nil {
+		return err
+	}
+	for _, c := range custScan {
+		if err := c.Bind(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structFieldsByIndex returns the fields of v matching the columns of its
+// table in order, or its exported fields if the type is not registered.
+func structFieldsByIndex(dbUtils *DbUtils, v reflect.Value) []reflect.Value {
+	var fields []reflect.Value
+	if table := tableOrNil(dbUtils, v.Type(), ""); table != nil {
+		for _, col := range table.Columns {
+			if !col.Transient {
+				fields = append(fields, v.FieldByIndex(col.fieldIndex))
+			}
 		}
+		return fields
 	}
 
-	return rsMap.Rows.Scan(newDest...)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).PkgPath == "" {
+			fields = append(fields, v.Field(i))
+		}
+	}
+	return fields
 }
 
+// ScanMap scans the current row into dest, a pointer to a map from column
+// names to values. Values stored in a map[string]interface{} are
+// normalized: the []byte of non binary columns become strings, and MySQL
+// numbers and dates are parsed.
 func (rsMap *RowsMap) ScanMap(dest interface{}) error {
 	vv := reflect.ValueOf(dest)
-	if vv.Kind() != reflect.Ptr || vv.Elem().Kind() != reflect.Map {
-		return errors.New("dest should be a map's pointer")
+	if vv.Kind() != reflect.Ptr || vv.Elem().Kind() != reflect.Map || vv.Elem().Type().Key().Kind() != reflect.String {
+		return errors.New("godb: dest should be a pointer to a map with string keys")
 	}
 
 	cols, err := rsMap.Rows.Columns()
@@ -54,34 +127,43 @@ func (rsMap *RowsMap) ScanMap(dest interface{}) error {
 		return err
 	}
 
-	newDest := make([]interface{}, len(cols))
 	vvv := vv.Elem()
-
-	for i, _ := range cols {
-		newDest[i] = rsMap.DbUtils.reflectNew(vvv.Type().Elem()).Interface()
-
+	if vvv.IsNil() {
+		vvv.Set(reflect.MakeMap(vvv.Type()))
+	}
+	elemType := vvv.Type().Elem()
+	newDest := make([]interface{}, len(cols))
+	for i := range cols {
+		newDest[i] = reflect.New(elemType).Interface()
 	}
 
-	err = rsMap.Rows.Scan(newDest...)
-	if err != nil {
+	if err := rsMap.Rows.Scan(newDest...); err != nil {
 		return err
 	}
 
+	var types []*sql.ColumnType
+	if elemType.Kind() == reflect.Interface {
+		if types, err = rsMap.Rows.ColumnTypes(); err != nil {
+			return err
+		}
+	}
 	for i, name := range cols {
-		vname := reflect.ValueOf(name)
-		//fmt.Println(reflect.TypeOf(newDest[i]))
-		//fmt.Println(reflect.ValueOf(newDest[i]))
-		//fmt.Println(reflect.ValueOf(newDest[i]).Elem())
-		vvv.SetMapIndex(vname, reflect.ValueOf(newDest[i]).Elem())
+		value := reflect.ValueOf(newDest[i]).Elem()
+		if types != nil && !value.IsNil() {
+			value = reflect.ValueOf(normalizeMapValue(rsMap.dbUtils.Dialect, types[i].DatabaseTypeName(), value.Interface()))
+		}
+		vvv.SetMapIndex(reflect.ValueOf(name), value)
 	}
-
 	return nil
 }
 
+// ScanSlice scans the current row into the elements of dest, a pointer to
+// a slice, in the order of the columns. Existing elements are scanned
+// into, and the slice is extended to the number of columns.
 func (rsMap *RowsMap) ScanSlice(dest interface{}) error {
 	vv := reflect.ValueOf(dest)
 	if vv.Kind() != reflect.Ptr || vv.Elem().Kind() != reflect.Slice {
-		return errors.New("dest should be a slice's pointer")
+		return errors.New("godb: dest should be a pointer to a slice")
 	}
 
 	vvv := vv.Elem()
@@ -91,7 +173,6 @@ func (rsMap *RowsMap) ScanSlice(dest interface{}) error {
 	}
 
 	newDest := make([]interface{}, len(cols))
-
 	for j := 0; j < len(cols); j++ {
 		if j >= vvv.Len() {
 			newDest[j] = reflect.New(vvv.Type().Elem()).Interface()
@@ -100,46 +181,69 @@ func (rsMap *RowsMap) ScanSlice(dest interface{}) error {
 		}
 	}
 
-	err = rsMap.Rows.Scan(newDest...)
-	if err != nil {
+	if err := rsMap.Rows.Scan(newDest...); err != nil {
 		return err
 	}
 
-	srcLen := vvv.Len()
-	for i := srcLen; i < len(cols); i++ {
-		vvv = reflect.Append(vvv, reflect.ValueOf(newDest[i]).Elem())
+	for i := vvv.Len(); i < len(cols); i++ {
+		vvv.Set(reflect.Append(vvv, reflect.ValueOf(newDest[i]).Elem()))
 	}
 	return nil
 }
 
+// selectMaps runs query and returns each row as a map from column names
+// to normalized values.
+func selectMaps(dbUtils *DbUtils, queryRunner SqlQueryRunner, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rsMap, err := queryMap(dbUtils, queryRunner, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rsMap.Close()
 
+	list := make([]map[string]interface{}, 0)
+	for rsMap.Next() {
+		m := make(map[string]interface{})
+		if err := rsMap.ScanMap(&m); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	if err := rsMap.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
 
+func queryMap(dbUtils *DbUtils, queryRunner SqlQueryRunner, query string, args ...interface{}) (*RowsMap, error) {
+	if len(args) == 1 {
+		query, args = maybeExpandNamedQuery(dbUtils, query, args)
+	}
+	rows, err := queryRunner.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &RowsMap{Rows: rows, dbUtils: dbUtils}, nil
+}
 
-
-type RowMap struct {
-	RowsMap *RowsMap
-	// One of these two will be non-nil:
-	Err error // deferred error for easy chaining
+// binaryTypes are the database type names whose []byte values are kept.
+var binaryTypes = map[string]bool{
+	"BLOB": true, "TINYBLOB": true, "MEDIUMBLOB": true, "LONGBLOB": true,
+	"BINARY": true, "VARBINARY": true, "BYTEA": true, "IMAGE": true,
+	"RAW": true, "LONG RAW": true,
 }
 
-func (rowMap *RowMap) ScanStructByIndex(dest interface{}) error {
-	if rowMap.Err != nil {
-		return rowMap.Err
+func normalizeMapValue(d Dialect, typeName string, v interface{}) interface{} {
+	if n, ok := d.(MapValueNormalizer); ok {
+		return n.NormalizeMapValue(typeName, v)
 	}
-	defer rowMap.RowsMap.Rows.Close()
+	return standardNormalizeMapValue(typeName, v)
+}
 
-	if !rowMap.RowsMap.Rows.Next() {
-		if err := rowMap.RowsMap.Rows.Err(); err != nil {
-			return err
-		}
-		return sql.ErrNoRows
+// standardNormalizeMapValue turns the []byte of non binary columns into
+// strings.
+func standardNormalizeMapValue(typeName string, v interface{}) interface{} {
+	if b, ok := v.([]byte); ok && !binaryTypes[strings.ToUpper(typeName)] {
+		return string(b)
 	}
-	err := rowMap.RowsMap.ScanStructByIndex(dest)
-	if err != nil {
-		return err
-	}
-	// Make sure the query can be processed to completion with no errors.
-	return rowMap.RowsMap.Rows.Close()
+	return v
 }
-
-*/
\ No newline at end of file
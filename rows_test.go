@@ -3,8 +3,113 @@ package godb
 import (
 	"testing"
 	"fmt"
+	"reflect"
+	"time"
 )
 
 func Test_query(t *testing.T)  {
 	fmt.Println("test_query")
-}
\ No newline at end of file
+}
+
+type RowsPerson struct {
+	Id      int64  `db:"id, primarykey, autoincrement"`
+	Name    string `db:"name, size:50"`
+	Comment string `db:"-"`
+}
+
+type RowsOrder struct {
+	Id       int64 `db:"id, primarykey, autoincrement"`
+	PersonId int64 `db:"person_id"`
+	Total    float64 `db:"total"`
+}
+
+func TestDialect_NormalizeMapValue(t *testing.T) {
+	created := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		dialect  Dialect
+		typeName string
+		value    interface{}
+		want     interface{}
+	}{
+		{MySQLDialect{}, "VARCHAR", []byte("cly"), "cly"},
+		{MySQLDialect{}, "BIGINT", []byte("42"), int64(42)},
+		{MySQLDialect{}, "UNSIGNED BIGINT", []byte("18446744073709551615"), uint64(18446744073709551615)},
+		{MySQLDialect{}, "DOUBLE", []byte("1.5"), 1.5},
+		{MySQLDialect{}, "DECIMAL", []byte("1.50"), "1.50"},
+		{MySQLDialect{}, "DATETIME", []byte("2020-05-17 10:30:00"), created},
+		{MySQLDialect{}, "BLOB", []byte{1, 2}, []byte{1, 2}},
+		{PostgresDialect{}, "NUMERIC", []byte("1.50"), "1.50"},
+		{PostgresDialect{}, "BYTEA", []byte{1, 2}, []byte{1, 2}},
+		{PostgresDialect{}, "TIMESTAMPTZ", created, created},
+		{SqliteDialect{}, "TEXT", []byte("cly"), "cly"},
+		{SqliteDialect{}, "INTEGER", int64(3), int64(3)},
+	}
+	for _, test := range tests {
+		if got := normalizeMapValue(test.dialect, test.typeName, test.value); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%T %s: got %#v, want %#v", test.dialect, test.typeName, got, test.want)
+		}
+	}
+}
+
+func Test_SelectMaps(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	dbmap.AddTableWithName(RowsPerson{}, "rows_person_test")
+	dbmap.AddTableWithName(RowsOrder{}, "rows_order_test")
+	dbmap.DropTablesIfExists()
+	if err := dbmap.CreateTables(); err != nil {
+		panic(err)
+	}
+	defer dbmap.DropTablesIfExists()
+
+	p := &RowsPerson{Name: "cly"}
+	_insert(dbmap, p)
+	_insert(dbmap, &RowsOrder{PersonId: p.Id, Total: 12.5}, &RowsOrder{PersonId: p.Id, Total: 7})
+
+	maps, err := dbmap.SelectMaps("select p.name, o.total, o.id from rows_person_test p join rows_order_test o on o.person_id = p.id order by o.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) != 2 || maps[0]["name"] != "cly" || maps[1]["total"] != float64(7) {
+		t.Errorf("unexpected maps %v", maps)
+	}
+	if _, ok := maps[0]["id"].(int64); !ok {
+		t.Errorf("id was not normalized to an int64: %#v", maps[0]["id"])
+	}
+
+	rows, err := dbmap.QueryMap("select p.*, o.* from rows_person_test p join rows_order_test o on o.person_id = p.id order by o.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var orders []RowsOrder
+	for rows.Next() {
+		var person RowsPerson
+		var order RowsOrder
+		if err := rows.ScanStructByIndex(&person, &order); err != nil {
+			t.Fatal(err)
+		}
+		if person.Name != "cly" || order.PersonId != p.Id {
+			t.Errorf("unexpected row %+v %+v", person, order)
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil || len(orders) != 2 || orders[0].Total != 12.5 {
+		t.Errorf("unexpected orders %+v: %v", orders, err)
+	}
+
+	rows, err = dbmap.QueryMap("select name, name from rows_person_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var names []string
+		if err := rows.ScanSlice(&names); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(names, []string{"cly", "cly"}) {
+			t.Errorf("unexpected slice %v", names)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package godb
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestDialect_SavepointSql(t *testing.T) {
+	tests := []struct {
+		dialect                      Savepointer
+		savepoint, rollback, release string
+	}{
+		{SqlServerDialect{}, "save transaction [sp]", "rollback transaction [sp]", ""},
+		{OracleDialect{}, `savepoint "SP"`, `rollback to savepoint "SP"`, ""},
+		{Db2Dialect{}, `savepoint "SP" on rollback retain cursors`, `rollback to savepoint "SP"`, `release savepoint "SP"`},
+	}
+	for _, test := range tests {
+		if got := test.dialect.SavepointSql("sp"); got != test.savepoint {
+			t.Errorf("%T.SavepointSql = %q, want %q", test.dialect, got, test.savepoint)
+		}
+		if got := test.dialect.RollbackToSavepointSql("sp"); got != test.rollback {
+			t.Errorf("%T.RollbackToSavepointSql = %q, want %q", test.dialect, got, test.rollback)
+		}
+		if got := test.dialect.ReleaseSavepointSql("sp"); got != test.release {
+			t.Errorf("%T.ReleaseSavepointSql = %q, want %q", test.dialect, got, test.release)
+		}
+	}
+}
+
+func Test_NestedTransactions(t *testing.T) {
+	dbmap := initSqliteDB(t, "nested")
+	dbmap.Db.SetMaxOpenConns(1)
+	if _, err := dbmap.Exec("create table nested_tx_test (name varchar(20))"); err != nil {
+		t.Fatal(err)
+	}
+	insert := func(tx *Transaction, name string) {
+		if _, err := tx.Exec("insert into nested_tx_test (name) values (?)", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(tx *Transaction) string {
+		s, err := tx.SelectStr("select coalesce(group_concat(name, ','), '') from (select name from nested_tx_test order by name)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	trans, err := dbmap.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(trans, "a")
+
+	child, err := trans.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(child, "b")
+	if err := child.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := child.Commit(); err != sql.ErrTxDone {
+		t.Errorf("expected ErrTxDone committing twice, got %v", err)
+	}
+
+	child, err = trans.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(child, "c")
+	grandchild, err := child.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(grandchild, "d")
+	if err := trans.Commit(); err == nil {
+		t.Error("expected an error committing with an open nested transaction")
+	}
+	if err := child.Commit(); err == nil {
+		t.Error("expected an error committing with an open nested transaction")
+	}
+	if err := grandchild.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if s := names(child); s != "a,b,c,d" {
+		t.Errorf("expected a,b,c,d, got %s", s)
+	}
+	if err := child.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if s := names(trans); s != "a,b" {
+		t.Errorf("expected the rollback of the nested transaction, got %s", s)
+	}
+
+	child, err = trans.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	grandchild, err = child.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(grandchild, "e")
+	if err := child.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := grandchild.Commit(); err != sql.ErrTxDone {
+		t.Errorf("expected ErrTxDone once the enclosing transaction rolled back, got %v", err)
+	}
+
+	if err := trans.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := selectInt(dbmap, "select count(*) from nested_tx_test"); n != 2 {
+		t.Errorf("expected 2 rows committed, got %d", n)
+	}
+}
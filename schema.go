@@ -0,0 +1,518 @@
+package godb
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SchemaInspector is implemented by dialects that can read the structure of
+// the tables of a database from its catalog.
+type SchemaInspector interface {
+	// InspectSchema reads the tables of schema, or of the current schema
+	// of the connection if schema is empty.
+	InspectSchema(queryRunner SqlQueryRunner, schema string) (*Schema, error)
+
+	// NormalizeSqlType returns the canonical spelling of a column type, so
+	// that a type returned by ToSqlType can be compared with one read from
+	// the catalog.
+	NormalizeSqlType(sqlType string) string
+}
+
+// SchemaAlterer is implemented by dialects that can generate the
+// statements changing the columns of an existing table.
+type SchemaAlterer interface {
+	AddColumnSql(schema, table string, col *ColumnMap) string
+	DropColumnSql(schema, table, column string) string
+
+	// AlterColumnSql returns the statements changing the type or
+	// nullability of an existing column, as reported by diff, to the ones
+	// of diff.Column, or nil if the dialect cannot alter columns.
+	AlterColumnSql(schema, table string, diff *ColumnDiff) []string
+}
+
+// Schema is the structure of the tables of a database schema, as read by
+// InspectSchema.
+type Schema struct {
+	Name   string
+	Tables []*TableSchema
+}
+
+// Table returns the table with the given name, compared case
+// insensitively, or nil.
+func (s *Schema) Table(name string) *TableSchema {
+	for _, t := range s.Tables {
+		if strings.EqualFold(t.Name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+// TableSchema is the structure of a table read from the database catalog.
+type TableSchema struct {
+	Name        string
+	Columns     []*ColumnSchema
+	PrimaryKey  []string
+	Indexes     []*IndexSchema
+	ForeignKeys []*ForeignKeySchema
+}
+
+// Column returns the column with the given name, compared case
+// insensitively, or nil.
+func (t *TableSchema) Column(name string) *ColumnSchema {
+	for _, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Index returns the index with the given name, compared case
+// insensitively, or nil.
+func (t *TableSchema) Index(name string) *IndexSchema {
+	for _, idx := range t.Indexes {
+		if strings.EqualFold(idx.Name, name) {
+			return idx
+		}
+	}
+	return nil
+}
+
+// ColumnSchema is a column of a table in the database. Type is spelled as
+// in the catalog.
+type ColumnSchema struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+// IndexSchema is an index other than the primary key.
+type IndexSchema struct {
+	Name   string
+	Unique bool
+
+	// Type is the index method reported by the database, like "btree", or
+	// empty if the catalog does not record it.
+	Type    string
+	Columns []string
+}
+
+// ForeignKeySchema is a foreign key of a table, referencing RefColumns of
+// RefTable.
+type ForeignKeySchema struct {
+	Name       string
+	Columns    []string
+	RefSchema  string
+	RefTable   string
+	RefColumns []string
+}
+
+// InspectSchema reads the tables, columns, keys and indexes of the current
+// schema of the database.
+func (dbUtils *DbUtils) InspectSchema() (*Schema, error) {
+	return dbUtils.InspectSchemaWithName("")
+}
+
+// InspectSchemaWithName reads the tables, columns, keys and indexes of the
+// named schema.
+func (dbUtils *DbUtils) InspectSchemaWithName(schema string) (*Schema, error) {
+	inspector, ok := dbUtils.Dialect.(SchemaInspector)
+	if !ok {
+		return nil, fmt.Errorf("godb: dialect %T does not support schema inspection", dbUtils.Dialect)
+	}
+	return inspector.InspectSchema(dbUtils, schema)
+}
+
+// catalogQueries are the queries reading a schema from the catalog of a
+// database. Each query selects the schema and table names first, and
+// takes the schema name as its only argument.
+type catalogQueries struct {
+	// columns selects the column name, its type and 1 if it is nullable,
+	// ordered by position.
+	columns string
+
+	// primaryKeys selects the key columns ordered by position.
+	primaryKeys string
+
+	// indexes selects the index name, 1 if it is unique, its type and the
+	// column name, ordered by position in the index.
+	indexes string
+
+	// foreignKeys selects the constraint name, the column name and the
+	// referenced schema, table and column, ordered by position.
+	foreignKeys string
+}
+
+// inspectCatalog runs queries and assembles their rows into a Schema.
+func inspectCatalog(queryRunner SqlQueryRunner, queries catalogQueries, schema string) (*Schema, error) {
+	s := &Schema{Name: schema}
+	table := func(schemaName, name string) *TableSchema {
+		s.Name = schemaName
+		t := s.Table(name)
+		if t == nil {
+			t = &TableSchema{Name: name}
+			s.Tables = append(s.Tables, t)
+		}
+		return t
+	}
+
+	err := scanCatalog(queryRunner, queries.columns, schema, func(row []string) {
+		t := table(row[0], row[1])
+		t.Columns = append(t.Columns, &ColumnSchema{Name: row[2], Type: row[3], Nullable: row[4] == "1"})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanCatalog(queryRunner, queries.primaryKeys, schema, func(row []string) {
+		t := table(row[0], row[1])
+		t.PrimaryKey = append(t.PrimaryKey, row[2])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanCatalog(queryRunner, queries.indexes, schema, func(row []string) {
+		t := table(row[0], row[1])
+		idx := t.Index(row[2])
+		if idx == nil {
+			idx = &IndexSchema{Name: row[2], Unique: row[3] == "1", Type: row[4]}
+			t.Indexes = append(t.Indexes, idx)
+		}
+		idx.Columns = append(idx.Columns, row[5])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanCatalog(queryRunner, queries.foreignKeys, schema, func(row []string) {
+		t := table(row[0], row[1])
+		var fk *ForeignKeySchema
+		for _, f := range t.ForeignKeys {
+			if f.Name == row[2] {
+				fk = f
+			}
+		}
+		if fk == nil {
+			fk = &ForeignKeySchema{Name: row[2], RefSchema: row[4], RefTable: row[5]}
+			t.ForeignKeys = append(t.ForeignKeys, fk)
+		}
+		fk.Columns = append(fk.Columns, row[3])
+		fk.RefColumns = append(fk.RefColumns, row[6])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// scanCatalog calls fn with the columns of each row of query as strings,
+// NULL being read as "".
+func scanCatalog(queryRunner SqlQueryRunner, query, schema string, fn func(row []string)) error {
+	rows, err := queryRunner.Query(query, schema)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = v.String
+		}
+		fn(row)
+	}
+	return rows.Err()
+}
+
+// normalizeSqlType lowercases sqlType, collapses its white space, and
+// replaces it by its alias if it has one.
+func normalizeSqlType(sqlType string, aliases map[string]string) string {
+	t := strings.Join(strings.Fields(strings.ToLower(sqlType)), " ")
+	t = strings.Replace(t, " (", "(", -1)
+	t = strings.Replace(t, ", ", ",", -1)
+	if alias, ok := aliases[t]; ok {
+		return alias
+	}
+	return t
+}
+
+// columnDefinition returns the type, nullability and default of col for
+// "create table" and "alter table" statements.
+func columnDefinition(d Dialect, col *ColumnMap) string {
+	s := d.ToSqlType(col.gotype, col.MaxSize, col.isAutoIncr)
+	if col.isPK || col.isNotNull {
+		s += " not null"
+	}
+	if col.isAutoIncr && d.AutoIncrStr() != "" {
+		s += " " + d.AutoIncrStr()
+	}
+	if col.DefaultValue != "" {
+		s += " default " + col.DefaultValue
+	}
+	return s
+}
+
+// SchemaDiff lists the differences between the registered tables and the
+// database, and the statements reconciling them.
+type SchemaDiff struct {
+	// MissingTables are registered tables that do not exist in the
+	// database.
+	MissingTables []*TableMap
+
+	// ExtraTables are tables of the inspected schemas that are not
+	// registered. They are reported without statements dropping them.
+	ExtraTables []*TableSchema
+
+	// Tables lists the registered tables whose columns differ.
+	Tables []*TableDiff
+
+	// Statements creates the missing tables and indexes, and adds and
+	// alters the columns of the tables that differ. Extra columns are only
+	// dropped with SchemaDiffOptions.DropColumns.
+	Statements []string
+}
+
+// TableDiff lists the differences between a registered table and the
+// database table of the same name.
+type TableDiff struct {
+	Table  *TableMap
+	Actual *TableSchema
+
+	// MissingColumns are mapped columns that do not exist in the table.
+	// Those that are not null without a DefaultValue are added as
+	// nullable.
+	MissingColumns []*ColumnMap
+
+	// ExtraColumns are columns of the table that are not mapped.
+	ExtraColumns []*ColumnSchema
+
+	// ChangedColumns are mapped columns whose type or nullability
+	// differs from the table.
+	ChangedColumns []*ColumnDiff
+
+	// PrimaryKeyChanged is true if the mapped keys are not the primary key
+	// of the table. No statement is generated for it.
+	PrimaryKeyChanged bool
+
+	// MissingIndexes are indexes added with AddIndex that do not exist on
+	// the table.
+	MissingIndexes []*IndexMap
+}
+
+// ColumnDiff is a mapped column whose type or nullability differs from the
+// column of the same name in the database.
+type ColumnDiff struct {
+	Column *ColumnMap
+	Actual *ColumnSchema
+
+	// ExpectedType is the type of the mapped column in the dialect.
+	ExpectedType    string
+	TypeChanged     bool
+	NullableChanged bool
+}
+
+// Empty is true if the table matches its mapping.
+func (d *TableDiff) Empty() bool {
+	return len(d.MissingColumns) == 0 && len(d.ExtraColumns) == 0 &&
+		len(d.ChangedColumns) == 0 && !d.PrimaryKeyChanged && len(d.MissingIndexes) == 0
+}
+
+// SchemaDiffOptions configures DiffSchemaWithOptions.
+type SchemaDiffOptions struct {
+	// DropColumns adds statements dropping the columns that are not
+	// mapped. They are otherwise only reported, as they may be written by
+	// another application or by a version not deployed yet.
+	DropColumns bool
+}
+
+// DiffSchema compares the registered tables with the tables of their
+// schemas in the database. Extra columns are reported without statements
+// dropping them.
+func (dbUtils *DbUtils) DiffSchema() (*SchemaDiff, error) {
+	return dbUtils.DiffSchemaWithOptions(SchemaDiffOptions{})
+}
+
+// DiffSchemaWithOptions is the same as DiffSchema, with opts choosing the
+// statements generated.
+func (dbUtils *DbUtils) DiffSchemaWithOptions(opts SchemaDiffOptions) (*SchemaDiff, error) {
+	inspector, ok := dbUtils.Dialect.(SchemaInspector)
+	if !ok {
+		return nil, fmt.Errorf("godb: dialect %T does not support schema inspection", dbUtils.Dialect)
+	}
+	alterer, _ := dbUtils.Dialect.(SchemaAlterer)
+
+	diff := &SchemaDiff{}
+	schemas := make(map[string]*Schema)
+	var names []string
+	for _, table := range dbUtils.tables {
+		if _, ok := schemas[table.SchemaName]; ok {
+			continue
+		}
+		schema, err := inspector.InspectSchema(dbUtils, table.SchemaName)
+		if err != nil {
+			return nil, err
+		}
+		schemas[table.SchemaName] = schema
+		names = append(names, table.SchemaName)
+	}
+
+	for _, table := range dbUtils.tables {
+		actual := schemas[table.SchemaName].Table(table.TableName)
+		if actual == nil {
+			diff.MissingTables = append(diff.MissingTables, table)
+			diff.Statements = append(diff.Statements, table.CreateTableSql(false))
+			for _, idx := range table.indexes {
+				diff.Statements = append(diff.Statements, table.createIndexSql(idx))
+			}
+			continue
+		}
+
+		td := table.diff(inspector, actual)
+		if td.Empty() {
+			continue
+		}
+		diff.Tables = append(diff.Tables, td)
+		if alterer != nil {
+			for _, col := range td.MissingColumns {
+				diff.Statements = append(diff.Statements, alterer.AddColumnSql(table.SchemaName, table.TableName, col))
+			}
+			for _, col := range td.ChangedColumns {
+				diff.Statements = append(diff.Statements, alterer.AlterColumnSql(table.SchemaName, table.TableName, col)...)
+			}
+			for _, col := range td.ExtraColumns {
+				if !opts.DropColumns {
+					break
+				}
+				diff.Statements = append(diff.Statements, alterer.DropColumnSql(table.SchemaName, table.TableName, col.Name))
+			}
+		}
+		// after the columns they may be created on
+		for _, idx := range td.MissingIndexes {
+			diff.Statements = append(diff.Statements, table.createIndexSql(idx))
+		}
+	}
+
+	for _, name := range names {
+		for _, actual := range schemas[name].Tables {
+			if tableOrNilByName(dbUtils, name, actual.Name) == nil {
+				diff.ExtraTables = append(diff.ExtraTables, actual)
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+func tableOrNilByName(dbUtils *DbUtils, schema, name string) *TableMap {
+	for _, table := range dbUtils.tables {
+		if table.SchemaName == schema && strings.EqualFold(table.TableName, name) {
+			return table
+		}
+	}
+	return nil
+}
+
+func (t *TableMap) diff(inspector SchemaInspector, actual *TableSchema) *TableDiff {
+	d := &TableDiff{Table: t, Actual: actual}
+	dialect := t.dbUtils.Dialect
+
+	for _, col := range t.Columns {
+		if col.Transient {
+			continue
+		}
+		ac := actual.Column(col.ColumnName)
+		if ac == nil {
+			d.MissingColumns = append(d.MissingColumns, col)
+			continue
+		}
+
+		expected := dialect.ToSqlType(col.gotype, col.MaxSize, col.isAutoIncr)
+		cd := &ColumnDiff{
+			Column:          col,
+			Actual:          ac,
+			ExpectedType:    expected,
+			TypeChanged:     inspector.NormalizeSqlType(expected) != inspector.NormalizeSqlType(ac.Type),
+			NullableChanged: (col.isPK || col.isNotNull) == ac.Nullable,
+		}
+		if cd.TypeChanged || cd.NullableChanged {
+			d.ChangedColumns = append(d.ChangedColumns, cd)
+		}
+	}
+
+	for _, ac := range actual.Columns {
+		if col := colMapOrNilByColumn(t, ac.Name); col == nil || col.Transient {
+			d.ExtraColumns = append(d.ExtraColumns, ac)
+		}
+	}
+
+	for _, idx := range t.indexes {
+		if actual.Index(idx.IndexName) == nil {
+			d.MissingIndexes = append(d.MissingIndexes, idx)
+		}
+	}
+
+	if len(t.keys) != len(actual.PrimaryKey) {
+		d.PrimaryKeyChanged = true
+	} else {
+		for i, key := range t.keys {
+			if !strings.EqualFold(key.ColumnName, actual.PrimaryKey[i]) {
+				d.PrimaryKeyChanged = true
+			}
+		}
+	}
+
+	return d
+}
+
+func colMapOrNilByColumn(t *TableMap, column string) *ColumnMap {
+	for _, col := range t.Columns {
+		if strings.EqualFold(col.ColumnName, column) {
+			return col
+		}
+	}
+	return nil
+}
+
+// addColumnDefinition returns the definition of col for "alter table ...
+// add". A not null column without a default is added as nullable, as the
+// rows of the table would have no value for it: it is reported as changed
+// by the next diff, once filled.
+func addColumnDefinition(d Dialect, col *ColumnMap) string {
+	if col.DefaultValue == "" && !col.isAutoIncr && (col.isPK || col.isNotNull) {
+		nullable := *col
+		nullable.isPK, nullable.isNotNull = false, false
+		return columnDefinition(d, &nullable)
+	}
+	return columnDefinition(d, col)
+}
+
+// standardAddColumnSql is the "alter table ... add column" statement shared
+// by most dialects.
+func standardAddColumnSql(d Dialect, schema, table string, col *ColumnMap) string {
+	return fmt.Sprintf("alter table %s add column %s %s%s", d.QuotedTableForQuery(schema, table),
+		d.QuoteField(col.ColumnName), addColumnDefinition(d, col), d.QuerySuffix())
+}
+
+// standardDropColumnSql is the "alter table ... drop column" statement
+// shared by most dialects.
+func standardDropColumnSql(d Dialect, schema, table, column string) string {
+	return fmt.Sprintf("alter table %s drop column %s%s", d.QuotedTableForQuery(schema, table),
+		d.QuoteField(column), d.QuerySuffix())
+}
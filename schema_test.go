@@ -0,0 +1,216 @@
+package godb
+
+import (
+	"reflect"
+	"testing"
+)
+
+type SchemaPerson struct {
+	Id    int64  `db:"id, primarykey, autoincrement"`
+	Name  string `db:"name, size:50, notnull"`
+	Email string `db:"email, size:100"`
+	Age   int    `db:"age"`
+}
+
+func TestDialect_NormalizeSqlType(t *testing.T) {
+	tests := []struct {
+		dialect          SchemaInspector
+		declared, actual string
+	}{
+		{MySQLDialect{}, "boolean", "tinyint(1)"},
+		{MySQLDialect{}, "bigint", "bigint(20)"},
+		{MySQLDialect{}, "int unsigned", "INT(10) UNSIGNED"},
+		{MySQLDialect{}, "varchar(255)", "varchar(255)"},
+		{PostgresDialect{}, "bigserial", "bigint"},
+		{PostgresDialect{}, "varchar(50)", "character varying(50)"},
+		{PostgresDialect{}, "timestamp with time zone", "timestamp with time zone"},
+		{SqliteDialect{}, "varchar(255)", "VARCHAR (255)"},
+		{SqlServerDialect{}, "float(53)", "float"},
+		{SqlServerDialect{}, "nvarchar(max)", "nvarchar(max)"},
+		{OracleDialect{}, "varchar(50)", "VARCHAR2(50)"},
+		{Db2Dialect{}, "double precision", "DOUBLE"},
+		{Db2Dialect{}, "varchar(50)", "VARCHAR(50)"},
+		{Db2Dialect{}, "char(10)", "CHARACTER(10)"},
+		{OracleDialect{}, "bigint", "INTEGER"},
+	}
+	for _, test := range tests {
+		if a, b := test.dialect.NormalizeSqlType(test.declared), test.dialect.NormalizeSqlType(test.actual); a != b {
+			t.Errorf("%T: %s normalized to %s, %s normalized to %s", test.dialect, test.declared, a, test.actual, b)
+		}
+	}
+	mysql := MySQLDialect{}
+	if mysql.NormalizeSqlType("tinyint") == mysql.NormalizeSqlType("tinyint(1)") {
+		t.Error("MySQL tinyint and boolean normalized to the same type")
+	}
+}
+
+func TestTableMap_diff(t *testing.T) {
+	dbUtils := &DbUtils{Dialect: PostgresDialect{}}
+	table := dbUtils.AddTableWithName(SchemaPerson{}, "person")
+	actual := &TableSchema{
+		Name: "person",
+		Columns: []*ColumnSchema{
+			{Name: "id", Type: "bigint", Nullable: false},
+			{Name: "name", Type: "character varying(50)", Nullable: true},
+			{Name: "age", Type: "bigint", Nullable: true},
+			{Name: "nickname", Type: "text", Nullable: true},
+		},
+		PrimaryKey: []string{"id"},
+	}
+
+	d := table.diff(PostgresDialect{}, actual)
+	if len(d.MissingColumns) != 1 || d.MissingColumns[0].ColumnName != "email" {
+		t.Errorf("unexpected missing columns %v", d.MissingColumns)
+	}
+	if len(d.ExtraColumns) != 1 || d.ExtraColumns[0].Name != "nickname" {
+		t.Errorf("unexpected extra columns %v", d.ExtraColumns)
+	}
+	if len(d.ChangedColumns) != 2 || d.PrimaryKeyChanged {
+		t.Fatalf("unexpected changes %+v", d)
+	}
+	name, age := d.ChangedColumns[0], d.ChangedColumns[1]
+	if name.TypeChanged || !name.NullableChanged || !age.TypeChanged || age.NullableChanged {
+		t.Errorf("unexpected column changes %+v %+v", name, age)
+	}
+
+	tests := []struct {
+		dialect Dialect
+		stmts   []string
+	}{
+		{PostgresDialect{}, []string{
+			`alter table "person" add column "email" varchar(100);`,
+			`alter table "person" alter column "name" set not null;`,
+			`alter table "person" alter column "age" type integer;`,
+			`alter table "person" drop column "nickname";`,
+		}},
+		{MySQLDialect{}, []string{
+			"alter table `person` add column `email` varchar(100);",
+			"alter table `person` modify column `name` varchar(50) not null;",
+			"alter table `person` modify column `age` int;",
+			"alter table `person` drop column `nickname`;",
+		}},
+		{SqliteDialect{}, []string{
+			`alter table "person" add column "email" varchar(100);`,
+			`alter table "person" drop column "nickname";`,
+		}},
+		{SqlServerDialect{}, []string{
+			"alter table [person] add [email] nvarchar(100);",
+			"alter table [person] alter column [name] nvarchar(50) not null;",
+			"alter table [person] alter column [age] int null;",
+			"alter table [person] drop column [nickname];",
+		}},
+		{Db2Dialect{}, []string{
+			`alter table "PERSON" add column "EMAIL" varchar(100)`,
+			`alter table "PERSON" alter column "NAME" set not null`,
+			`alter table "PERSON" alter column "AGE" set data type integer`,
+			`alter table "PERSON" drop column "NICKNAME"`,
+		}},
+		{OracleDialect{}, []string{
+			`alter table "PERSON" add ("EMAIL" varchar(100))`,
+			`alter table "PERSON" modify ("NAME" not null)`,
+			`alter table "PERSON" modify ("AGE" integer)`,
+			`alter table "PERSON" drop column "NICKNAME"`,
+		}},
+	}
+	for _, test := range tests {
+		alterer := test.dialect.(SchemaAlterer)
+		var stmts []string
+		for _, col := range d.MissingColumns {
+			stmts = append(stmts, alterer.AddColumnSql("", "person", col))
+		}
+		for _, col := range d.ChangedColumns {
+			stmts = append(stmts, alterer.AlterColumnSql("", "person", col)...)
+		}
+		for _, col := range d.ExtraColumns {
+			stmts = append(stmts, alterer.DropColumnSql("", "person", col.Name))
+		}
+		if !reflect.DeepEqual(stmts, test.stmts) {
+			t.Errorf("%T: got %q, want %q", test.dialect, stmts, test.stmts)
+		}
+	}
+}
+
+func TestDialect_AddColumnSql(t *testing.T) {
+	dbUtils := &DbUtils{Dialect: PostgresDialect{}}
+	table := dbUtils.AddTableWithName(SchemaPerson{}, "person")
+	name := table.ColMap("Name")
+
+	// the rows of the table have no name yet
+	want := `alter table "person" add column "name" varchar(50);`
+	if stmt := (PostgresDialect{}).AddColumnSql("", "person", name); stmt != want {
+		t.Errorf("got %s, want %s", stmt, want)
+	}
+	name.DefaultValue = "''"
+	want = `alter table "person" add column "name" varchar(50) not null default '';`
+	if stmt := (PostgresDialect{}).AddColumnSql("", "person", name); stmt != want {
+		t.Errorf("got %s, want %s", stmt, want)
+	}
+}
+
+func Test_DiffSchema(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	dbmap.Exec("drop table if exists schema_person_test")
+	dbmap.Exec("create table schema_person_test (id bigint not null auto_increment primary key, name varchar(50), age bigint, nickname text, key person_age (age))")
+	defer dbmap.Exec("drop table if exists schema_person_test")
+
+	s, err := dbmap.InspectSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := s.Table("schema_person_test")
+	if actual == nil {
+		t.Fatalf("table not found in %s", s.Name)
+	}
+	if len(actual.Columns) != 4 || actual.Column("name").Type != "varchar(50)" || !actual.Column("name").Nullable {
+		t.Errorf("unexpected columns %+v", actual.Columns)
+	}
+	if !reflect.DeepEqual(actual.PrimaryKey, []string{"id"}) {
+		t.Errorf("unexpected primary key %v", actual.PrimaryKey)
+	}
+	if idx := actual.Index("person_age"); idx == nil || idx.Unique || !reflect.DeepEqual(idx.Columns, []string{"age"}) {
+		t.Errorf("unexpected indexes %+v", actual.Indexes)
+	}
+
+	dbmap.AddTableWithName(SchemaPerson{}, "schema_person_test")
+	diff, err := dbmap.DiffSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Tables) != 1 || len(diff.Statements) != 3 {
+		t.Fatalf("unexpected diff %+v: %q", diff, diff.Statements)
+	}
+	for _, stmt := range diff.Statements {
+		if _, err := dbmap.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	diff, err = dbmap.DiffSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Tables) != 1 || len(diff.Tables[0].ExtraColumns) != 1 || len(diff.Statements) != 0 {
+		t.Fatalf("expected the extra column reported without statement, got %+v: %q", diff, diff.Statements)
+	}
+	diff, err = dbmap.DiffSchemaWithOptions(SchemaDiffOptions{DropColumns: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Statements) != 1 {
+		t.Fatalf("expected the extra column dropped, got %q", diff.Statements)
+	}
+	for _, stmt := range diff.Statements {
+		if _, err := dbmap.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	diff, err = dbmap.DiffSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Tables) != 0 || len(diff.Statements) != 0 {
+		t.Errorf("table differs after applying the statements: %+v", diff.Tables[0])
+	}
+}
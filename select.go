@@ -71,9 +71,6 @@ func selectVal(queryRunner SqlQueryRunner, holder interface{}, query string, arg
 		}
 	}
 
-	fmt.Println(len(args))
-	fmt.Println(query)
-
 	rows, err := queryRunner.Query(query, args...)
 	if err != nil {
 		return err
@@ -94,7 +91,6 @@ func SelectOne(dbUtils *DbUtils, queryRunner SqlQueryRunner, holder interface{},
 	t:=reflect.TypeOf(holder)
 	if t.Kind() ==reflect.Ptr{
 		t = t.Elem()
-		fmt.Println(t)
 	}else {
 		return fmt.Errorf("godb: SelectOne holder must be a pointer, but got: %t", holder)
 	}
@@ -103,12 +99,8 @@ func SelectOne(dbUtils *DbUtils, queryRunner SqlQueryRunner, holder interface{},
 	if t.Kind() == reflect.Ptr {
 		isptr = true
 		t = t.Elem()
-	}else{
-		fmt.Println(t.Kind())
 	}
 
-	fmt.Println(isptr)
-
 	if t.Kind() == reflect.Struct {
 		var nonFatalErr error
 		list, err := rawselect(dbUtils, queryRunner, holder, query, args...)
@@ -126,8 +118,6 @@ func SelectOne(dbUtils *DbUtils, queryRunner SqlQueryRunner, holder interface{},
 		}
 
 		if list != nil && len(list) > 0 {
-
-			fmt.Println(len(list))
 			if len(list) > 1 {
 				return fmt.Errorf("godb: multiple rows returned for: %s - %v", query, args)
 			}
@@ -145,7 +135,7 @@ func SelectOne(dbUtils *DbUtils, queryRunner SqlQueryRunner, holder interface{},
 		return nonFatalErr
 	}
 
-	return selectVal(dbUtils, holder, query, args...)
+	return selectVal(queryRunner, holder, query, args...)
 }
 
 
@@ -175,96 +165,29 @@ func rawselect(dbUtils *DbUtils, queryRunner SqlQueryRunner, i interface{}, quer
 		intoStruct = t.Kind() == reflect.Struct
 	}
 
-	fmt.Println("-----------------")
-
 	if len(args) == 1 {
-		fmt.Println("args=1")
 		query, args = maybeExpandNamedQuery(dbUtils, query, args)
 	}
 
-	fmt.Println(query)
 	rows, err := queryRunner.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	fmt.Println("-----------------")
-
-	cols, err := rows.Columns()
-	if err != nil {
-		return nil, err
-	}
-
-	if !intoStruct && len(cols) > 1 {
-		return nil, fmt.Errorf("godb: select into non-struct slice requires 1 column, got %d", len(cols))
-	}
-
-	var nonFatalErr error
-
-	var colToFieldIndex [][]int
-
-	if intoStruct {
-		colToFieldIndex, err = columnToFieldIndex(dbUtils, t, "",cols)
-		if err != nil {
-			if !NonFatalError(err) {
-				return nil, err
-			}
-			nonFatalErr = err
-		}
+	scanner, nonFatalErr := newRowScanner(dbUtils, rows, t, intoStruct)
+	if nonFatalErr != nil && !NonFatalError(nonFatalErr) {
+		return nil, nonFatalErr
 	}
 
-	conv := dbUtils.TypeConverter
-
-	fmt.Println("-----------------")
-	//list       = make([]interface{}, 0)
 	var (
 		list       = make([]interface{}, 0)
 		sliceValue = reflect.Indirect(reflect.ValueOf(i))
 	)
 
-
-	for{
-		if !rows.Next() {
-			// if error occured return rawselect
-			if rows.Err() != nil {
-				return nil, rows.Err()
-			}
-			// time to exit from outer "for" loop
-			break
-
-		}
+	for rows.Next() {
 		v := reflect.New(t)
-
-		dest := make([]interface{}, len(cols))
-
-		custScan := make([]CustomScanner, 0)
-		for x := range cols {
-			f := v.Elem()
-			if intoStruct {
-				index := colToFieldIndex[x]
-				if index == nil {
-					var dummy dummyField
-					dest[x] = &dummy
-					continue
-				}
-				f = f.FieldByIndex(index)
-			}
-			target := f.Addr().Interface()
-			if conv != nil {
-				scanner, ok := conv.FromDb(target)
-				if ok {
-					target = scanner.Holder
-					custScan = append(custScan, scanner)
-				}
-			}
-			dest[x] = target
-		}
-
-		fmt.Println(dest)
-
-		err = rows.Scan(dest...)
-		if err != nil {
+		if err := scanner.scan(rows, v.Elem()); err != nil {
 			return nil, err
 		}
 		if appendToSlice {
@@ -276,13 +199,13 @@ func rawselect(dbUtils *DbUtils, queryRunner SqlQueryRunner, i interface{}, quer
 			list = append(list, v.Interface())
 		}
 	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
 	if appendToSlice && sliceValue.IsNil() {
 		sliceValue.Set(reflect.MakeSlice(sliceValue.Type(), 0, 0))
 	}
 
-	fmt.Println("-----------------")
-	//fmt.Println(nonFatalErr)
-	fmt.Println(list)
 	return list, nonFatalErr
 }
 
@@ -291,6 +214,7 @@ func selectlist(dbUtils *DbUtils, queryRunner SqlQueryRunner, i interface{}, que
 
 	var nonFatalErr error
 
+	args, preloads := extractPreloads(args)
 	list, err := rawselect(dbUtils, queryRunner, i, query, args...)
 	if err != nil {
 		if !NonFatalError(err) {
@@ -299,6 +223,182 @@ func selectlist(dbUtils *DbUtils, queryRunner SqlQueryRunner, i interface{}, que
 		nonFatalErr = err
 	}
 
+	if len(preloads) > 0 {
+		if err := preloadSelected(dbUtils, queryRunner, i, list, preloads); err != nil {
+			return nil, err
+		}
+	}
 
 	return list, nonFatalErr
-}
\ No newline at end of file
+}
+// preloadSelected loads the relations of the structs selected into i, a
+// pointer to a slice, or returned in list.
+func preloadSelected(dbUtils *DbUtils, queryRunner SqlQueryRunner, i interface{}, list []interface{}, paths []string) error {
+	var rows []reflect.Value
+	if t, _ := toSliceType(i); t != nil {
+		slice := reflect.ValueOf(i).Elem()
+		for j := 0; j < slice.Len(); j++ {
+			rows = append(rows, reflect.Indirect(slice.Index(j)))
+		}
+	} else {
+		for _, v := range list {
+			rows = append(rows, reflect.ValueOf(v).Elem())
+		}
+	}
+	if len(rows) == 0 || rows[0].Kind() != reflect.Struct {
+		return nil
+	}
+	return preload(dbUtils, queryRunner, rows[0].Type(), rows, paths)
+}
+
+// rowScanner scans rows into values of a type, matching the columns with
+// the fields of a struct or, for other types, scanning the single column.
+// Pointers to nested structs are allocated for each row, and left nil when
+// all of their columns are NULL.
+type rowScanner struct {
+	intoStruct      bool
+	colToFieldIndex [][]int
+	conv            TypeConverter
+
+	// ptrPaths are the indexes of the pointer fields crossed to reach the
+	// fields, parents first, and colPtrs the ones crossed by each column.
+	ptrPaths [][]int
+	colPtrs  [][]int
+}
+
+// newRowScanner maps the columns of rows to the fields of t. The returned
+// error may be a NonFatalError listing the columns without a field, in
+// which case the scanner is usable.
+func newRowScanner(dbUtils *DbUtils, rows *sql.Rows, t reflect.Type, intoStruct bool) (*rowScanner, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	if !intoStruct && len(cols) > 1 {
+		return nil, fmt.Errorf("godb: select into non-struct slice requires 1 column, got %d", len(cols))
+	}
+
+	s := &rowScanner{intoStruct: intoStruct, conv: dbUtils.TypeConverter}
+	if !intoStruct {
+		return s, nil
+	}
+
+	var nonFatalErr error
+	s.colToFieldIndex, err = columnToFieldIndex(dbUtils, t, "", cols)
+	if err != nil {
+		if !NonFatalError(err) {
+			return nil, err
+		}
+		nonFatalErr = err
+	}
+	s.findPointers(t)
+	return s, nonFatalErr
+}
+
+// findPointers fills ptrPaths and colPtrs.
+func (s *rowScanner) findPointers(t reflect.Type) {
+	seen := make(map[string]int)
+	s.colPtrs = make([][]int, len(s.colToFieldIndex))
+	for x, index := range s.colToFieldIndex {
+		ft := t
+		for i := 0; i < len(index)-1; i++ {
+			ft = ft.Field(index[i]).Type
+			if ft.Kind() != reflect.Ptr {
+				continue
+			}
+			ft = ft.Elem()
+
+			key := fmt.Sprint(index[:i+1])
+			p, ok := seen[key]
+			if !ok {
+				p = len(s.ptrPaths)
+				seen[key] = p
+				s.ptrPaths = append(s.ptrPaths, index[:i+1])
+			}
+			s.colPtrs[x] = append(s.colPtrs[x], p)
+		}
+	}
+}
+
+// scan scans the current row into v, which must be addressable, and binds
+// the values read through the TypeConverter.
+func (s *rowScanner) scan(rows *sql.Rows, v reflect.Value) error {
+	var (
+		dest     []interface{}
+		custScan []CustomScanner
+		nullable []nullableField
+		notNull  = make([]bool, len(s.ptrPaths))
+	)
+	add := func(f reflect.Value, ptrs []int) {
+		if s.conv != nil {
+			scanner, ok := s.conv.FromDb(f.Addr().Interface())
+			if ok {
+				// The holder tells nothing of NULL values: the structs
+				// holding f are kept.
+				for _, p := range ptrs {
+					notNull[p] = true
+				}
+				dest = append(dest, scanner.Holder)
+				custScan = append(custScan, scanner)
+				return
+			}
+		}
+		if len(ptrs) > 0 {
+			holder := reflect.New(reflect.PtrTo(f.Type()))
+			nullable = append(nullable, nullableField{f, holder, ptrs})
+			dest = append(dest, holder.Interface())
+			return
+		}
+		dest = append(dest, f.Addr().Interface())
+	}
+
+	if s.intoStruct {
+		for _, p := range s.ptrPaths {
+			f := v.FieldByIndex(p)
+			f.Set(reflect.New(f.Type().Elem()))
+		}
+		for x, index := range s.colToFieldIndex {
+			if index == nil {
+				dest = append(dest, &dummyField{})
+				continue
+			}
+			add(v.FieldByIndex(index), s.colPtrs[x])
+		}
+	} else {
+		add(v, nil)
+	}
+
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	for _, c := range custScan {
+		if err := c.Bind(); err != nil {
+			return err
+		}
+	}
+
+	for _, n := range nullable {
+		if value := n.holder.Elem(); !value.IsNil() {
+			n.field.Set(value.Elem())
+			for _, p := range n.ptrs {
+				notNull[p] = true
+			}
+		}
+	}
+	for i := len(s.ptrPaths) - 1; i >= 0; i-- {
+		if !notNull[i] {
+			f := v.FieldByIndex(s.ptrPaths[i])
+			f.Set(reflect.Zero(f.Type()))
+		}
+	}
+	return nil
+}
+
+// nullableField is a field reached through pointers, scanned into a
+// pointer holder to find out whether the column is NULL.
+type nullableField struct {
+	field  reflect.Value
+	holder reflect.Value
+	ptrs   []int
+}
@@ -0,0 +1,715 @@
+package godb
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ShardFunc returns the index, between 0 and shards-1, of the shard holding
+// the rows with the given shard key.
+type ShardFunc func(key interface{}, shards int) int
+
+// HashShard spreads the keys over the shards by their FNV-1a hash.
+func HashShard(key interface{}, shards int) int {
+	k, err := driver.DefaultParameterConverter.ConvertValue(key)
+	if err != nil {
+		k = key
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%T:%v", k, k)
+	return int(h.Sum32() % uint32(shards))
+}
+
+// RangeShard returns a ShardFunc placing in shard i the keys lower than
+// bounds[i] and not lower than the previous bounds, and in the last shard
+// the keys not lower than any bound. Bounds are numbers, strings or times
+// in ascending order. Keys that cannot be compared with the bounds get the
+// shard -1, which is out of range.
+func RangeShard(bounds ...interface{}) ShardFunc {
+	return func(key interface{}, shards int) int {
+		for i, bound := range bounds {
+			c, ok := compareValues(key, bound)
+			if !ok {
+				return -1
+			}
+			if c < 0 {
+				return i
+			}
+		}
+		return len(bounds)
+	}
+}
+
+// MergeOrderer is implemented by dialects able to sort rows in the order in
+// which ShardedSelectBuilder merges the rows of the shards: NULL values
+// before the others in ascending order, and strings by their bytes, as with
+// a binary collation. The statements of OrderBy and OrderByDesc then sort
+// each shard in that order, so that the merged rows, Limit and Offset agree
+// with it. With other dialects the ordered columns must sort that way by
+// default, strings with a binary collation.
+type MergeOrderer interface {
+	// MergeOrderSql returns the order by term sorting the quoted column
+	// in the order of the merge, text telling whether it holds strings.
+	MergeOrderSql(column string, text, desc bool) string
+}
+
+// mergeOrderSuffix returns the direction of an order by term, along with
+// the clause putting NULL values first in ascending order for dialects
+// sorting them last.
+func mergeOrderSuffix(desc, nullsLast bool) string {
+	switch {
+	case desc && nullsLast:
+		return " desc nulls last"
+	case desc:
+		return " desc"
+	case nullsLast:
+		return " asc nulls first"
+	}
+	return " asc"
+}
+
+// ShardedDb spreads the rows of its tables over several databases, choosing
+// the shard of a row by the value of its shard key. Insert, Get, Update and
+// Delete run on the shard of their rows, while Select and the statements
+// of From without a condition on the shard key run on every shard.
+//
+// Keys generated by autoincrement columns are only unique within a shard.
+type ShardedDb struct {
+	Shards []*DbUtils
+	tables []*ShardedTable
+}
+
+// NewShardedDb returns a ShardedDb over the given shards, whose order must
+// not change as it determines where the rows are.
+func NewShardedDb(shards ...*DbUtils) *ShardedDb {
+	return &ShardedDb{Shards: shards}
+}
+
+// ShardedTable is a table registered on every shard of a ShardedDb.
+type ShardedTable struct {
+	// Tables holds the TableMap of each shard, in the order of the shards.
+	Tables []*TableMap
+
+	gotype    reflect.Type
+	keyField  string
+	shardFunc ShardFunc
+}
+
+func (s *ShardedDb) AddTable(i interface{}) *ShardedTable {
+	return s.AddTableWithName(i, "")
+}
+
+// AddTableWithName registers the table on every shard. Its shard key must
+// then be set with SetShardKey.
+func (s *ShardedDb) AddTableWithName(i interface{}, name string) *ShardedTable {
+	t := &ShardedTable{gotype: reflect.TypeOf(i)}
+	for _, shard := range s.Shards {
+		t.Tables = append(t.Tables, shard.AddTableWithName(i, name))
+	}
+	for x, table := range s.tables {
+		if table.gotype == t.gotype {
+			t.keyField, t.shardFunc = table.keyField, table.shardFunc
+			s.tables[x] = t
+			return t
+		}
+	}
+	s.tables = append(s.tables, t)
+	return t
+}
+
+// SetShardKey sets the field whose value chooses the shard of a row, and
+// the function mapping it to a shard, HashShard if nil.
+func (t *ShardedTable) SetShardKey(field string, fn ShardFunc) *ShardedTable {
+	for _, table := range t.Tables {
+		field = table.ColMap(field).fieldName
+	}
+	if fn == nil {
+		fn = HashShard
+	}
+	t.keyField, t.shardFunc = field, fn
+	return t
+}
+
+// isShardKey reports whether field names the shard key, by field or column
+// name.
+func (t *ShardedTable) isShardKey(field string) bool {
+	if t.keyField == "" || len(t.Tables) == 0 {
+		return false
+	}
+	col := colMapOrNil(t.Tables[0], field)
+	return col != nil && col.fieldName == t.keyField
+}
+
+// SetKeys calls SetKeys on the table of every shard.
+func (t *ShardedTable) SetKeys(isAutoIncr bool, fieldNames ...string) *ShardedTable {
+	for _, table := range t.Tables {
+		table.SetKeys(isAutoIncr, fieldNames...)
+	}
+	return t
+}
+
+func (s *ShardedDb) tableFor(t reflect.Type) (*ShardedTable, error) {
+	for _, table := range s.tables {
+		if table.gotype == t {
+			if table.shardFunc == nil {
+				return nil, fmt.Errorf("godb: no shard key set for table %s", table.Tables[0].TableName)
+			}
+			return table, nil
+		}
+	}
+	return nil, fmt.Errorf("godb: no sharded table found for type: %v", t.Name())
+}
+
+// shardFor returns the index of the shard holding the rows with key.
+func (s *ShardedDb) shardFor(t *ShardedTable, key interface{}) (int, error) {
+	n := t.shardFunc(key, len(s.Shards))
+	if n < 0 || n >= len(s.Shards) {
+		return -1, fmt.Errorf("godb: shard %d of key %v out of range for table %s", n, key, t.Tables[0].TableName)
+	}
+	return n, nil
+}
+
+// groupByShard returns the elements of list, struct pointers, grouped by the
+// index of their shard.
+func (s *ShardedDb) groupByShard(list []interface{}) (map[int][]interface{}, error) {
+	groups := make(map[int][]interface{})
+	for _, ptr := range list {
+		v := reflect.ValueOf(ptr)
+		if v.Kind() != reflect.Ptr {
+			return nil, fmt.Errorf("godb: passed non-pointer: %v (kind=%v)", ptr, v.Kind())
+		}
+		t, err := s.tableFor(v.Elem().Type())
+		if err != nil {
+			return nil, err
+		}
+		n, err := s.shardFor(t, fieldByName(v.Elem(), t.keyField).Interface())
+		if err != nil {
+			return nil, err
+		}
+		groups[n] = append(groups[n], ptr)
+	}
+	return groups, nil
+}
+
+// Insert inserts each element of list in its shard.
+func (s *ShardedDb) Insert(list ...interface{}) error {
+	groups, err := s.groupByShard(list)
+	if err != nil {
+		return err
+	}
+	for n, shard := range s.Shards {
+		if len(groups[n]) > 0 {
+			if err := shard.Insert(groups[n]...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Update updates each element of list in its shard. Shard keys cannot
+// change, as the row would be looked up in its new shard: an element whose
+// row is not in its shard is an error.
+func (s *ShardedDb) Update(list ...interface{}) (int64, error) {
+	groups, err := s.groupByShard(list)
+	if err != nil {
+		return -1, err
+	}
+	count := int64(0)
+	for n, shard := range s.Shards {
+		for _, ptr := range groups[n] {
+			rows, err := shard.Update(ptr)
+			if err != nil {
+				return -1, err
+			}
+			// versioned tables already report a missing row
+			if rows == 0 {
+				if err := checkInShard(shard, n, ptr); err != nil {
+					return -1, err
+				}
+			}
+			count += rows
+		}
+	}
+	return count, nil
+}
+
+// checkInShard returns an error when the row of ptr is not in shard n.
+func checkInShard(shard *DbUtils, n int, ptr interface{}) error {
+	table, elem, err := shard.tableForPointer(ptr, true)
+	if err != nil {
+		return err
+	}
+	keys := make([]interface{}, len(table.keys))
+	for x, col := range table.keys {
+		keys[x] = elem.FieldByIndex(col.fieldIndex).Interface()
+		if shard.TypeConverter != nil {
+			keys[x], err = shard.TypeConverter.ToDb(keys[x])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	count, err := shard.SelectInt(table.bindExists().query, keys...)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("godb: no row of %s with keys %v in shard %d, shard keys cannot change",
+			table.TableName, keys, n)
+	}
+	return nil
+}
+
+// Delete deletes each element of list from its shard.
+func (s *ShardedDb) Delete(list ...interface{}) (int64, error) {
+	return s.eachGroup(list, (*DbUtils).Delete)
+}
+
+func (s *ShardedDb) eachGroup(list []interface{}, f func(*DbUtils, ...interface{}) (int64, error)) (int64, error) {
+	groups, err := s.groupByShard(list)
+	if err != nil {
+		return -1, err
+	}
+	count := int64(0)
+	for n, shard := range s.Shards {
+		if len(groups[n]) > 0 {
+			rows, err := f(shard, groups[n]...)
+			if err != nil {
+				return -1, err
+			}
+			count += rows
+		}
+	}
+	return count, nil
+}
+
+// Get runs Get on the shard of the row when the shard key is one of the
+// primary keys, and on every shard until the row is found otherwise.
+func (s *ShardedDb) Get(i interface{}, keys ...interface{}) (interface{}, error) {
+	t, err := toType(i)
+	if err != nil {
+		return nil, err
+	}
+	table, err := s.tableFor(t)
+	if err != nil {
+		return nil, err
+	}
+
+	for x, col := range table.Tables[0].keys {
+		if col.fieldName == table.keyField && x < len(keys) {
+			n, err := s.shardFor(table, keys[x])
+			if err != nil {
+				return nil, err
+			}
+			return s.Shards[n].Get(i, keys...)
+		}
+	}
+	for _, shard := range s.Shards {
+		obj, err := shard.Get(i, keys...)
+		if err != nil || obj != nil {
+			return obj, err
+		}
+	}
+	return nil, nil
+}
+
+// Select runs query on every shard and returns the rows of the shards in
+// turn, or appends them to i if it is a pointer to a slice. Use From to
+// sort and limit the rows of all the shards.
+func (s *ShardedDb) Select(i interface{}, query string, args ...interface{}) ([]interface{}, error) {
+	var (
+		lists  = make([][]interface{}, len(s.Shards))
+		slices = make([]reflect.Value, len(s.Shards))
+		errs   = make([]error, len(s.Shards))
+	)
+	sliceType, _ := toSliceType(i)
+	s.scatter(s.allShards(), func(_, n int) error {
+		holder := i
+		if sliceType != nil {
+			slices[n] = reflect.New(reflect.TypeOf(i).Elem())
+			holder = slices[n].Interface()
+		}
+		lists[n], errs[n] = s.Shards[n].Select(holder, query, args...)
+		return nil
+	})
+
+	var (
+		list        []interface{}
+		nonFatalErr error
+	)
+	for n := range s.Shards {
+		if err := errs[n]; err != nil {
+			if !NonFatalError(err) {
+				return nil, err
+			}
+			nonFatalErr = err
+		}
+		list = append(list, lists[n]...)
+		if sliceType != nil {
+			dest := reflect.ValueOf(i).Elem()
+			dest.Set(reflect.AppendSlice(dest, slices[n].Elem()))
+		}
+	}
+	return list, nonFatalErr
+}
+
+func (s *ShardedDb) allShards() []int {
+	shards := make([]int, len(s.Shards))
+	for n := range shards {
+		shards[n] = n
+	}
+	return shards
+}
+
+// scatter runs f concurrently for the given shards, passing the position x
+// of the shard in shards and its index n, and returns the first error in
+// the order of shards.
+func (s *ShardedDb) scatter(shards []int, f func(x, n int) error) error {
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for x, n := range shards {
+		wg.Add(1)
+		go func(x, n int) {
+			defer wg.Done()
+			errs[x] = f(x, n)
+		}(x, n)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShardedSelectBuilder builds a select statement run on the shards of a
+// ShardedDb, like a SelectBuilder. The rows of the shards are merged in
+// the order given by OrderBy and OrderByDesc, and Limit and Offset apply
+// to the merged rows. A WhereEq condition on the shard key restricts the
+// statement to the shard of the key.
+type ShardedSelectBuilder struct {
+	db       *ShardedDb
+	table    *ShardedTable
+	i        interface{}
+	ops      []func(b *SelectBuilder)
+	orderBys []shardOrder
+	shard    int
+	limit    int
+	offset   int
+	err      error
+}
+
+type shardOrder struct {
+	field string
+	desc  bool
+}
+
+// From starts a select statement on the table mapped to i.
+func (s *ShardedDb) From(i interface{}) *ShardedSelectBuilder {
+	b := &ShardedSelectBuilder{db: s, i: i, shard: -1, limit: -1}
+	t, err := toType(i)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.table, b.err = s.tableFor(t)
+	return b
+}
+
+// Where adds a condition to the statement, as SelectBuilder.Where.
+func (b *ShardedSelectBuilder) Where(cond string, args ...interface{}) *ShardedSelectBuilder {
+	b.ops = append(b.ops, func(sb *SelectBuilder) { sb.Where(cond, args...) })
+	return b
+}
+
+// WhereEq adds a "field = value" condition to the statement. A condition on
+// the shard key runs the statement on the shard of value only.
+func (b *ShardedSelectBuilder) WhereEq(field string, value interface{}) *ShardedSelectBuilder {
+	if b.table != nil && b.table.isShardKey(field) && b.err == nil {
+		n, err := b.db.shardFor(b.table, value)
+		if err != nil {
+			b.err = err
+		} else if b.shard >= 0 && b.shard != n {
+			b.err = errors.New("godb: conditions on the shard key of different shards")
+		}
+		b.shard = n
+	}
+	b.ops = append(b.ops, func(sb *SelectBuilder) { sb.WhereEq(field, value) })
+	return b
+}
+
+// WhereIn adds a "field in (values...)" condition to the statement.
+func (b *ShardedSelectBuilder) WhereIn(field string, values ...interface{}) *ShardedSelectBuilder {
+	b.ops = append(b.ops, func(sb *SelectBuilder) { sb.WhereIn(field, values...) })
+	return b
+}
+
+// OrderBy sorts the result by field in ascending order, NULL values first
+// and strings by their bytes, as described by MergeOrderer.
+func (b *ShardedSelectBuilder) OrderBy(field string) *ShardedSelectBuilder {
+	b.orderBys = append(b.orderBys, shardOrder{field: field})
+	b.ops = append(b.ops, func(sb *SelectBuilder) { sb.orderByMerged(field, false) })
+	return b
+}
+
+// OrderByDesc sorts the result by field in descending order, NULL values
+// last and strings by their bytes, as described by MergeOrderer.
+func (b *ShardedSelectBuilder) OrderByDesc(field string) *ShardedSelectBuilder {
+	b.orderBys = append(b.orderBys, shardOrder{field: field, desc: true})
+	b.ops = append(b.ops, func(sb *SelectBuilder) { sb.orderByMerged(field, true) })
+	return b
+}
+
+// orderByMerged sorts the result by field in the order of the merge of
+// ShardedSelectBuilder.
+func (b *SelectBuilder) orderByMerged(field string, desc bool) *SelectBuilder {
+	var (
+		col     *ColumnMap
+		orderer MergeOrderer
+	)
+	if b.table != nil {
+		col = colMapOrNil(b.table, field)
+		orderer, _ = b.table.dbUtils.Dialect.(MergeOrderer)
+	}
+	if col == nil || orderer == nil {
+		if desc {
+			return b.OrderByDesc(field)
+		}
+		return b.OrderBy(field)
+	}
+	t := indirectType(col.gotype)
+	text := t.Kind() == reflect.String || t == reflect.TypeOf(sql.NullString{})
+	column := b.table.dbUtils.Dialect.QuoteField(col.ColumnName)
+	b.orderBys = append(b.orderBys, orderer.MergeOrderSql(column, text, desc))
+	return b
+}
+
+// Limit restricts the merged result to at most n rows.
+func (b *ShardedSelectBuilder) Limit(n int) *ShardedSelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset skips the first n rows of the merged result.
+func (b *ShardedSelectBuilder) Offset(n int) *ShardedSelectBuilder {
+	b.offset = n
+	return b
+}
+
+func (b *ShardedSelectBuilder) shards() []int {
+	if b.shard >= 0 {
+		return []int{b.shard}
+	}
+	return b.db.allShards()
+}
+
+// builder returns the statement for shard n. Each shard returns the rows
+// up to the limit of the merged result.
+func (b *ShardedSelectBuilder) builder(n int) *SelectBuilder {
+	sb := b.db.Shards[n].From(b.i)
+	for _, op := range b.ops {
+		op(sb)
+	}
+	if b.limit >= 0 {
+		sb.Limit(b.offset + b.limit)
+	}
+	return sb
+}
+
+// Select runs the statement and returns one pointer to a new struct of the
+// table type per row. As with ShardedDb.Select, a NonFatalError of a shard
+// is returned along with the rows.
+func (b *ShardedSelectBuilder) Select() ([]interface{}, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	shards := b.shards()
+	lists := make([][]interface{}, len(shards))
+	nonFatalErrs := make([]error, len(shards))
+	err := b.db.scatter(shards, func(x, n int) error {
+		list, err := b.builder(n).Select()
+		lists[x] = list
+		if err != nil {
+			if !NonFatalError(err) {
+				return err
+			}
+			nonFatalErrs[x] = err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var nonFatalErr error
+	for _, e := range nonFatalErrs {
+		if e != nil {
+			nonFatalErr = e
+		}
+	}
+
+	list := b.merge(lists)
+	if b.offset >= len(list) {
+		return []interface{}{}, nonFatalErr
+	}
+	list = list[b.offset:]
+	if b.limit >= 0 && b.limit < len(list) {
+		list = list[:b.limit]
+	}
+	return list, nonFatalErr
+}
+
+// merge merges the sorted lists of rows of the shards.
+func (b *ShardedSelectBuilder) merge(lists [][]interface{}) []interface{} {
+	merged := make([]interface{}, 0)
+	heads := make([]int, len(lists))
+	for {
+		best := -1
+		for x, list := range lists {
+			if heads[x] < len(list) && (best < 0 || b.less(list[heads[x]], lists[best][heads[best]])) {
+				best = x
+			}
+		}
+		if best < 0 {
+			return merged
+		}
+		merged = append(merged, lists[best][heads[best]])
+		heads[best]++
+	}
+}
+
+// less reports whether the row a comes before b in the order of the
+// statement.
+func (b *ShardedSelectBuilder) less(x, y interface{}) bool {
+	vx, vy := reflect.ValueOf(x).Elem(), reflect.ValueOf(y).Elem()
+	for _, order := range b.orderBys {
+		col := colMapOrNil(b.table.Tables[0], order.field)
+		if col == nil {
+			continue
+		}
+		c, _ := compareValues(vx.FieldByIndex(col.fieldIndex).Interface(), vy.FieldByIndex(col.fieldIndex).Interface())
+		if order.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
+
+// SelectInto runs the statement and appends the rows to holder, which must
+// be a pointer to a slice of structs or struct pointers.
+func (b *ShardedSelectBuilder) SelectInto(holder interface{}) error {
+	elemType, err := toSliceType(holder)
+	if err != nil {
+		return err
+	}
+	if elemType == nil {
+		return fmt.Errorf("godb: SelectInto holder must be a pointer to a slice, got %T", holder)
+	}
+	list, err := b.Select()
+	if err != nil && !NonFatalError(err) {
+		return err
+	}
+	dest := reflect.ValueOf(holder).Elem()
+	for _, row := range list {
+		v := reflect.ValueOf(row)
+		if elemType.Kind() != reflect.Ptr {
+			v = v.Elem()
+		}
+		dest.Set(reflect.Append(dest, v))
+	}
+	return err
+}
+
+// Count returns the number of rows matched by the conditions of the
+// statement on all the shards.
+func (b *ShardedSelectBuilder) Count() (int64, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	shards := b.shards()
+	counts := make([]int64, len(shards))
+	err := b.db.scatter(shards, func(x, n int) error {
+		var err error
+		counts[x], err = b.builder(n).Count()
+		return err
+	})
+	total := int64(0)
+	for _, count := range counts {
+		total += count
+	}
+	return total, err
+}
+
+// compareValues compares x and y, converted to driver values so that an int
+// compares with an int64. NULL values come first. It returns false if the
+// values cannot be compared.
+func compareValues(x, y interface{}) (int, bool) {
+	a, errA := driver.DefaultParameterConverter.ConvertValue(x)
+	b, errB := driver.DefaultParameterConverter.ConvertValue(y)
+	switch {
+	case errA != nil || errB != nil:
+		return 0, false
+	case a == nil && b == nil:
+		return 0, true
+	case a == nil:
+		return -1, true
+	case b == nil:
+		return 1, true
+	}
+
+	switch a := a.(type) {
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return compareOrdered(a < b, a > b), true
+		case float64:
+			return compareOrdered(float64(a) < b, float64(a) > b), true
+		}
+	case float64:
+		switch b := b.(type) {
+		case float64:
+			return compareOrdered(a < b, a > b), true
+		case int64:
+			return compareOrdered(a < float64(b), a > float64(b)), true
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return compareOrdered(a < b, a > b), true
+		}
+	case []byte:
+		if b, ok := b.([]byte); ok {
+			return bytes.Compare(a, b), true
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return compareOrdered(a.Before(b), a.After(b)), true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			return compareOrdered(!a && b, a && !b), true
+		}
+	}
+	return 0, false
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package godb
+
+import (
+	"strings"
+	"testing"
+)
+
+type ShardTenant struct {
+	Id       int64  `db:"id, primarykey"`
+	TenantId int64  `db:"tenant_id"`
+	Name     string `db:"name, size:50"`
+}
+
+func TestShardFuncs(t *testing.T) {
+	for _, key := range []interface{}{int64(1), 42, "tenant"} {
+		n := HashShard(key, 4)
+		if n < 0 || n >= 4 || HashShard(key, 4) != n {
+			t.Errorf("HashShard(%v) = %d", key, n)
+		}
+	}
+	if HashShard(7, 3) != HashShard(int64(7), 3) {
+		t.Error("expected int and int64 keys to hash alike")
+	}
+
+	byRange := RangeShard(100, 200)
+	for key, want := range map[interface{}]int{int64(5): 0, 100: 1, int8(-3): 0, 199.5: 1, uint(500): 2} {
+		if got := byRange(key, 3); got != want {
+			t.Errorf("RangeShard(%v) = %d, want %d", key, got, want)
+		}
+	}
+	if got := byRange("tenant", 3); got != -1 {
+		t.Errorf("expected no shard for a key not comparable with the bounds, got %d", got)
+	}
+}
+
+func TestDialect_MergeOrderSql(t *testing.T) {
+	tests := []struct {
+		dialect    MergeOrderer
+		text, desc bool
+		want       string
+	}{
+		{MySQLDialect{}, true, false, "cast(c as binary) asc"},
+		{MySQLDialect{}, false, true, "c desc"},
+		{PostgresDialect{}, true, false, `c collate "C" asc nulls first`},
+		{PostgresDialect{}, false, true, "c desc nulls last"},
+		{SqlServerDialect{}, true, true, "c collate Latin1_General_BIN2 desc"},
+		{OracleDialect{}, true, false, "nlssort(c, 'NLS_SORT=BINARY') asc nulls first"},
+		{OracleDialect{}, false, true, "c desc nulls last"},
+		{Db2Dialect{}, true, false, "c asc nulls first"},
+		{SqliteDialect{}, true, true, "c collate binary desc"},
+		{SqliteDialect{}, false, false, "c asc"},
+	}
+	for _, test := range tests {
+		if got := test.dialect.MergeOrderSql("c", test.text, test.desc); got != test.want {
+			t.Errorf("%T.MergeOrderSql(%v, %v) = %q, want %q", test.dialect, test.text, test.desc, got, test.want)
+		}
+	}
+}
+
+func Test_ShardedDb(t *testing.T) {
+	s := NewShardedDb(initSqliteDB(t, "s0"), initSqliteDB(t, "s1"))
+	s.AddTableWithName(ShardTenant{}, "shard_tenant_test").SetShardKey("TenantId", RangeShard(10))
+	for _, shard := range s.Shards {
+		if err := shard.CreateTables(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows := []interface{}{
+		&ShardTenant{1, 1, "a"}, &ShardTenant{2, 20, "b"}, &ShardTenant{3, 2, "c"},
+		&ShardTenant{4, 30, "d"}, &ShardTenant{5, 3, "e"},
+	}
+	if err := s.Insert(rows...); err != nil {
+		t.Fatal(err)
+	}
+	if n := selectInt(s.Shards[1], "select count(*) from shard_tenant_test"); n != 2 {
+		t.Errorf("expected 2 rows in the second shard, got %d", n)
+	}
+
+	obj, err := s.Get(ShardTenant{}, int64(4))
+	if err != nil || obj == nil || obj.(*ShardTenant).Name != "d" {
+		t.Errorf("unexpected Get: %v %v", obj, err)
+	}
+
+	b := rows[1].(*ShardTenant)
+	b.Name = "bb"
+	if count, err := s.Update(b); err != nil || count != 1 {
+		t.Errorf("update: %d %v", count, err)
+	}
+	moved := *rows[2].(*ShardTenant)
+	moved.TenantId = 40
+	if _, err := s.Update(&moved); err == nil {
+		t.Error("expected an error for an update changing the shard key")
+	}
+	if count, err := s.Delete(rows[0]); err != nil || count != 1 {
+		t.Errorf("delete: %d %v", count, err)
+	}
+
+	var all []ShardTenant
+	if _, err := s.Select(&all, "select * from shard_tenant_test"); err != nil || len(all) != 4 {
+		t.Errorf("expected 4 rows from all shards, got %d: %v", len(all), err)
+	}
+
+	query, _, err := s.From(ShardTenant{}).OrderByDesc("Name").OrderBy("Id").builder(0).ToSql()
+	if err != nil || !strings.Contains(query, `order by "name" collate binary desc,"id" asc`) {
+		t.Errorf("expected the shards sorted in the order of the merge, got %s %v", query, err)
+	}
+
+	var page []*ShardTenant
+	err = s.From(ShardTenant{}).OrderByDesc("Name").Offset(1).Limit(2).SelectInto(&page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].Name != "d" || page[1].Name != "c" {
+		t.Errorf("unexpected merged page %v %v", page[0], page[1])
+	}
+
+	list, err := s.From(ShardTenant{}).WhereEq("TenantId", 20).Select()
+	if err != nil || len(list) != 1 || list[0].(*ShardTenant).Name != "bb" {
+		t.Errorf("unexpected routed select %v %v", list, err)
+	}
+	if n, err := s.From(ShardTenant{}).Where("id > ?", 2).Count(); err != nil || n != 3 {
+		t.Errorf("expected 3 rows counted on all shards, got %d: %v", n, err)
+	}
+
+	byName := NewShardedDb(s.Shards...)
+	byName.AddTableWithName(ShardTenant{}, "shard_tenant_test").SetShardKey("TenantId", RangeShard("m"))
+	if err := byName.Insert(&ShardTenant{6, 6, "f"}); err == nil {
+		t.Error("expected an error for a key not comparable with the bounds")
+	}
+}
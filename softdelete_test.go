@@ -0,0 +1,107 @@
+package godb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type SoftDeleteInvoice struct {
+	Id        int64      `db:"id, primarykey, autoincrement"`
+	Memo      string     `db:"memo, size:50"`
+	Version   int64      `db:"version, version"`
+	DeletedAt *time.Time `db:"deleted_at, softdelete"`
+}
+
+func TestTableMap_SoftDeleteSql(t *testing.T) {
+	dbUtils := &DbUtils{Dialect: PostgresDialect{}}
+	table := dbUtils.AddTableWithName(SoftDeleteInvoice{}, "soft_invoice_test")
+	if table.softDelete == nil || table.softDelete.fieldName != "DeletedAt" {
+		t.Fatal("softdelete tag was not applied")
+	}
+
+	want := `select "id","memo","version","deleted_at" from "soft_invoice_test" where "id"=$1 and "deleted_at" is null;`
+	if query := table.bindGet(false).query; query != want {
+		t.Errorf("got %s, want %s", query, want)
+	}
+	want = `select "id","memo","version","deleted_at" from "soft_invoice_test" where "id"=$1;`
+	if query := table.bindGet(true).query; query != want {
+		t.Errorf("got %s, want %s", query, want)
+	}
+
+	want = `select count(*) from "soft_invoice_test" where "id"=$1;`
+	if query := table.bindExists().query; query != want {
+		t.Errorf("got %s, want %s", query, want)
+	}
+
+	bi, err := table.bindSoftDelete(reflect.ValueOf(&SoftDeleteInvoice{Id: 1, Version: 3}).Elem())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `update "soft_invoice_test" set "deleted_at"=$1 where "id"=$2 and "version"=$3 and "deleted_at" is null;`
+	if bi.query != want {
+		t.Errorf("got %s, want %s", bi.query, want)
+	}
+
+	query, _, err := dbUtils.From(SoftDeleteInvoice{}).WhereEq("Memo", "a").ToSql()
+	want = `select "id","memo","version","deleted_at" from "soft_invoice_test" where ("memo"=$1) and ("deleted_at" is null);`
+	if err != nil || query != want {
+		t.Errorf("got %s, want %s: %v", query, want, err)
+	}
+	query, _, err = dbUtils.Unscoped().From(SoftDeleteInvoice{}).ToSql()
+	want = `select "id","memo","version","deleted_at" from "soft_invoice_test";`
+	if err != nil || query != want {
+		t.Errorf("got %s, want %s: %v", query, want, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected SetSoftDelete to panic on a non time field")
+		}
+	}()
+	table.SetSoftDelete("Memo")
+}
+
+func Test_SoftDelete(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	dbmap.AddTableWithName(SoftDeleteInvoice{}, "soft_invoice_test")
+	dbmap.DropTablesIfExists()
+	if err := dbmap.CreateTables(); err != nil {
+		panic(err)
+	}
+	defer dbmap.DropTablesIfExists()
+
+	a, b := &SoftDeleteInvoice{Memo: "a"}, &SoftDeleteInvoice{Memo: "b"}
+	_insert(dbmap, a, b)
+
+	count, err := dbmap.Delete(a)
+	if err != nil || count != 1 || a.DeletedAt == nil {
+		t.Fatalf("soft delete: %d %v %v", count, a.DeletedAt, err)
+	}
+	if _, err = dbmap.Delete(a); err == nil {
+		t.Error("expected deleting a deleted row to fail")
+	} else if lockErr, ok := err.(*OptimisticLockError); !ok || !lockErr.RowExists {
+		t.Errorf("expected an OptimisticLockError for a deleted row that exists, got %v", err)
+	}
+	if n := selectInt(dbmap, "select count(*) from soft_invoice_test"); n != 2 {
+		t.Errorf("expected the row to be kept, got %d rows", n)
+	}
+
+	if obj, err := dbmap.Get(SoftDeleteInvoice{}, a.Id); err != nil || obj != nil {
+		t.Errorf("expected Get to skip the deleted row: %v %v", obj, err)
+	}
+	if obj, err := dbmap.Unscoped().Get(SoftDeleteInvoice{}, a.Id); err != nil || obj == nil {
+		t.Errorf("expected Unscoped Get to return the deleted row: %v", err)
+	}
+	if n, err := dbmap.From(SoftDeleteInvoice{}).Count(); err != nil || n != 1 {
+		t.Errorf("expected 1 row not deleted, got %d: %v", n, err)
+	}
+
+	if count, err = dbmap.HardDelete(a, b); err != nil || count != 2 {
+		t.Errorf("hard delete: %d %v", count, err)
+	}
+	if n := selectInt(dbmap, "select count(*) from soft_invoice_test"); n != 0 {
+		t.Errorf("expected no rows left, got %d", n)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"bytes"
 	"strings"
 	"sync"
+	"database/sql"
+	"time"
 )
 
 type TableMap struct {
@@ -15,8 +17,63 @@ type TableMap struct {
 	Columns        []*ColumnMap
 	keys           []*ColumnMap
 	indexes        []*IndexMap
-	uniqueTogether [][]string
+	uniqueTogether []uniqueConstraint
+	version        *ColumnMap
+	softDelete     *ColumnMap
+	relations      []*relation
 	dbUtils        *DbUtils
+	plans          [numPlanKinds]*bindPlan
+	plansMutex     sync.RWMutex
+}
+
+type uniqueConstraint struct {
+	name    string
+	columns []string
+}
+
+type planKind int
+
+const (
+	insertPlanKind planKind = iota
+	getPlanKind
+	unscopedGetPlanKind
+	updatePlanKind
+	deletePlanKind
+	softDeletePlanKind
+	existsPlanKind
+	numPlanKinds
+)
+
+// ResetSql removes the cached insert/update/get/delete SQL strings
+// associated with this TableMap. SetKeys, SetVersionCol and the ColumnMap
+// setters call it automatically and a changed TableName or SchemaName is
+// detected, so it is only needed after assigning other exported fields
+// of a ColumnMap, such as DefaultValue, directly.
+func (t *TableMap) ResetSql() {
+	t.plansMutex.Lock()
+	t.plans = [numPlanKinds]*bindPlan{}
+	t.plansMutex.Unlock()
+}
+
+// cachedPlan returns the plan of the given kind, creating an empty one if
+// there is none yet or if the table was renamed since it was created.
+// Callers fill in a new plan through its once field.
+func (t *TableMap) cachedPlan(kind planKind) *bindPlan {
+	t.plansMutex.RLock()
+	plan := t.plans[kind]
+	t.plansMutex.RUnlock()
+	if plan != nil && plan.tableName == t.TableName && plan.schemaName == t.SchemaName {
+		return plan
+	}
+
+	t.plansMutex.Lock()
+	defer t.plansMutex.Unlock()
+	plan = t.plans[kind]
+	if plan == nil || plan.tableName != t.TableName || plan.schemaName != t.SchemaName {
+		plan = &bindPlan{tableName: t.TableName, schemaName: t.SchemaName}
+		t.plans[kind] = plan
+	}
+	return plan
 }
 
 
@@ -35,10 +92,97 @@ func (t *TableMap) SetKeys(isAutoIncr bool, fieldNames ...string) *TableMap {
 		colmap.isAutoIncr = isAutoIncr
 		t.keys = append(t.keys, colmap)
 	}
+	t.ResetSql()
+
+	return t
+}
+
+// SetVersionCol sets the column used for optimistic locking. Insert and
+// Update store the version of the struct plus one in it, and Update and
+// Delete only affect the row if the version in the database still matches
+// the one of the struct, returning an OptimisticLockError otherwise.
+// The field must be a signed integer, or SetVersionCol panics.
+//
+// Automatically calls ResetSql() to ensure SQL statements are regenerated.
+func (t *TableMap) SetVersionCol(field string) *TableMap {
+	c := t.ColMap(field)
+	switch c.gotype.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+	default:
+		panic(fmt.Sprintf("godb: SetVersionCol: field %s must be a signed integer, not %v", field, c.gotype))
+	}
+	t.version = c
+	t.ResetSql()
+	return t
+}
 
+// SetSoftDelete sets the column marking the rows as deleted, a time.Time,
+// *time.Time, NullTime or sql.NullTime field which is NULL for the rows
+// not deleted. It can also be set with a `db:",softdelete"` tag.
+//
+// Delete then sets the column to the current time instead of deleting the
+// row, and Get, From and Preload skip the deleted rows. HardDelete and
+// Unscoped bypass the soft delete.
+func (t *TableMap) SetSoftDelete(field string) *TableMap {
+	c := t.ColMap(field)
+	f := t.gotype.FieldByIndex(c.fieldIndex)
+	if _, ok := timeValue(f.Type, time.Time{}); !ok {
+		panic(fmt.Sprintf("godb: SetSoftDelete: field %s must be a time, not %v", field, f.Type))
+	}
+	t.softDelete = c
+	t.ResetSql()
 	return t
 }
 
+// timeValue returns now as a value of typ, the type of a soft delete,
+// created or updated field.
+func timeValue(typ reflect.Type, now time.Time) (reflect.Value, bool) {
+	switch typ {
+	case reflect.TypeOf(now):
+		return reflect.ValueOf(now), true
+	case reflect.TypeOf(&now):
+		return reflect.ValueOf(&now), true
+	case reflect.TypeOf(NullTime{}):
+		return reflect.ValueOf(NullTime{Time: now, Valid: true}), true
+	case reflect.TypeOf(sql.NullTime{}):
+		return reflect.ValueOf(sql.NullTime{Time: now, Valid: true}), true
+	}
+	return reflect.Value{}, false
+}
+
+// notDeletedCond returns the condition selecting the rows not soft deleted,
+// with the column prefixed by alias, or "" if the table has no soft delete
+// column.
+func (t *TableMap) notDeletedCond(alias string) string {
+	if t.softDelete == nil {
+		return ""
+	}
+	return alias + t.dbUtils.Dialect.QuoteField(t.softDelete.ColumnName) + " is null"
+}
+
+// setTimestamps sets the updated fields of elem to now, and its created
+// fields too on insert. It returns a function restoring the fields, for
+// statements that fail.
+func (t *TableMap) setTimestamps(elem reflect.Value, now time.Time, insert bool) (restore func()) {
+	var fields, olds []reflect.Value
+	for _, col := range t.Columns {
+		if col.Transient || !(col.isUpdated || col.isCreated && insert) {
+			continue
+		}
+		f := elem.FieldByIndex(col.fieldIndex)
+		if v, ok := timeValue(f.Type(), now); ok {
+			fields = append(fields, f)
+			olds = append(olds, reflect.ValueOf(f.Interface()))
+			f.Set(v)
+		}
+	}
+	return func() {
+		for i, f := range fields {
+			f.Set(olds[i])
+		}
+	}
+}
+
 func (t *TableMap) ColMap(field string) *ColumnMap {
 	col := colMapOrNil(t, field)
 	if col == nil {
@@ -56,10 +200,28 @@ func colMapOrNil(t *TableMap, field string) *ColumnMap {
 			return col
 		}
 	}
-	return nil
+	// the field of a single prefixed embedded struct can be named
+	// without its path
+	var found *ColumnMap
+	for _, col := range t.Columns {
+		if strings.HasSuffix(col.fieldName, "."+field) {
+			if found != nil {
+				return nil
+			}
+			found = col
+		}
+	}
+	return found
 }
 
 func (t *TableMap) SetUniqueTogether(fieldNames ...string) *TableMap {
+	return t.SetUniqueTogetherWithName("", fieldNames...)
+}
+
+// SetUniqueTogetherWithName is the same as SetUniqueTogether, but names the
+// constraint in create table statements. The name can then be used as
+// UpsertOptions.Constraint.
+func (t *TableMap) SetUniqueTogetherWithName(name string, fieldNames ...string) *TableMap {
 	if len(fieldNames) < 2 {
 		panic(fmt.Sprintf(
 			"godb: SetUniqueTogether: must provide at least two fieldNames to set uniqueness constraint."))
@@ -69,7 +231,7 @@ func (t *TableMap) SetUniqueTogether(fieldNames ...string) *TableMap {
 	for _, name := range fieldNames {
 		columns = append(columns, name)
 	}
-	t.uniqueTogether = append(t.uniqueTogether, columns)
+	t.uniqueTogether = append(t.uniqueTogether, uniqueConstraint{name: name, columns: columns})
 
 	return t
 }
@@ -91,8 +253,8 @@ func (t *TableMap) AddIndex(name string, idxtype string, columns []string) *Inde
 		}
 	}
 	for _, icol := range columns {
-		if res := t.ColMap(icol); res == nil {
-			e := fmt.Sprintf("No ColumnName in table %s to create index on", t.TableName)
+		if col := colMapOrNil(t, icol); col == nil || col.Transient {
+			e := fmt.Sprintf("godb: no column %s in table %s to create index %s on", icol, t.TableName, name)
 			panic(e)
 		}
 	}
@@ -111,11 +273,12 @@ func (t *TableMap) CreateTableSql(ifNotExists bool) string {
 	if strings.TrimSpace(t.SchemaName) != "" {
 		schemaCreate := "create schema"
 		if ifNotExists {
-			s.WriteString(dialect.IfSchemaNotExists(schemaCreate, t.SchemaName))
-		} else {
+			schemaCreate = dialect.IfSchemaNotExists(schemaCreate, t.SchemaName)
+		}
+		if schemaCreate != "" {
 			s.WriteString(schemaCreate)
+			s.WriteString(fmt.Sprintf(" %s;", t.SchemaName))
 		}
-		s.WriteString(fmt.Sprintf(" %s;", t.SchemaName))
 	}
 
 	tableCreate := "create table"
@@ -163,9 +326,13 @@ func (t *TableMap) CreateTableSql(ifNotExists bool) string {
 		s.WriteString(")")
 	}
 	if len(t.uniqueTogether) > 0 {
-		for _, columns := range t.uniqueTogether {
-			s.WriteString(", unique (")
-			for i, column := range columns {
+		for _, unique := range t.uniqueTogether {
+			s.WriteString(", ")
+			if unique.name != "" {
+				s.WriteString(fmt.Sprintf("constraint %s ", dialect.QuoteField(unique.name)))
+			}
+			s.WriteString("unique (")
+			for i, column := range unique.columns {
 				if i > 0 {
 					s.WriteString(", ")
 				}
@@ -199,7 +366,13 @@ func (me CustomScanner) Bind() error {
 	return me.Binder(me.Holder, me.Target)
 }
 
+// versFieldConst is the placeholder used in bindPlan.argFields for the new
+// value of the version column.
+const versFieldConst = "[godb_ver_field]"
+
 type bindPlan struct {
+	tableName         string
+	schemaName        string
 	query             string
 	argFields         []string
 	keyFields         []string
@@ -207,22 +380,60 @@ type bindPlan struct {
 	autoIncrIdx       int
 	autoIncrFieldName string
 	once              sync.Once
+
+	// pieces of the insert statement used to build multi-row inserts.
+	// batchValues holds the value of each inserted column, with an empty
+	// string standing for a bind variable.
+	batchPrefix string
+	batchValues []string
+	batchSuffix string
+}
+
+// batchQuery returns the insert statement of the plan for the given number
+// of rows, each one adding len(plan.argFields) bind variables.
+func (plan *bindPlan) batchQuery(dialect Dialect, rows int) string {
+	s := bytes.Buffer{}
+	s.WriteString(plan.batchPrefix)
+
+	x := 0
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			s.WriteString(",")
+		}
+		s.WriteString("(")
+		for y, val := range plan.batchValues {
+			if y > 0 {
+				s.WriteString(",")
+			}
+			if val == "" {
+				s.WriteString(dialect.BindVar(x))
+				x++
+			} else {
+				s.WriteString(val)
+			}
+		}
+		s.WriteString(")")
+	}
+	s.WriteString(plan.batchSuffix)
+	return s.String()
 }
 
 func (plan *bindPlan) createBindInstance(elem reflect.Value, conv TypeConverter) (bindInstance, error) {
 	bi := bindInstance{query: plan.query, autoIncrIdx: plan.autoIncrIdx, autoIncrFieldName: plan.autoIncrFieldName, versField: plan.versField}
 	if plan.versField != "" {
-		bi.existingVersion = elem.FieldByName(plan.versField).Int()
+		bi.existingVersion = fieldByName(elem, plan.versField).Int()
 	}
 
 	var err error
 
 	for i := 0; i < len(plan.argFields); i++ {
 		k := plan.argFields[i]
-		if k == "" {
-
+		if k == versFieldConst {
+			bi.args = append(bi.args, bi.existingVersion+1)
+		} else if k == plan.versField {
+			bi.args = append(bi.args, bi.existingVersion)
 		} else {
-			val := elem.FieldByName(k).Interface()
+			val := fieldByName(elem, k).Interface()
 			if conv != nil {
 				val, err = conv.ToDb(val)
 				if err != nil {
@@ -235,7 +446,7 @@ func (plan *bindPlan) createBindInstance(elem reflect.Value, conv TypeConverter)
 
 	for i := 0; i < len(plan.keyFields); i++ {
 		k := plan.keyFields[i]
-		val := elem.FieldByName(k).Interface()
+		val := fieldByName(elem, k).Interface()
 		if conv != nil {
 			val, err = conv.ToDb(val)
 			if err != nil {
@@ -256,11 +467,18 @@ type bindInstance struct {
 	versField         string
 	autoIncrIdx       int
 	autoIncrFieldName string
+
+	// autoIncrQuery is set for upserts returning the key as a row.
+	autoIncrQuery bool
 }
 
 func (t *TableMap)insert(elem reflect.Value) (bindInstance, error)  {
+	plan := t.bindInsert()
+	return plan.createBindInstance(elem, t.dbUtils.TypeConverter)
+}
 
-	plan :=&bindPlan{}
+func (t *TableMap) bindInsert() *bindPlan {
+	plan := t.cachedPlan(insertPlanKind)
 	plan.once.Do(func() {
 		plan.autoIncrIdx = -1
 
@@ -282,17 +500,26 @@ func (t *TableMap)insert(elem reflect.Value) (bindInstance, error)  {
 
 					if col.isAutoIncr {
 						s2.WriteString(t.dbUtils.Dialect.AutoIncrBindValue())
+						plan.batchValues = append(plan.batchValues, t.dbUtils.Dialect.AutoIncrBindValue())
 						plan.autoIncrIdx = y
 						plan.autoIncrFieldName = col.fieldName
+					} else if col == t.version {
+						s2.WriteString(t.dbUtils.Dialect.BindVar(x))
+						plan.batchValues = append(plan.batchValues, "")
+						plan.versField = col.fieldName
+						plan.argFields = append(plan.argFields, versFieldConst)
+						x++
 					} else {
 						if col.DefaultValue == "" {
 							s2.WriteString(t.dbUtils.Dialect.BindVar(x))
+							plan.batchValues = append(plan.batchValues, "")
 
 							plan.argFields = append(plan.argFields, col.fieldName)
 
 							x++
 						} else {
 							s2.WriteString(col.DefaultValue)
+							plan.batchValues = append(plan.batchValues, col.DefaultValue)
 						}
 					}
 					first = false
@@ -302,25 +529,38 @@ func (t *TableMap)insert(elem reflect.Value) (bindInstance, error)  {
 				plan.autoIncrFieldName = col.fieldName
 			}
 		}
-		s.WriteString(") values (")
+		s.WriteString(") values ")
+		prefix := ""
+		if p, ok := t.dbUtils.Dialect.(AutoIncrInsertPrefixer); ok && plan.autoIncrIdx > -1 {
+			prefix = p.AutoIncrInsertPrefix(t.Columns[plan.autoIncrIdx])
+		}
+		plan.batchPrefix = prefix + s.String()
+		s.WriteString("(")
 		s.WriteString(s2.String())
 		s.WriteString(")")
+		suffix := ""
 		if plan.autoIncrIdx > -1 {
-			s.WriteString(t.dbUtils.Dialect.AutoIncrInsertSuffix(t.Columns[plan.autoIncrIdx]))
+			suffix = t.dbUtils.Dialect.AutoIncrInsertSuffix(t.Columns[plan.autoIncrIdx])
 		}
-		s.WriteString(t.dbUtils.Dialect.QuerySuffix())
+		suffix += t.dbUtils.Dialect.QuerySuffix()
+		s.WriteString(suffix)
+		plan.batchSuffix = suffix
 
-		plan.query = s.String()
+		plan.query = prefix + s.String()
 	})
 
-	fmt.Println(plan)
-
-	return plan.createBindInstance(elem, t.dbUtils.TypeConverter)
+	return plan
 }
 
 
-func (t *TableMap) bindGet() *bindPlan {
-	plan := &bindPlan{}
+// bindGet returns the plan of Get, which skips the soft deleted rows
+// unless unscoped.
+func (t *TableMap) bindGet(unscoped bool) *bindPlan {
+	kind := getPlanKind
+	if unscoped {
+		kind = unscopedGetPlanKind
+	}
+	plan := t.cachedPlan(kind)
 	plan.once.Do(func() {
 		s := bytes.Buffer{}
 		s.WriteString("select ")
@@ -350,12 +590,38 @@ func (t *TableMap) bindGet() *bindPlan {
 
 			plan.keyFields = append(plan.keyFields, col.fieldName)
 		}
+		if cond := t.notDeletedCond(""); cond != "" && !unscoped {
+			s.WriteString(" and ")
+			s.WriteString(cond)
+		}
 		s.WriteString(t.dbUtils.Dialect.QuerySuffix())
 
 		plan.query = s.String()
 	})
 
-	fmt.Println(plan.query)
+	return plan
+}
+
+// bindExists counts the rows with the keys of the table, deleted or not.
+func (t *TableMap) bindExists() *bindPlan {
+	plan := t.cachedPlan(existsPlanKind)
+	plan.once.Do(func() {
+		s := bytes.Buffer{}
+		s.WriteString("select count(*) from ")
+		s.WriteString(t.dbUtils.Dialect.QuotedTableForQuery(t.SchemaName, t.TableName))
+		s.WriteString(" where ")
+		for x, col := range t.keys {
+			if x > 0 {
+				s.WriteString(" and ")
+			}
+			s.WriteString(t.dbUtils.Dialect.QuoteField(col.ColumnName))
+			s.WriteString("=")
+			s.WriteString(t.dbUtils.Dialect.BindVar(x))
+		}
+		s.WriteString(t.dbUtils.Dialect.QuerySuffix())
+
+		plan.query = s.String()
+	})
 
 	return plan
 }
@@ -363,7 +629,7 @@ func (t *TableMap) bindGet() *bindPlan {
 func (t *TableMap) bindUpdate(elem reflect.Value) (bindInstance, error) {
 
 
-	plan := &bindPlan{}
+	plan := t.cachedPlan(updatePlanKind)
 	plan.once.Do(func() {
 		s := bytes.Buffer{}
 		s.WriteString(fmt.Sprintf("update %s set ", t.dbUtils.Dialect.QuotedTableForQuery(t.SchemaName, t.TableName)))
@@ -371,7 +637,7 @@ func (t *TableMap) bindUpdate(elem reflect.Value) (bindInstance, error) {
 
 		for y := range t.Columns {
 			col := t.Columns[y]
-			if !col.isAutoIncr && !col.Transient{
+			if !col.isAutoIncr && !col.Transient && !col.isCreated {
 				if x > 0 {
 					s.WriteString(", ")
 				}
@@ -379,8 +645,12 @@ func (t *TableMap) bindUpdate(elem reflect.Value) (bindInstance, error) {
 				s.WriteString("=")
 				s.WriteString(t.dbUtils.Dialect.BindVar(x))
 
-
-				plan.argFields = append(plan.argFields, col.fieldName)
+				if col == t.version {
+					plan.versField = col.fieldName
+					plan.argFields = append(plan.argFields, versFieldConst)
+				} else {
+					plan.argFields = append(plan.argFields, col.fieldName)
+				}
 
 				x++
 			}
@@ -400,6 +670,14 @@ func (t *TableMap) bindUpdate(elem reflect.Value) (bindInstance, error) {
 			plan.keyFields = append(plan.keyFields, col.fieldName)
 			x++
 		}
+		if plan.versField != "" {
+			s.WriteString(" and ")
+			s.WriteString(t.dbUtils.Dialect.QuoteField(t.version.ColumnName))
+			s.WriteString("=")
+			s.WriteString(t.dbUtils.Dialect.BindVar(x))
+
+			plan.argFields = append(plan.argFields, plan.versField)
+		}
 
 		s.WriteString(t.dbUtils.Dialect.QuerySuffix())
 
@@ -411,7 +689,7 @@ func (t *TableMap) bindUpdate(elem reflect.Value) (bindInstance, error) {
 
 
 func (t *TableMap) bindDelete(elem reflect.Value) (bindInstance, error) {
-	plan := &bindPlan{}
+	plan := t.cachedPlan(deletePlanKind)
 	plan.once.Do(func() {
 		s := bytes.Buffer{}
 		s.WriteString(fmt.Sprintf("delete from %s", t.dbUtils.Dialect.QuotedTableForQuery(t.SchemaName, t.TableName)))
@@ -419,7 +697,9 @@ func (t *TableMap) bindDelete(elem reflect.Value) (bindInstance, error) {
 		for y := range t.Columns {
 			col := t.Columns[y]
 			if !col.Transient {
-
+				if col == t.version {
+					plan.versField = col.fieldName
+				}
 			}
 		}
 
@@ -436,6 +716,14 @@ func (t *TableMap) bindDelete(elem reflect.Value) (bindInstance, error) {
 			plan.keyFields = append(plan.keyFields, k.fieldName)
 			plan.argFields = append(plan.argFields, k.fieldName)
 		}
+		if plan.versField != "" {
+			s.WriteString(" and ")
+			s.WriteString(t.dbUtils.Dialect.QuoteField(t.version.ColumnName))
+			s.WriteString("=")
+			s.WriteString(t.dbUtils.Dialect.BindVar(len(plan.argFields)))
+
+			plan.argFields = append(plan.argFields, plan.versField)
+		}
 
 		s.WriteString(t.dbUtils.Dialect.QuerySuffix())
 
@@ -443,4 +731,46 @@ func (t *TableMap) bindDelete(elem reflect.Value) (bindInstance, error) {
 	})
 
 	return plan.createBindInstance(elem, t.dbUtils.TypeConverter)
-}
\ No newline at end of file
+}
+
+// bindSoftDelete returns the update marking the row of elem as deleted,
+// elem holding the deletion time in its soft delete field. Rows already
+// deleted are not updated.
+func (t *TableMap) bindSoftDelete(elem reflect.Value) (bindInstance, error) {
+	plan := t.cachedPlan(softDeletePlanKind)
+	plan.once.Do(func() {
+		dialect := t.dbUtils.Dialect
+		s := bytes.Buffer{}
+		s.WriteString(fmt.Sprintf("update %s set %s=%s where ", dialect.QuotedTableForQuery(t.SchemaName, t.TableName),
+			dialect.QuoteField(t.softDelete.ColumnName), dialect.BindVar(0)))
+		plan.argFields = append(plan.argFields, t.softDelete.fieldName)
+
+		for x, k := range t.keys {
+			if x > 0 {
+				s.WriteString(" and ")
+			}
+			s.WriteString(dialect.QuoteField(k.ColumnName))
+			s.WriteString("=")
+			s.WriteString(dialect.BindVar(len(plan.argFields)))
+
+			plan.keyFields = append(plan.keyFields, k.fieldName)
+			plan.argFields = append(plan.argFields, k.fieldName)
+		}
+		if t.version != nil {
+			plan.versField = t.version.fieldName
+			s.WriteString(" and ")
+			s.WriteString(dialect.QuoteField(t.version.ColumnName))
+			s.WriteString("=")
+			s.WriteString(dialect.BindVar(len(plan.argFields)))
+
+			plan.argFields = append(plan.argFields, plan.versField)
+		}
+		s.WriteString(" and ")
+		s.WriteString(t.notDeletedCond(""))
+		s.WriteString(dialect.QuerySuffix())
+
+		plan.query = s.String()
+	})
+
+	return plan.createBindInstance(elem, t.dbUtils.TypeConverter)
+}
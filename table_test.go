@@ -1109,6 +1109,70 @@ func Test_SqlQueryRunnerInterfaceSelects(t *testing.T) {
 
 
 
+func TestDialect_Db2Exists(t *testing.T) {
+	dbUtils := &DbUtils{Dialect: Db2Dialect{}}
+	table := dbUtils.AddTableWithName(Student{}, "student").SetKeys(true, "Id")
+	table.SchemaName = "app"
+	want := `create table "APP"."STUDENT" (`
+	if query := table.CreateTableSql(true); !strings.HasPrefix(query, want) {
+		t.Errorf("expected no create schema nor if not exists, got %s", query)
+	}
+
+	d := Db2Dialect{}
+	exists := errors.New(`SQLExecute: {42710} [IBM][CLI Driver][DB2/LINUXX8664] SQL0601N  The name of the object to be created is identical to the existing name "APP.STUDENT" of type "TABLE".  SQLSTATE=42710`)
+	undefined := errors.New(`SQL0204N  "APP.STUDENT" is an undefined name.  SQLSTATE=42704`)
+	if !d.IsAlreadyExists(exists) || d.IsAlreadyExists(undefined) || !d.IsUndefined(undefined) || d.IsUndefined(exists) || d.IsUndefined(nil) {
+		t.Error("unexpected classification of the DB2 errors")
+	}
+}
+
+// existsSqliteDialect creates and drops tables without existence clauses,
+// as Db2Dialect does.
+type existsSqliteDialect struct {
+	SqliteDialect
+}
+
+func (d existsSqliteDialect) IfTableExists(command, schema, table string) string {
+	return command
+}
+
+func (d existsSqliteDialect) IfTableNotExists(command, schema, table string) string {
+	return command
+}
+
+func (d existsSqliteDialect) IsAlreadyExists(err error) bool {
+	return strings.Contains(err.Error(), "already exists")
+}
+
+func (d existsSqliteDialect) IsUndefined(err error) bool {
+	return strings.Contains(err.Error(), "no such table")
+}
+
+func Test_ExistsErrorClassifier(t *testing.T) {
+	dbmap := initSqliteDB(t, "exists")
+	dbmap.Dialect = existsSqliteDialect{}
+	dbmap.AddTableWithName(Student{}, "student_exists_test").SetKeys(true, "Id")
+
+	if err := dbmap.DropTablesIfExists(); err != nil {
+		t.Errorf("expected a missing table ignored, got %v", err)
+	}
+	if err := dbmap.CreateTablesIfNotExists(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbmap.CreateTablesIfNotExists(); err != nil {
+		t.Errorf("expected an existing table ignored, got %v", err)
+	}
+	if err := dbmap.CreateTables(); err == nil {
+		t.Error("expected CreateTables to fail on an existing table")
+	}
+	if err := dbmap.DropTables(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbmap.DropTables(); err == nil {
+		t.Error("expected DropTables to fail on a missing table")
+	}
+}
+
 func Test_NullTime(t *testing.T) {
 	dbmap := initDB()
 	dbmap.AddTableWithName(WithNullTime{}, "nulltime_test").SetKeys(false, "Id")
@@ -1388,4 +1452,58 @@ func _del(dbmap *DbUtils, list ...interface{}) int64 {
 	}
 
 	return count
-}
\ No newline at end of file
+}
+type VersionedInvoice struct {
+	Id      int64
+	Memo    string
+	Version int64
+}
+
+type VersionedTag struct {
+	Id   int64
+	Name string
+	Rev  int32 `db:"rev, version"`
+}
+
+func Test_OptimisticLock(t *testing.T) {
+	dbmap := initDB()
+	dbmap.AddTableWithName(VersionedInvoice{}, "versioned_invoice_test").SetKeys(true, "Id").SetVersionCol("Version")
+	dbmap.AddTableWithName(VersionedTag{}, "versioned_tag_test").SetKeys(true, "Id")
+	dbmap.CreateTablesIfNotExists()
+	defer close(dbmap)
+
+	inv := &VersionedInvoice{Memo: "a"}
+	_insert(dbmap, inv)
+	if inv.Version != 1 {
+		t.Errorf("%d != 1", inv.Version)
+	}
+
+	stale := *inv
+	inv.Memo = "b"
+	_update(dbmap, inv)
+	if inv.Version != 2 {
+		t.Errorf("%d != 2", inv.Version)
+	}
+
+	stale.Memo = "c"
+	_, err := dbmap.Update(&stale)
+	if lockErr, ok := err.(*OptimisticLockError); !ok || !lockErr.RowExists || lockErr.LocalVersion != 1 {
+		t.Errorf("expected OptimisticLockError with RowExists, got %v", err)
+	}
+	if _, err = dbmap.Delete(&stale); err == nil {
+		t.Errorf("Delete with a stale version did not fail")
+	}
+
+	_del(dbmap, inv)
+	_, err = dbmap.Update(inv)
+	if lockErr, ok := err.(*OptimisticLockError); !ok || lockErr.RowExists {
+		t.Errorf("expected OptimisticLockError without RowExists, got %v", err)
+	}
+
+	tag := &VersionedTag{Name: "tag"}
+	_insert(dbmap, tag)
+	_update(dbmap, tag)
+	if tag.Rev != 2 {
+		t.Errorf("%d != 2", tag.Rev)
+	}
+}
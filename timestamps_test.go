@@ -0,0 +1,95 @@
+package godb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TimestampPost struct {
+	Id      int64     `db:"id, primarykey, autoincrement"`
+	Title   string    `db:"title, size:50"`
+	Created time.Time `db:"created, created"`
+	Updated NullTime  `db:"updated, updated"`
+}
+
+func TestTableMap_TimestampSql(t *testing.T) {
+	dbUtils := &DbUtils{Dialect: PostgresDialect{}}
+	table := dbUtils.AddTableWithName(TimestampPost{}, "timestamp_post_test")
+
+	bi, err := table.bindUpdate(reflect.ValueOf(&TimestampPost{Id: 1}).Elem())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `update "timestamp_post_test" set "title"=$1, "updated"=$2 where "id"=$3;`
+	if bi.query != want {
+		t.Errorf("got %s, want %s", bi.query, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a created string field to panic")
+		}
+	}()
+	dbUtils.AddTable(struct {
+		Id      int64  `db:"id, primarykey"`
+		Created string `db:",created"`
+	}{})
+}
+
+func Test_Timestamps(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	now := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	dbmap.Clock = func() time.Time { return now }
+	dbmap.AddTableWithName(TimestampPost{}, "timestamp_post_test")
+	dbmap.DropTablesIfExists()
+	if err := dbmap.CreateTables(); err != nil {
+		panic(err)
+	}
+	defer dbmap.DropTablesIfExists()
+
+	created := now
+	p := &TimestampPost{Title: "a"}
+	_insert(dbmap, p)
+	if !p.Created.Equal(created) || !p.Updated.Valid || !p.Updated.Time.Equal(created) {
+		t.Errorf("unexpected timestamps after insert: %v %v", p.Created, p.Updated)
+	}
+
+	now = now.Add(time.Hour)
+	p.Created = time.Time{}
+	p.Title = "b"
+	if _, err := dbmap.Update(p); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Updated.Time.Equal(now) {
+		t.Errorf("updated was not set: %v", p.Updated)
+	}
+
+	obj, err := dbmap.Get(TimestampPost{}, p.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := obj.(*TimestampPost); !got.Created.Equal(created) || !got.Updated.Time.Equal(now) {
+		t.Errorf("unexpected stored timestamps: %v %v", got.Created, got.Updated)
+	}
+
+	missing := &TimestampPost{Id: p.Id + 100, Title: "c"}
+	if n, err := dbmap.Update(missing); err != nil || n != 0 {
+		t.Fatalf("expected no row updated, got %d, %v", n, err)
+	}
+	if missing.Updated.Valid {
+		t.Errorf("expected updated left unset without a row updated, got %v", missing.Updated)
+	}
+
+	if err := dbmap.DropTables(); err != nil {
+		t.Fatal(err)
+	}
+	q := &TimestampPost{Title: "d"}
+	if err := dbmap.Insert(q); err == nil {
+		t.Fatal("expected an error inserting into a dropped table")
+	}
+	if !q.Created.IsZero() || q.Updated.Valid {
+		t.Errorf("expected the timestamps restored after a failed insert, got %v %v", q.Created, q.Updated)
+	}
+}
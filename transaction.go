@@ -11,6 +11,53 @@ type Transaction struct {
 	dbUtils  *DbUtils
 	tx       *sql.Tx
 	closed   bool
+	id       string
+
+	// savepoint names the savepoint of a nested transaction, and is empty
+	// for the database transaction.
+	savepoint  string
+	savepoints *savepointStack
+
+	isolation sql.IsolationLevel
+}
+
+// savepointStack holds the savepoints of the open nested transactions,
+// shared by the transactions of a database transaction.
+type savepointStack struct {
+	names []string
+	seq   int
+}
+
+// IsolationLeveler is implemented by dialects whose drivers do not support
+// all the isolation levels of sql.TxOptions, which are set with SQL instead.
+type IsolationLeveler interface {
+	// IsolationSql returns the statement applying opts at the start of a
+	// transaction, or "" to let the driver apply them, and the isolation
+	// level the transaction runs at, which may be stricter than the
+	// requested one. It fails for levels the database does not provide.
+	IsolationSql(opts sql.TxOptions) (string, sql.IsolationLevel, error)
+}
+
+// Savepointer is implemented by dialects whose savepoint statements differ
+// from the standard savepoint, rollback to savepoint and release savepoint.
+type Savepointer interface {
+	SavepointSql(name string) string
+	RollbackToSavepointSql(name string) string
+
+	// ReleaseSavepointSql returns "" if savepoints cannot be released, in
+	// which case they last until the end of the transaction.
+	ReleaseSavepointSql(name string) string
+}
+
+// ID returns the identifier of the transaction reported in QueryEvent.TxID.
+func (t *Transaction) ID() string {
+	return t.id
+}
+
+// IsolationLevel returns the isolation level the transaction runs at, or
+// sql.LevelDefault if it runs at the default level of the database.
+func (t *Transaction) IsolationLevel() sql.IsolationLevel {
+	return t.isolation
 }
 
 func (t *Transaction) WithContext(ctx context.Context) SqlQueryRunner {
@@ -25,6 +72,21 @@ func (t *Transaction) Insert(list ...interface{}) error {
 	return insert(t.dbUtils, t, list...)
 }
 
+// InsertBatch has the same behavior as DbUtils.InsertBatch(), but runs in a transaction.
+func (t *Transaction) InsertBatch(list interface{}, batchSize int) error {
+	return insertBatch(t.dbUtils, t, list, batchSize)
+}
+
+// Upsert has the same behavior as DbUtils.Upsert(), but runs in a transaction.
+func (t *Transaction) Upsert(list ...interface{}) error {
+	return upsert(t.dbUtils, t, UpsertOptions{}, list...)
+}
+
+// UpsertWithOptions has the same behavior as DbUtils.UpsertWithOptions(), but runs in a transaction.
+func (t *Transaction) UpsertWithOptions(opts UpsertOptions, list ...interface{}) error {
+	return upsert(t.dbUtils, t, opts, list...)
+}
+
 // Update had the same behavior as DbMap.Update(), but runs in a transaction.
 func (t *Transaction) Update(list ...interface{}) (int64, error) {
 	return update(t.dbUtils, t, list...)
@@ -32,7 +94,27 @@ func (t *Transaction) Update(list ...interface{}) (int64, error) {
 
 // Delete has the same behavior as DbMap.Delete(), but runs in a transaction.
 func (t *Transaction) Delete(list ...interface{}) (int64, error) {
-	return delete(t.dbUtils, t, list...)
+	return delete(t.dbUtils, t, false, list...)
+}
+
+// HardDelete has the same behavior as DbUtils.HardDelete(), but runs in a transaction.
+func (t *Transaction) HardDelete(list ...interface{}) (int64, error) {
+	return delete(t.dbUtils, t, true, list...)
+}
+
+// Unscoped has the same behavior as DbUtils.Unscoped(), but runs in the
+// transaction.
+func (t *Transaction) Unscoped() *Transaction {
+	return t.withDbUtils(t.dbUtils.Unscoped())
+}
+
+// withDbUtils returns a copy of t running in the same database transaction
+// with the tables and settings of dbUtils.
+func (t *Transaction) withDbUtils(dbUtils *DbUtils) *Transaction {
+	copy := &Transaction{}
+	*copy = *t
+	copy.dbUtils = dbUtils
+	return copy
 }
 
 // Get has the same behavior as DbMap.Get(), but runs in a transaction.
@@ -45,6 +127,21 @@ func (t *Transaction) Select(i interface{}, query string, args ...interface{}) (
 	return selectlist(t.dbUtils, t, i, query, args...)
 }
 
+// SelectIter has the same behavior as DbUtils.SelectIter(), but runs in a transaction.
+func (t *Transaction) SelectIter(holderType interface{}, query string, args ...interface{}) (*RowIter, error) {
+	return selectIter(t.dbUtils, t, holderType, query, args...)
+}
+
+// QueryMap has the same behavior as DbUtils.QueryMap(), but runs in a transaction.
+func (t *Transaction) QueryMap(query string, args ...interface{}) (*RowsMap, error) {
+	return queryMap(t.dbUtils, t, query, args...)
+}
+
+// SelectMaps has the same behavior as DbUtils.SelectMaps(), but runs in a transaction.
+func (t *Transaction) SelectMaps(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return selectMaps(t.dbUtils, t, query, args...)
+}
+
 // Exec has the same behavior as DbMap.Exec(), but runs in a transaction.
 func (t *Transaction) Exec(query string, args ...interface{}) (sql.Result, error) {
 	return maybeExpandNamedQueryAndExec(t, query, args...)
@@ -85,31 +182,96 @@ func (t *Transaction) SelectOne(holder interface{}, query string, args ...interf
 	return SelectOne(t.dbUtils, t, holder, query, args...)
 }
 
+// Begin starts a nested transaction, backed by a savepoint of t. Its
+// Commit releases the savepoint and its Rollback rolls back to it, leaving
+// t open. t cannot be committed while the nested transaction is open.
+func (t *Transaction) Begin() (*Transaction, error) {
+	if t.done() {
+		return nil, sql.ErrTxDone
+	}
+	if t.savepoints == nil {
+		t.savepoints = &savepointStack{}
+	}
+	name := fmt.Sprintf("godb_sp_%d", t.savepoints.seq+1)
+	if err := t.Savepoint(name); err != nil {
+		return nil, err
+	}
+	t.savepoints.seq++
+	t.savepoints.names = append(t.savepoints.names, name)
+
+	child := &Transaction{}
+	*child = *t
+	child.savepoint = name
+	child.closed = false
+	return child, nil
+}
+
+// done reports whether t was committed or rolled back, by itself or, for a
+// nested transaction, by the rollback of an enclosing transaction.
+func (t *Transaction) done() bool {
+	return t.closed || t.savepoint != "" && t.savepoints.index(t.savepoint) < 0
+}
+
+// index returns the position of the savepoint named name, or -1.
+func (s *savepointStack) index(name string) int {
+	if s == nil {
+		return -1
+	}
+	for i, n := range s.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Commit commits the database transaction or, for a nested transaction,
+// releases its savepoint. It fails while a transaction nested in t is open.
 func (t *Transaction) Commit() error {
-	if !t.closed {
+	if t.done() {
+		return sql.ErrTxDone
+	}
+	if open := t.savepoints.index(t.savepoint) + 1; t.savepoints != nil && open < len(t.savepoints.names) {
+		return fmt.Errorf("godb: cannot commit transaction %s while nested transaction %s is open", t.id, t.savepoints.names[open])
+	}
+
+	if t.savepoint == "" {
 		t.closed = true
 
 		return t.tx.Commit()
 	}
-
-	return sql.ErrTxDone
+	if err := t.ReleaseSavepoint(t.savepoint); err != nil {
+		return err
+	}
+	t.closed = true
+	t.savepoints.names = t.savepoints.names[:len(t.savepoints.names)-1]
+	return nil
 }
 
-// Rollback rolls back the underlying database transaction.
+// Rollback rolls back the underlying database transaction or, for a nested
+// transaction, rolls back to its savepoint. The transactions nested in t
+// are rolled back with it.
 func (t *Transaction) Rollback() error {
-	if !t.closed {
-		t.closed = true
+	if t.done() {
+		return sql.ErrTxDone
+	}
+	t.closed = true
 
+	if t.savepoint == "" {
+		if t.savepoints != nil {
+			t.savepoints.names = nil
+		}
 		return t.tx.Rollback()
 	}
-
-	return sql.ErrTxDone
+	t.savepoints.names = t.savepoints.names[:t.savepoints.index(t.savepoint)]
+	return t.RollbackToSavepoint(t.savepoint)
 }
 
 func (t *Transaction) Savepoint(name string) error {
 	query := "savepoint " + t.dbUtils.Dialect.QuoteField(name)
-
-	fmt.Println(query)
+	if sp, ok := t.dbUtils.Dialect.(Savepointer); ok {
+		query = sp.SavepointSql(name)
+	}
 	_, err := maybeExpandNamedQueryAndExec(t, query)
 	return err
 }
@@ -119,6 +281,25 @@ func (t *Transaction) Savepoint(name string) error {
 // sanitize it if it is derived from user input.
 func (t *Transaction) RollbackToSavepoint(savepoint string) error {
 	query := "rollback to savepoint " + t.dbUtils.Dialect.QuoteField(savepoint)
+	if sp, ok := t.dbUtils.Dialect.(Savepointer); ok {
+		query = sp.RollbackToSavepointSql(savepoint)
+	}
+
+	_, err := maybeExpandNamedQueryAndExec(t, query)
+	return err
+}
+
+// ReleaseSavepoint releases the savepoint with the given name, keeping the
+// changes made since. It does nothing for dialects without release, such as
+// SQL Server and Oracle.
+func (t *Transaction) ReleaseSavepoint(savepoint string) error {
+	query := "release savepoint " + t.dbUtils.Dialect.QuoteField(savepoint)
+	if sp, ok := t.dbUtils.Dialect.(Savepointer); ok {
+		query = sp.ReleaseSavepointSql(savepoint)
+	}
+	if query == "" {
+		return nil
+	}
 
 	_, err := maybeExpandNamedQueryAndExec(t, query)
 	return err
@@ -0,0 +1,90 @@
+package godb
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestDialect_IsolationSql(t *testing.T) {
+	tests := []struct {
+		dialect IsolationLeveler
+		opts    sql.TxOptions
+		query   string
+		level   sql.IsolationLevel
+	}{
+		{OracleDialect{}, sql.TxOptions{}, "", sql.LevelDefault},
+		{OracleDialect{}, sql.TxOptions{Isolation: sql.LevelReadUncommitted}, "set transaction isolation level read committed", sql.LevelReadCommitted},
+		{OracleDialect{}, sql.TxOptions{Isolation: sql.LevelReadCommitted}, "set transaction isolation level read committed", sql.LevelReadCommitted},
+		{OracleDialect{}, sql.TxOptions{Isolation: sql.LevelRepeatableRead}, "set transaction isolation level serializable", sql.LevelSerializable},
+		{OracleDialect{}, sql.TxOptions{Isolation: sql.LevelSerializable}, "set transaction isolation level serializable", sql.LevelSerializable},
+		{OracleDialect{}, sql.TxOptions{ReadOnly: true}, "set transaction read only", sql.LevelSerializable},
+		{Db2Dialect{}, sql.TxOptions{}, "", sql.LevelDefault},
+		{Db2Dialect{}, sql.TxOptions{Isolation: sql.LevelReadCommitted}, "", sql.LevelReadCommitted},
+		{Db2Dialect{}, sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}, "", sql.LevelSerializable},
+	}
+	for _, test := range tests {
+		query, level, err := test.dialect.IsolationSql(test.opts)
+		if err != nil || query != test.query || level != test.level {
+			t.Errorf("%T.IsolationSql(%+v) = %q, %s, %v, want %q, %s", test.dialect, test.opts, query, level, err, test.query, test.level)
+		}
+	}
+	for _, d := range []IsolationLeveler{OracleDialect{}, Db2Dialect{}} {
+		if _, _, err := d.IsolationSql(sql.TxOptions{Isolation: sql.LevelLinearizable}); err == nil {
+			t.Errorf("%T: expected an error for linearizable", d)
+		}
+	}
+}
+
+// isolationSqliteDialect sets isolation levels with a statement, recorded
+// by the Logger of the test.
+type isolationSqliteDialect struct {
+	SqliteDialect
+}
+
+func (d isolationSqliteDialect) IsolationSql(opts sql.TxOptions) (string, sql.IsolationLevel, error) {
+	return "pragma read_uncommitted = 0", sql.LevelSerializable, nil
+}
+
+func Test_BeginWithOptions(t *testing.T) {
+	dbmap := initSqliteDB(t, "options")
+
+	trans, err := dbmap.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level := trans.IsolationLevel(); level != sql.LevelDefault {
+		t.Errorf("expected the default level, got %s", level)
+	}
+	trans.Rollback()
+
+	trans, err = dbmap.BeginWithOptions(&sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level := trans.IsolationLevel(); level != sql.LevelSerializable {
+		t.Errorf("expected serializable, got %s", level)
+	}
+	child, err := trans.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level := child.IsolationLevel(); level != sql.LevelSerializable {
+		t.Errorf("expected the nested transaction at the level of its parent, got %s", level)
+	}
+	child.Rollback()
+	trans.Rollback()
+
+	logger := &recordingLogger{}
+	dbmap = &DbUtils{Db: dbmap.Db, Dialect: isolationSqliteDialect{}, Logger: logger}
+	trans, err = dbmap.BeginWithOptions(&sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trans.Rollback()
+	if len(logger.events) != 1 || logger.events[0].Query != "pragma read_uncommitted = 0" || logger.events[0].TxID != trans.ID() {
+		t.Errorf("expected the statement of the dialect run in the transaction, got %+v", logger.events)
+	}
+	if level := trans.IsolationLevel(); level != sql.LevelSerializable {
+		t.Errorf("expected the level given by the dialect, got %s", level)
+	}
+}
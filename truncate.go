@@ -0,0 +1,163 @@
+package godb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TruncateOptions configures TruncateTablesWithOptions and
+// TruncateTableWithOptions.
+type TruncateOptions struct {
+	// RestartIdentity resets the autoincrement counters of the tables.
+	// MySQL always resets them.
+	RestartIdentity bool
+
+	// Cascade also empties the tables referencing the truncated tables
+	// through foreign keys, registered or not. It requires a dialect that
+	// is a SchemaInspector.
+	Cascade bool
+}
+
+// QualifiedTable identifies a table by schema and name.
+type QualifiedTable struct {
+	Schema string
+	Name   string
+}
+
+// Truncater is implemented by dialects with their own way of emptying
+// tables. Other dialects run TruncateClause() on each table, and cannot
+// restart identities.
+type Truncater interface {
+	// Truncate empties tables, ordered so that a table comes before the
+	// tables it references. exec is a transaction, so that settings of
+	// the session apply to every statement.
+	Truncate(exec SqlQueryRunner, tables []QualifiedTable, opts TruncateOptions) error
+}
+
+// truncate empties tables and, if opts.Cascade is set, the tables
+// referencing them. The tables are ordered by foreign key when the dialect
+// is a SchemaInspector.
+func truncate(dbUtils *DbUtils, tables []*TableMap, opts TruncateOptions) error {
+	targets := make([]QualifiedTable, len(tables))
+	for i, table := range tables {
+		targets[i] = QualifiedTable{Schema: table.SchemaName, Name: table.TableName}
+	}
+
+	if inspector, ok := dbUtils.Dialect.(SchemaInspector); ok {
+		var err error
+		targets, err = orderByForeignKeys(dbUtils, inspector, targets, opts.Cascade)
+		if err != nil {
+			return err
+		}
+	} else if opts.Cascade {
+		return fmt.Errorf("godb: dialect %T cannot find the tables to cascade to", dbUtils.Dialect)
+	}
+
+	truncater, ok := dbUtils.Dialect.(Truncater)
+	if !ok && opts.RestartIdentity {
+		return fmt.Errorf("godb: dialect %T cannot restart identities", dbUtils.Dialect)
+	}
+
+	trans, err := dbUtils.Begin()
+	if err != nil {
+		return err
+	}
+	if ok {
+		err = truncater.Truncate(trans, targets, opts)
+	} else {
+		for _, t := range targets {
+			_, err = trans.Exec(fmt.Sprintf("%s %s%s", dbUtils.Dialect.TruncateClause(),
+				dbUtils.Dialect.QuotedTableForQuery(t.Schema, t.Name), dbUtils.Dialect.QuerySuffix()))
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		trans.Rollback()
+		return err
+	}
+	return trans.Commit()
+}
+
+// orderByForeignKeys orders targets so that each table comes before the
+// tables it references, adding the tables referencing them if cascade is
+// set. Tables not found in the database keep their order.
+func orderByForeignKeys(dbUtils *DbUtils, inspector SchemaInspector, targets []QualifiedTable, cascade bool) ([]QualifiedTable, error) {
+	schemas := make(map[string]*Schema)
+	key := func(t QualifiedTable) string {
+		schema := t.Schema
+		if s, ok := schemas[schema]; ok {
+			schema = s.Name
+		}
+		return strings.ToLower(schema + "." + t.Name)
+	}
+
+	for _, t := range targets {
+		if _, ok := schemas[t.Schema]; ok {
+			continue
+		}
+		s, err := inspector.InspectSchema(dbUtils, t.Schema)
+		if err != nil {
+			return nil, err
+		}
+		schemas[t.Schema] = s
+		if s.Name != t.Schema {
+			schemas[s.Name] = s
+		}
+	}
+
+	// children lists the tables referencing each table
+	children := make(map[string][]QualifiedTable)
+	for name, s := range schemas {
+		if name != s.Name {
+			continue
+		}
+		for _, table := range s.Tables {
+			child := QualifiedTable{Schema: s.Name, Name: table.Name}
+			for _, fk := range table.ForeignKeys {
+				parent := QualifiedTable{Schema: fk.RefSchema, Name: fk.RefTable}
+				if key(parent) != key(child) {
+					children[key(parent)] = append(children[key(parent)], child)
+				}
+			}
+		}
+	}
+
+	selected := make(map[string]bool)
+	for _, t := range targets {
+		selected[key(t)] = true
+	}
+	if cascade {
+		for i := 0; i < len(targets); i++ {
+			for _, child := range children[key(targets[i])] {
+				if !selected[key(child)] {
+					selected[key(child)] = true
+					targets = append(targets, child)
+				}
+			}
+		}
+	}
+
+	var (
+		ordered []QualifiedTable
+		visited = make(map[string]bool)
+		visit   func(t QualifiedTable)
+	)
+	visit = func(t QualifiedTable) {
+		if visited[key(t)] {
+			return
+		}
+		visited[key(t)] = true
+		for _, child := range children[key(t)] {
+			if selected[key(child)] {
+				visit(child)
+			}
+		}
+		ordered = append(ordered, t)
+	}
+	for _, t := range targets {
+		visit(t)
+	}
+	return ordered, nil
+}
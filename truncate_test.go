@@ -0,0 +1,105 @@
+package godb
+
+import (
+	"testing"
+)
+
+type TruncateParent struct {
+	Id   int64  `db:"id, primarykey, autoincrement"`
+	Name string `db:"name, size:50"`
+}
+
+type TruncateChild struct {
+	Id       int64 `db:"id, primarykey, autoincrement"`
+	ParentId int64 `db:"parent_id"`
+}
+
+func TestDialect_PostgresTruncateSql(t *testing.T) {
+	d := PostgresDialect{}
+	tables := []QualifiedTable{{Name: "child"}, {Schema: "app", Name: "parent"}}
+	tests := []struct {
+		opts TruncateOptions
+		want string
+	}{
+		{TruncateOptions{}, `truncate "child", app."parent";`},
+		{TruncateOptions{RestartIdentity: true}, `truncate "child", app."parent" restart identity;`},
+		{TruncateOptions{RestartIdentity: true, Cascade: true}, `truncate "child", app."parent" restart identity cascade;`},
+	}
+	for _, test := range tests {
+		if got := d.truncateSql(tables, test.opts); got != test.want {
+			t.Errorf("%+v: got %s, want %s", test.opts, got, test.want)
+		}
+	}
+}
+
+// plainDialect hides the optional interfaces of its Dialect.
+type plainDialect struct {
+	Dialect
+}
+
+func TestDbUtils_TruncateErrors(t *testing.T) {
+	dbUtils := &DbUtils{Dialect: SqliteDialect{}}
+	if err := dbUtils.TruncateTable(nil); err == nil {
+		t.Error("expected an error for a nil table")
+	}
+
+	dbUtils = &DbUtils{Dialect: plainDialect{SqliteDialect{}}}
+	dbUtils.AddTableWithName(TruncateParent{}, "truncate_parent_test")
+	if err := dbUtils.TruncateTableWithOptions(TruncateParent{}, TruncateOptions{Cascade: true}); err == nil {
+		t.Error("expected an error cascading without schema inspection")
+	}
+}
+
+func Test_TruncateTables(t *testing.T) {
+	dbmap := initDB()
+	defer close(dbmap)
+	dbmap.Exec("drop table if exists truncate_child_test, truncate_other_test, truncate_parent_test")
+	dbmap.Exec("create table truncate_parent_test (id bigint not null auto_increment primary key, name varchar(50))")
+	dbmap.Exec("create table truncate_child_test (id bigint not null auto_increment primary key, parent_id bigint not null, " +
+		"foreign key (parent_id) references truncate_parent_test (id))")
+	dbmap.Exec("create table truncate_other_test (id bigint not null auto_increment primary key, parent_id bigint not null, " +
+		"foreign key (parent_id) references truncate_parent_test (id))")
+	defer dbmap.Exec("drop table if exists truncate_child_test, truncate_other_test, truncate_parent_test")
+
+	dbmap.AddTableWithName(TruncateParent{}, "truncate_parent_test")
+	dbmap.AddTableWithName(TruncateChild{}, "truncate_child_test")
+	insert := func() {
+		parent := &TruncateParent{Name: "parent"}
+		_insert(dbmap, parent)
+		_insert(dbmap, &TruncateChild{ParentId: parent.Id})
+		rawExec(dbmap, "insert into truncate_other_test (parent_id) values (?)", parent.Id)
+	}
+
+	insert()
+	if err := dbmap.TruncateTable(TruncateParent{}); err != nil {
+		t.Fatal(err)
+	}
+	if count := selectInt(dbmap, "select count(*) from truncate_other_test"); count != 1 {
+		t.Errorf("unregistered table was truncated without cascade: %d rows", count)
+	}
+	dbmap.Exec("delete from truncate_child_test")
+	dbmap.Exec("delete from truncate_other_test")
+
+	insert()
+	if err := dbmap.TruncateTableWithOptions(&TruncateParent{}, TruncateOptions{Cascade: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"truncate_parent_test", "truncate_child_test", "truncate_other_test"} {
+		if count := selectInt(dbmap, "select count(*) from "+table); count != 0 {
+			t.Errorf("%s has %d rows after a cascading truncate", table, count)
+		}
+	}
+
+	insert()
+	if err := dbmap.TruncateTablesWithOptions(TruncateOptions{RestartIdentity: true, Cascade: true}); err != nil {
+		t.Fatal(err)
+	}
+	parent := &TruncateParent{Name: "again"}
+	_insert(dbmap, parent)
+	if parent.Id != 1 {
+		t.Errorf("identity was not restarted, got id %d", parent.Id)
+	}
+	if checks := selectInt(dbmap, "select @@foreign_key_checks"); checks != 1 {
+		t.Error("foreign key checks were not enabled again")
+	}
+}
@@ -0,0 +1,296 @@
+package godb
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Upserter is implemented by dialects that can insert a row, or update the
+// existing row it conflicts with, in a single statement.
+type Upserter interface {
+	// UpsertSql returns the statement inserting values into columns of the
+	// table. values holds the SQL expression of each column, usually a bind
+	// variable. conflict holds the columns identifying an existing row and
+	// update the columns overwritten with the new values when that row
+	// exists. An empty update leaves an existing row untouched.
+	UpsertSql(schema, table string, columns, values, conflict, update []string) string
+}
+
+// UpsertKeyReturner is implemented by Upserters whose upsert can report the
+// autoincrement key of the row inserted or updated. Upserts with other
+// dialects leave the key of the struct unchanged.
+type UpsertKeyReturner interface {
+	// UpsertKeySql returns the statement of UpsertSql also reporting the
+	// value of the key column: as the single column of the returned row if
+	// query is true, and as the LastInsertId of the result otherwise.
+	UpsertKeySql(schema, table string, columns, values, conflict, update []string, key string) (sql string, query bool)
+}
+
+// GeneratedKeyUpserter is implemented by Upserters whose autoincrement
+// columns reject explicit values, such as SQL Server identity columns and
+// DB2 columns generated always. When GeneratedKeys returns true, an
+// autoincrement key cannot identify the row of an upsert, which needs an
+// UpsertOptions.Constraint instead.
+type GeneratedKeyUpserter interface {
+	GeneratedKeys() bool
+}
+
+// UpsertOptions configures UpsertWithOptions.
+type UpsertOptions struct {
+	// Constraint is the name of a unique constraint, set with
+	// SetUniqueTogetherWithName, whose columns identify an existing row.
+	// The primary key is used when empty.
+	Constraint string
+
+	// UpdateFields lists the fields overwritten when the row exists. When
+	// empty, every inserted field that does not identify the row is.
+	UpdateFields []string
+
+	// DoNothing leaves an existing row untouched. Tables with a version
+	// column only accept such upserts, as the version of the row updated
+	// otherwise could not be checked.
+	DoNothing bool
+}
+
+func upsert(dbUtils *DbUtils, queryRunner SqlQueryRunner, opts UpsertOptions, list ...interface{}) error {
+	for _, ptr := range list {
+		table, elem, err := dbUtils.tableForPointer(ptr, opts.Constraint == "")
+		if err != nil {
+			return err
+		}
+
+		restore := table.setTimestamps(elem, dbUtils.now(), true)
+		bi, err := table.bindUpsert(opts, elem)
+		if err != nil {
+			restore()
+			return err
+		}
+
+		if bi.autoIncrIdx > -1 {
+			err = upsertReturningKey(queryRunner, bi, fieldByName(elem, bi.autoIncrFieldName))
+		} else {
+			_, err = queryRunner.Exec(bi.query, bi.args...)
+		}
+		if err != nil {
+			restore()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upsertReturningKey runs the upsert of bi and sets f to the key it
+// reports. Upserts leaving an existing row untouched may report no key, in
+// which case f is unchanged.
+func upsertReturningKey(queryRunner SqlQueryRunner, bi bindInstance, f reflect.Value) error {
+	var id int64
+	if bi.autoIncrQuery {
+		err := queryRunner.QueryRow(bi.query, bi.args...).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		res, err := queryRunner.Exec(bi.query, bi.args...)
+		if err != nil {
+			return err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	}
+	if id != 0 && !setAutoIncrValue(f, id) {
+		return fmt.Errorf("godb: cannot set autoincrement value on non-Int field %s", bi.autoIncrFieldName)
+	}
+	return nil
+}
+
+func (t *TableMap) bindUpsert(opts UpsertOptions, elem reflect.Value) (bindInstance, error) {
+	upserter, ok := t.dbUtils.Dialect.(Upserter)
+	if !ok {
+		return bindInstance{}, fmt.Errorf("godb: dialect %T does not support upsert", t.dbUtils.Dialect)
+	}
+
+	conflict, err := t.conflictColumns(opts.Constraint)
+	if err != nil {
+		return bindInstance{}, err
+	}
+
+	bi := bindInstance{autoIncrIdx: -1}
+	var (
+		columns, values, update []string
+		autoIncr                *ColumnMap
+	)
+	for _, col := range t.Columns {
+		if col.Transient {
+			continue
+		}
+		if col.isAutoIncr {
+			if !containsColumn(conflict, col) {
+				// left to the database, and read back when possible
+				autoIncr = col
+				continue
+			}
+			if generated, ok := upserter.(GeneratedKeyUpserter); ok && generated.GeneratedKeys() {
+				return bindInstance{}, fmt.Errorf("godb: dialect %T cannot insert the autoincrement key %s of %s, set UpsertOptions.Constraint to identify the row",
+					t.dbUtils.Dialect, col.fieldName, t.TableName)
+			}
+			if elem.FieldByIndex(col.fieldIndex).IsZero() {
+				return bindInstance{}, fmt.Errorf("godb: cannot upsert into %s with a zero autoincrement key %s, set UpsertOptions.Constraint to identify the row",
+					t.TableName, col.fieldName)
+			}
+		}
+
+		var val interface{}
+		if col == t.version {
+			// an upsert cannot check the version of the row it updates
+			if !opts.DoNothing {
+				return bindInstance{}, fmt.Errorf("godb: cannot upsert into %s with the version column %s, use DoNothing or Update",
+					t.TableName, col.fieldName)
+			}
+			// a new row gets the version of the struct, which is unchanged
+			val = elem.FieldByIndex(col.fieldIndex).Int()
+		} else {
+			val = elem.FieldByIndex(col.fieldIndex).Interface()
+			if t.dbUtils.TypeConverter != nil {
+				val, err = t.dbUtils.TypeConverter.ToDb(val)
+				if err != nil {
+					return bindInstance{}, err
+				}
+			}
+		}
+
+		columns = append(columns, col.ColumnName)
+		values = append(values, t.dbUtils.Dialect.BindVar(len(bi.args)))
+		bi.args = append(bi.args, val)
+
+		if !opts.DoNothing && len(opts.UpdateFields) == 0 && !containsColumn(conflict, col) && !col.isCreated {
+			update = append(update, col.ColumnName)
+		}
+	}
+	if !opts.DoNothing {
+		for _, field := range opts.UpdateFields {
+			col := colMapOrNil(t, field)
+			if col == nil || col.Transient {
+				return bindInstance{}, fmt.Errorf("godb: no field %s to update in table %s", field, t.TableName)
+			}
+			update = append(update, col.ColumnName)
+		}
+	}
+
+	conflictNames := make([]string, len(conflict))
+	for i, col := range conflict {
+		conflictNames[i] = col.ColumnName
+	}
+
+	if returner, ok := upserter.(UpsertKeyReturner); ok && autoIncr != nil {
+		bi.query, bi.autoIncrQuery = returner.UpsertKeySql(t.SchemaName, t.TableName, columns, values, conflictNames, update, autoIncr.ColumnName)
+		bi.autoIncrIdx = len(columns)
+		bi.autoIncrFieldName = autoIncr.fieldName
+		return bi, nil
+	}
+	bi.query = upserter.UpsertSql(t.SchemaName, t.TableName, columns, values, conflictNames, update)
+	return bi, nil
+}
+
+// conflictColumns returns the columns of the named unique constraint, or
+// the primary key if name is empty.
+func (t *TableMap) conflictColumns(name string) ([]*ColumnMap, error) {
+	if name == "" {
+		return t.keys, nil
+	}
+
+	for _, unique := range t.uniqueTogether {
+		if unique.name != name {
+			continue
+		}
+		cols := make([]*ColumnMap, len(unique.columns))
+		for i, field := range unique.columns {
+			cols[i] = colMapOrNil(t, field)
+			if cols[i] == nil {
+				return nil, fmt.Errorf("godb: no field %s in table %s for constraint %s", field, t.TableName, name)
+			}
+		}
+		return cols, nil
+	}
+	return nil, fmt.Errorf("godb: no unique constraint %s in table %s", name, t.TableName)
+}
+
+func containsColumn(cols []*ColumnMap, col *ColumnMap) bool {
+	for _, c := range cols {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// standardUpsertSql builds the "insert ... on conflict" statement shared by
+// PostgreSQL and sqlite.
+func standardUpsertSql(d Dialect, schema, table string, columns, values, conflict, update []string) string {
+	s := fmt.Sprintf("insert into %s (%s) values (%s) on conflict (%s)",
+		d.QuotedTableForQuery(schema, table), quoteFields(d, columns, ""), strings.Join(values, ","),
+		quoteFields(d, conflict, ""))
+	if len(update) == 0 {
+		return s + " do nothing" + d.QuerySuffix()
+	}
+
+	s += " do update set "
+	for i, col := range update {
+		if i > 0 {
+			s += ", "
+		}
+		s += d.QuoteField(col) + "=excluded." + d.QuoteField(col)
+	}
+	return s + d.QuerySuffix()
+}
+
+// returningUpsertSql adds a returning clause for key to the statement of
+// standardUpsertSql.
+func returningUpsertSql(d Dialect, schema, table string, columns, values, conflict, update []string, key string) string {
+	s := strings.TrimSuffix(standardUpsertSql(d, schema, table, columns, values, conflict, update), d.QuerySuffix())
+	return s + " returning " + d.QuoteField(key) + d.QuerySuffix()
+}
+
+// mergeUpsertSql builds the "merge" statement used by the dialects without
+// an upsert clause. source is the select or values expression naming the
+// new row "s".
+func mergeUpsertSql(d Dialect, target, source string, columns, conflict, update []string) string {
+	s := fmt.Sprintf("merge into %s %s on (", target, source)
+	for i, col := range conflict {
+		if i > 0 {
+			s += " and "
+		}
+		s += "t." + d.QuoteField(col) + "=s." + d.QuoteField(col)
+	}
+	s += ")"
+
+	if len(update) > 0 {
+		s += " when matched then update set "
+		for i, col := range update {
+			if i > 0 {
+				s += ", "
+			}
+			s += "t." + d.QuoteField(col) + "=s." + d.QuoteField(col)
+		}
+	}
+	s += fmt.Sprintf(" when not matched then insert (%s) values (%s)",
+		quoteFields(d, columns, ""), quoteFields(d, columns, "s."))
+	return s
+}
+
+func quoteFields(d Dialect, fields []string, prefix string) string {
+	s := ""
+	for i, field := range fields {
+		if i > 0 {
+			s += ","
+		}
+		s += prefix + d.QuoteField(field)
+	}
+	return s
+}
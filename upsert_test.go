@@ -0,0 +1,230 @@
+package godb
+
+import (
+	"reflect"
+	"testing"
+)
+
+type UpsertPerson struct {
+	Id        int64
+	FirstName string
+	LastName  string
+	Visits    int64
+}
+
+type VersionedUpsertPerson struct {
+	Id      int64
+	Name    string
+	Version int64 `db:"version, version"`
+}
+
+func TestTableMap_bindUpsert(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		// empty for dialects that cannot insert the autoincrement key
+		pk      string
+		named   string
+		nothing string
+	}{
+		{MySQLDialect{},
+			"insert into `upsert_test` (`Id`,`FirstName`,`LastName`,`Visits`) values (?,?,?,?) on duplicate key update `FirstName`=values(`FirstName`), `LastName`=values(`LastName`), `Visits`=values(`Visits`);",
+			"insert into `upsert_test` (`FirstName`,`LastName`,`Visits`) values (?,?,?) on duplicate key update `Id`=last_insert_id(`Id`), `Visits`=values(`Visits`);",
+			"insert into `upsert_test` (`FirstName`,`LastName`,`Visits`) values (?,?,?) on duplicate key update `Id`=last_insert_id(`Id`);"},
+		{PostgresDialect{},
+			`insert into "upsert_test" ("Id","FirstName","LastName","Visits") values ($1,$2,$3,$4) on conflict ("Id") do update set "FirstName"=excluded."FirstName", "LastName"=excluded."LastName", "Visits"=excluded."Visits";`,
+			`insert into "upsert_test" ("FirstName","LastName","Visits") values ($1,$2,$3) on conflict ("FirstName","LastName") do update set "Visits"=excluded."Visits" returning "Id";`,
+			`insert into "upsert_test" ("FirstName","LastName","Visits") values ($1,$2,$3) on conflict ("FirstName","LastName") do nothing returning "Id";`},
+		{SqliteDialect{},
+			`insert into "upsert_test" ("Id","FirstName","LastName","Visits") values (?,?,?,?) on conflict ("Id") do update set "FirstName"=excluded."FirstName", "LastName"=excluded."LastName", "Visits"=excluded."Visits";`,
+			`insert into "upsert_test" ("FirstName","LastName","Visits") values (?,?,?) on conflict ("FirstName","LastName") do update set "Visits"=excluded."Visits" returning "Id";`,
+			`insert into "upsert_test" ("FirstName","LastName","Visits") values (?,?,?) on conflict ("FirstName","LastName") do nothing returning "Id";`},
+		{SqlServerDialect{},
+			"",
+			`merge into [upsert_test] with (holdlock) as t using (values (?,?,?)) as s ([FirstName],[LastName],[Visits]) on (t.[FirstName]=s.[FirstName] and t.[LastName]=s.[LastName]) when matched then update set t.[Visits]=s.[Visits] when not matched then insert ([FirstName],[LastName],[Visits]) values (s.[FirstName],s.[LastName],s.[Visits]) output inserted.[Id];`,
+			`merge into [upsert_test] with (holdlock) as t using (values (?,?,?)) as s ([FirstName],[LastName],[Visits]) on (t.[FirstName]=s.[FirstName] and t.[LastName]=s.[LastName]) when not matched then insert ([FirstName],[LastName],[Visits]) values (s.[FirstName],s.[LastName],s.[Visits]) output inserted.[Id];`},
+		{Db2Dialect{},
+			"",
+			`merge into "UPSERT_TEST" t using (values (?,?,?)) as s ("FIRSTNAME","LASTNAME","VISITS") on (t."FIRSTNAME"=s."FIRSTNAME" and t."LASTNAME"=s."LASTNAME") when matched then update set t."VISITS"=s."VISITS" when not matched then insert ("FIRSTNAME","LASTNAME","VISITS") values (s."FIRSTNAME",s."LASTNAME",s."VISITS")`,
+			`merge into "UPSERT_TEST" t using (values (?,?,?)) as s ("FIRSTNAME","LASTNAME","VISITS") on (t."FIRSTNAME"=s."FIRSTNAME" and t."LASTNAME"=s."LASTNAME") when not matched then insert ("FIRSTNAME","LASTNAME","VISITS") values (s."FIRSTNAME",s."LASTNAME",s."VISITS")`},
+		{OracleDialect{},
+			`merge into "UPSERT_TEST" t using (select :1 "ID", :2 "FIRSTNAME", :3 "LASTNAME", :4 "VISITS" from dual) s on (t."ID"=s."ID") when matched then update set t."FIRSTNAME"=s."FIRSTNAME", t."LASTNAME"=s."LASTNAME", t."VISITS"=s."VISITS" when not matched then insert ("ID","FIRSTNAME","LASTNAME","VISITS") values (s."ID",s."FIRSTNAME",s."LASTNAME",s."VISITS")`,
+			`merge into "UPSERT_TEST" t using (select :1 "FIRSTNAME", :2 "LASTNAME", :3 "VISITS" from dual) s on (t."FIRSTNAME"=s."FIRSTNAME" and t."LASTNAME"=s."LASTNAME") when matched then update set t."VISITS"=s."VISITS" when not matched then insert ("FIRSTNAME","LASTNAME","VISITS") values (s."FIRSTNAME",s."LASTNAME",s."VISITS")`,
+			`merge into "UPSERT_TEST" t using (select :1 "FIRSTNAME", :2 "LASTNAME", :3 "VISITS" from dual) s on (t."FIRSTNAME"=s."FIRSTNAME" and t."LASTNAME"=s."LASTNAME") when not matched then insert ("FIRSTNAME","LASTNAME","VISITS") values (s."FIRSTNAME",s."LASTNAME",s."VISITS")`},
+	}
+
+	p := &UpsertPerson{Id: 1, FirstName: "a", LastName: "b", Visits: 3}
+	elem := reflect.ValueOf(p).Elem()
+
+	for _, test := range tests {
+		dbUtils := &DbUtils{Dialect: test.dialect}
+		table := dbUtils.AddTableWithName(UpsertPerson{}, "upsert_test").SetKeys(true, "Id").
+			SetUniqueTogetherWithName("upsert_name", "FirstName", "LastName")
+
+		for _, c := range []struct {
+			opts  UpsertOptions
+			query string
+		}{
+			{UpsertOptions{}, test.pk},
+			{UpsertOptions{Constraint: "upsert_name", UpdateFields: []string{"Visits"}}, test.named},
+			{UpsertOptions{Constraint: "upsert_name", DoNothing: true}, test.nothing},
+		} {
+			bi, err := table.bindUpsert(c.opts, elem)
+			if c.query == "" {
+				// the autoincrement key cannot be inserted
+				if err == nil {
+					t.Errorf("%T: expected an error for an autoincrement key without constraint", test.dialect)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bi.query != c.query {
+				t.Errorf("%T: got %s, want %s", test.dialect, bi.query, c.query)
+			}
+		}
+	}
+}
+
+func TestTableMap_bindUpsertExplicitKeys(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		query   string
+	}{
+		{SqlServerDialect{},
+			`merge into [upsert_test] with (holdlock) as t using (values (?,?,?,?)) as s ([Id],[FirstName],[LastName],[Visits]) on (t.[Id]=s.[Id]) when matched then update set t.[FirstName]=s.[FirstName], t.[LastName]=s.[LastName], t.[Visits]=s.[Visits] when not matched then insert ([Id],[FirstName],[LastName],[Visits]) values (s.[Id],s.[FirstName],s.[LastName],s.[Visits]);`},
+		{Db2Dialect{},
+			`merge into "UPSERT_TEST" t using (values (?,?,?,?)) as s ("ID","FIRSTNAME","LASTNAME","VISITS") on (t."ID"=s."ID") when matched then update set t."FIRSTNAME"=s."FIRSTNAME", t."LASTNAME"=s."LASTNAME", t."VISITS"=s."VISITS" when not matched then insert ("ID","FIRSTNAME","LASTNAME","VISITS") values (s."ID",s."FIRSTNAME",s."LASTNAME",s."VISITS")`},
+	}
+
+	elem := reflect.ValueOf(&UpsertPerson{Id: 1, FirstName: "a", LastName: "b", Visits: 3}).Elem()
+	for _, test := range tests {
+		dbUtils := &DbUtils{Dialect: test.dialect}
+		// a key that is not autoincrement is inserted as is
+		table := dbUtils.AddTableWithName(UpsertPerson{}, "upsert_test").SetKeys(false, "Id")
+		bi, err := table.bindUpsert(UpsertOptions{}, elem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bi.query != test.query {
+			t.Errorf("%T: got %s, want %s", test.dialect, bi.query, test.query)
+		}
+	}
+}
+
+func TestTableMap_bindUpsertErrors(t *testing.T) {
+	dbUtils := &DbUtils{Dialect: PostgresDialect{}}
+	table := dbUtils.AddTableWithName(UpsertPerson{}, "upsert_test").SetKeys(true, "Id")
+	elem := reflect.ValueOf(&UpsertPerson{Id: 1}).Elem()
+
+	if _, err := table.bindUpsert(UpsertOptions{}, reflect.ValueOf(&UpsertPerson{}).Elem()); err == nil {
+		t.Error("expected an error for a zero autoincrement key without constraint")
+	}
+	if _, err := table.bindUpsert(UpsertOptions{Constraint: "missing"}, elem); err == nil {
+		t.Error("expected an error for an unknown constraint")
+	}
+	if _, err := table.bindUpsert(UpsertOptions{UpdateFields: []string{"Missing"}}, elem); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+
+	versioned := dbUtils.AddTableWithName(VersionedUpsertPerson{}, "versioned_upsert_test").SetKeys(false, "Id")
+	velem := reflect.ValueOf(&VersionedUpsertPerson{Id: 1, Version: 3}).Elem()
+	if _, err := versioned.bindUpsert(UpsertOptions{}, velem); err == nil {
+		t.Error("expected an error for an upsert updating a versioned table")
+	}
+	bi, err := versioned.bindUpsert(UpsertOptions{DoNothing: true}, velem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bi.args[len(bi.args)-1] != int64(3) {
+		t.Errorf("expected the version of the struct, got %v", bi.args)
+	}
+}
+
+func Test_UpsertStaleVersion(t *testing.T) {
+	dbmap := initSqliteDB(t, "upsert")
+	dbmap.AddTableWithName(VersionedUpsertPerson{}, "versioned_upsert_test").SetKeys(false, "Id")
+	if err := dbmap.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &VersionedUpsertPerson{Id: 1, Name: "first"}
+	if err := dbmap.Insert(p); err != nil {
+		t.Fatal(err)
+	}
+	stale := *p
+	p.Name = "second"
+	if _, err := dbmap.Update(p); err != nil {
+		t.Fatal(err)
+	}
+
+	stale.Name = "stale"
+	if err := dbmap.Upsert(&stale); err == nil {
+		t.Error("expected an error for an upsert of a stale version")
+	}
+	stale.Name = "ignored"
+	if err := dbmap.UpsertWithOptions(UpsertOptions{DoNothing: true}, &stale); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := dbmap.Get(VersionedUpsertPerson{}, p.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := obj.(*VersionedUpsertPerson); got.Name != "second" || got.Version != p.Version {
+		t.Errorf("expected the updated row, got %+v", got)
+	}
+}
+
+func Test_Upsert(t *testing.T) {
+	dbmap := initDB()
+	dbmap.AddTableWithName(UpsertPerson{}, "upsert_test").SetKeys(true, "Id").
+		SetUniqueTogetherWithName("upsert_name", "FirstName", "LastName")
+	dbmap.CreateTablesIfNotExists()
+	defer close(dbmap)
+
+	p := &UpsertPerson{FirstName: "cly", LastName: "hs", Visits: 1}
+	_insert(dbmap, p)
+
+	same := &UpsertPerson{FirstName: "cly", LastName: "hs", Visits: 2}
+	err := dbmap.UpsertWithOptions(UpsertOptions{Constraint: "upsert_name"}, same)
+	if err != nil {
+		panic(err)
+	}
+	if same.Id != p.Id {
+		t.Errorf("expected the key of the existing row, got %d != %d", same.Id, p.Id)
+	}
+	obj := _get(dbmap, UpsertPerson{}, p.Id).(*UpsertPerson)
+	if obj.Visits != 2 {
+		t.Errorf("%d != 2", obj.Visits)
+	}
+
+	same.Visits = 3
+	err = dbmap.UpsertWithOptions(UpsertOptions{Constraint: "upsert_name", DoNothing: true}, same)
+	if err != nil {
+		panic(err)
+	}
+	obj = _get(dbmap, UpsertPerson{}, p.Id).(*UpsertPerson)
+	if obj.Visits != 2 {
+		t.Errorf("%d != 2", obj.Visits)
+	}
+
+	p.Visits = 10
+	if err = dbmap.Upsert(p); err != nil {
+		panic(err)
+	}
+	obj = _get(dbmap, UpsertPerson{}, p.Id).(*UpsertPerson)
+	if obj.Visits != 10 {
+		t.Errorf("%d != 10", obj.Visits)
+	}
+
+	fresh := &UpsertPerson{FirstName: "new", LastName: "row", Visits: 1}
+	if err = dbmap.UpsertWithOptions(UpsertOptions{Constraint: "upsert_name"}, fresh); err != nil {
+		panic(err)
+	}
+	if fresh.Id == 0 || fresh.Id == p.Id {
+		t.Errorf("expected the generated key of the new row, got %d", fresh.Id)
+	}
+	if err = dbmap.Upsert(&UpsertPerson{FirstName: "zero", LastName: "key"}); err == nil {
+		t.Error("expected an error upserting a zero autoincrement key without constraint")
+	}
+}